@@ -43,15 +43,37 @@ Package config 配置管理包
 运行方式：
 1. 服务器模式: ./vodcms --mode=server --port=8080
 2. CLI模式:    ./vodcms --mode=cli
+3. 媒体库导出: ./vodcms --mode=library --out=/data/library
+4. 训练分类器: ./vodcms --mode=train-classifier --out=category_classifier.json
 */
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	ServerPort   string
 	DatabasePath string
 	SourceConfig string
+	LibraryDir   string // 媒体库导出目录
+	SiteURL      string // 前台站点地址，用于订阅源和站点地图中的链接（为空时使用请求地址）
+	// VideoURLTemplate 前台视频详情页地址模板，支持 {site}、{vod_id}、{pinyin} 占位符
+	VideoURLTemplate string
+	// 图片代理：缓存目录、是否在导入后预热封面、是否允许访问内网地址
+	ImageCacheDir     string
+	ImagePrewarm      bool
+	ImageAllowPrivate bool
+	// 播放地址检测：定时检测间隔（0 表示不定时检测）、每个资源站每轮抽样的视频数
+	PlayCheckInterval time.Duration
+	PlayCheckSample   int
+	// 映射规则变化后是否自动重新分类已入库的视频（请求中的 recategorize 参数优先）
+	RecategorizeOnRuleChange bool
+	// 分类器模型文件（--mode=train-classifier 生成，启动时加载）
+	ClassifierModel string
 }
 
 var AppConfig *Config
@@ -62,6 +84,21 @@ func LoadConfig() {
 		ServerPort:   getEnv("PORT", "8080"),
 		DatabasePath: getEnv("DB_PATH", "vodcms.db"),
 		SourceConfig: getEnv("SOURCE_CONFIG", "sources_config.json"),
+		LibraryDir:   getEnv("LIBRARY_DIR", "library"),
+		SiteURL:      strings.TrimRight(getEnv("SITE_URL", ""), "/"),
+
+		VideoURLTemplate: getEnv("VIDEO_URL_TEMPLATE", "{site}/video/{vod_id}"),
+
+		ImageCacheDir:     getEnv("IMAGE_CACHE_DIR", "image_cache"),
+		ImagePrewarm:      getEnv("IMAGE_PREWARM", "1") != "0",
+		ImageAllowPrivate: getEnv("IMAGE_ALLOW_PRIVATE", "0") == "1",
+
+		PlayCheckInterval: time.Duration(getEnvInt("PLAY_CHECK_INTERVAL", 360)) * time.Minute,
+		PlayCheckSample:   getEnvInt("PLAY_CHECK_SAMPLE", 100),
+
+		RecategorizeOnRuleChange: getEnv("RECATEGORIZE_ON_RULE_CHANGE", "0") == "1",
+
+		ClassifierModel: getEnv("CLASSIFIER_MODEL", "category_classifier.json"),
 	}
 }
 
@@ -71,3 +108,10 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return defaultValue
+}
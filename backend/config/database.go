@@ -46,6 +46,14 @@ func InitDatabase() error {
 		&models.UnmappedCategory{},
 		&models.MappingRule{},
 		&models.FuzzyMatchRule{},
+		&models.SubCategoryRule{},
+		&models.MappingChangeset{},
+		&models.VideoHistory{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.PlayCheck{},
+		&models.StandardCategory{},
+		&models.StandardSubCategory{},
 	)
 	if err != nil {
 		return fmt.Errorf("数据库迁移失败: %w", err)
@@ -4,45 +4,72 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+
+	"vodcms/models"
+	"vodcms/utils"
 )
 
-// GetStandardCategories 获取标准分类列表
+// GetStandardCategories 获取标准分类（只返回显示的分类）
+// GET /api/categories
+// 保持 category_mapping.json 的旧格式：以分类ID为键，subcategories 为 "子分类ID: 名称"
 func GetStandardCategories(c *gin.Context) {
-	file, err := os.ReadFile("category_mapping.json")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "读取分类配置失败",
-		})
-		return
-	}
-
-	var config struct {
-		StandardCategories map[string]interface{} `json:"standard_categories"`
+	categories := make(map[string]gin.H)
+	for _, cat := range visibleTaxonomy() {
+		subs := make(map[string]string, len(cat.Subcategories))
+		for _, sub := range cat.Subcategories {
+			subs[strconv.Itoa(sub.ID)] = sub.Name
+		}
+		categories[strconv.Itoa(cat.ID)] = gin.H{
+			"id":            cat.ID,
+			"name":          cat.Name,
+			"subcategories": subs,
+		}
 	}
 
-	if err := json.Unmarshal(file, &config); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "解析分类配置失败",
-		})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": categories,
+	})
+}
 
+// GetStandardCategoryTree 获取标准分类列表（只返回显示的分类，按排序排列，含图标，子分类在 subcategories 中）
+// GET /api/categories/tree
+func GetStandardCategoryTree(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
-		"data": config.StandardCategories,
+		"data": visibleTaxonomy(),
 	})
 }
 
+// visibleTaxonomy 过滤掉隐藏的分类和子分类
+func visibleTaxonomy() []utils.TaxonomyCategory {
+	categories := make([]utils.TaxonomyCategory, 0)
+	for _, cat := range utils.GetTaxonomy().Categories {
+		if !cat.IsVisible {
+			continue
+		}
+		visible := cat
+		visible.Subcategories = make([]models.StandardSubCategory, 0, len(cat.Subcategories))
+		for _, sub := range cat.Subcategories {
+			if sub.IsVisible {
+				visible.Subcategories = append(visible.Subcategories, sub)
+			}
+		}
+		categories = append(categories, visible)
+	}
+	return categories
+}
+
 // GetCategoryMappings 获取分类映射配置
 func GetCategoryMappings(c *gin.Context) {
 	sourceKey := c.Query("source_key")
 
-	file, err := os.ReadFile("category_mapping.json")
+	file, err := os.ReadFile(utils.CategoryMappingFile)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
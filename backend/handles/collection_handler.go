@@ -127,10 +127,12 @@ func collectAndSave(mode CollectMode, sourceKeys []string, maxPages int) error {
 
 		db.Save(&log)
 
-		// 读取采集的JSON文件并保存到数据库
-		if err := utils.ImportVideoFromJSON(source.Key); err != nil {
+		// 读取采集的JSON文件并保存到数据库，导入结束后再触发采集事件
+		result, err := utils.ImportVideoFromJSON(source.Key, log.ID)
+		if err != nil {
 			fmt.Printf("⚠️ 导入数据库失败: %v\n", err)
 		}
+		utils.NotifyCollectionLog(db, &log, result, err)
 	}
 
 	return nil
@@ -173,7 +175,7 @@ func ImportJSON(c *gin.Context) {
 	}
 
 	// TODO: 调用导入功能
-	// utils.ImportVideoFromJSON(req.SourceKey)
+	// utils.ImportVideoFromJSON(req.SourceKey, 0)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
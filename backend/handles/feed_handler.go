@@ -0,0 +1,452 @@
+package handles
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"vodcms/config"
+	"vodcms/models"
+	"vodcms/utils"
+)
+
+// 订阅源（RSS 2.0 / Atom）
+// 1. new：新上架的视频，按首次入库时间排序
+// 2. updated：最近更新的剧集（电影以外的分类），按采集时间排序，同一视频每次更新生成新条目
+// 3. categories/:category_id：某个标准分类下最近采集的视频
+// 4. 支持 ETag / Last-Modified，内容未变化时返回 304
+
+const (
+	feedDefaultLimit = 50
+	feedMaxLimit     = 100
+	feedCacheMaxAge  = 300 // 秒
+)
+
+// feedEntry 订阅源条目
+type feedEntry struct {
+	Video     models.Video
+	PerUpdate bool // 每次更新生成新条目（ID 带上更新时间）
+	Published time.Time
+	Updated   time.Time
+}
+
+// feed 生成订阅源所需的数据
+type feed struct {
+	Kind    string
+	Title   string
+	SelfURL string
+	SiteURL string
+	Entries []feedEntry
+}
+
+// GetNewVideosFeed 新上架视频订阅源
+// GET /api/feeds/new?format=rss|atom&limit=50
+func GetNewVideosFeed(c *gin.Context) {
+	db := config.GetDB()
+	limit := feedLimit(c)
+
+	// 每个视频最早入库的记录即为上架时间
+	subQuery := db.Table("videos").
+		Select("MIN(id) as id").
+		Group("vod_id")
+	var videos []models.Video
+	if err := db.Where("id IN (?)", subQuery).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&videos).Error; err != nil {
+		feedError(c, err)
+		return
+	}
+
+	entries := make([]feedEntry, 0, len(videos))
+	for _, video := range videos {
+		entries = append(entries, feedEntry{
+			Video:     video,
+			Published: video.CreatedAt,
+			Updated:   video.CreatedAt,
+		})
+	}
+	renderFeed(c, &feed{Kind: "new", Title: "最新上架", Entries: entries})
+}
+
+// GetUpdatedSeriesFeed 最近更新的剧集订阅源
+// GET /api/feeds/updated?format=rss|atom&limit=50
+func GetUpdatedSeriesFeed(c *gin.Context) {
+	db := config.GetDB()
+	query := feedLatestQuery(db).Where("standard_category_id <> ?", utils.MovieCategoryID)
+
+	entries, err := loadUpdatedFeedEntries(query, feedLimit(c), true)
+	if err != nil {
+		feedError(c, err)
+		return
+	}
+	renderFeed(c, &feed{Kind: "updated", Title: "剧集更新", Entries: entries})
+}
+
+// GetCategoryFeed 标准分类更新订阅源
+// GET /api/feeds/categories/:category_id?sub_category_id=201&format=rss|atom
+func GetCategoryFeed(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Param("category_id"))
+	if err != nil || categoryID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "category_id 参数无效",
+		})
+		return
+	}
+
+	db := config.GetDB()
+	query := feedLatestQuery(db).Where("standard_category_id = ?", categoryID)
+	var subCategoryID *int
+	if sub := c.Query("sub_category_id"); sub != "" {
+		id, err := strconv.Atoi(sub)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "sub_category_id 参数无效",
+			})
+			return
+		}
+		query = query.Where("standard_sub_category_id = ?", id)
+		subCategoryID = &id
+	}
+
+	entries, err := loadUpdatedFeedEntries(query, feedLimit(c), false)
+	if err != nil {
+		feedError(c, err)
+		return
+	}
+
+	title := fmt.Sprintf("分类 %d", categoryID)
+	if name, subName := utils.StandardCategoryNames(categoryID, subCategoryID); subName != "" {
+		title = subName
+	} else if name != "" {
+		title = name
+	}
+	renderFeed(c, &feed{Kind: "category", Title: title + "更新", Entries: entries})
+}
+
+// feedLatestQuery 每个视频取最新的一条记录
+func feedLatestQuery(db *gorm.DB) *gorm.DB {
+	subQuery := db.Table("videos").
+		Select("MAX(id) as id").
+		Group("vod_id")
+	return db.Model(&models.Video{}).Where("id IN (?)", subQuery)
+}
+
+// loadUpdatedFeedEntries 按采集时间倒序读取条目
+func loadUpdatedFeedEntries(query *gorm.DB, limit int, perUpdate bool) ([]feedEntry, error) {
+	var videos []models.Video
+	if err := query.Order("collected_at DESC, id DESC").Limit(limit).Find(&videos).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]feedEntry, 0, len(videos))
+	for _, video := range videos {
+		entries = append(entries, feedEntry{
+			Video:     video,
+			PerUpdate: perUpdate,
+			Published: video.CollectedAt,
+			Updated:   video.CollectedAt,
+		})
+	}
+	return entries, nil
+}
+
+// renderFeed 处理条件请求并输出 RSS 或 Atom
+func renderFeed(c *gin.Context, f *feed) {
+	format := c.DefaultQuery("format", "rss")
+	if format != "rss" && format != "atom" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "format 参数只支持 rss 或 atom",
+		})
+		return
+	}
+
+	f.SiteURL = siteBaseURL(c)
+	f.SelfURL = requestBaseURL(c) + c.Request.URL.RequestURI()
+
+	var lastModified time.Time
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", format, f.SiteURL, f.SelfURL)
+	for _, entry := range f.Entries {
+		if entry.Updated.After(lastModified) {
+			lastModified = entry.Updated
+		}
+		fmt.Fprintf(h, "%d:%d:%d\n", entry.Video.ID, entry.Updated.UnixNano(), entry.Video.UpdatedAt.UnixNano())
+	}
+	if lastModified.IsZero() {
+		lastModified = time.Unix(0, 0)
+	}
+	etag := `W/"` + hex.EncodeToString(h.Sum(nil))[:20] + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", feedCacheMaxAge))
+	if feedNotModified(c, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	var doc interface{}
+	contentType := "application/rss+xml; charset=utf-8"
+	if format == "atom" {
+		doc = buildAtomFeed(f, lastModified)
+		contentType = "application/atom+xml; charset=utf-8"
+	} else {
+		doc = buildRSSFeed(f, lastModified)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		feedError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, contentType, append([]byte(xml.Header), data...))
+}
+
+// feedNotModified 判断条件请求，If-None-Match 优先于 If-Modified-Since
+func feedNotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if since := c.GetHeader("If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+func feedLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return feedDefaultLimit
+	}
+	if limit > feedMaxLimit {
+		return feedMaxLimit
+	}
+	return limit
+}
+
+func feedError(c *gin.Context, err error) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"code": 500,
+		"msg":  "生成订阅源失败: " + err.Error(),
+	})
+}
+
+// siteBaseURL 前台站点地址，未配置 SITE_URL 时使用请求地址
+func siteBaseURL(c *gin.Context) string {
+	if config.AppConfig.SiteURL != "" {
+		return config.AppConfig.SiteURL
+	}
+	return requestBaseURL(c)
+}
+
+// videoPageURL 视频在前台的详情页地址（按 VIDEO_URL_TEMPLATE 生成）
+func videoPageURL(siteURL string, vodID int, pinyin string) string {
+	return strings.NewReplacer(
+		"{site}", siteURL,
+		"{vod_id}", strconv.Itoa(vodID),
+		"{pinyin}", url.PathEscape(pinyin),
+	).Replace(config.AppConfig.VideoURLTemplate)
+}
+
+// feedEntryID 条目唯一标识，按更新生成条目时带上更新时间，阅读器会把每次更新当作新条目
+func feedEntryID(link string, entry *feedEntry) string {
+	if entry.PerUpdate {
+		return link + "#" + strconv.FormatInt(entry.Updated.Unix(), 10)
+	}
+	return link
+}
+
+// feedEntryTitle 剧集条目带上更新状态，例如"某剧 更新至10集"
+func feedEntryTitle(f *feed, video *models.Video) string {
+	if f.Kind != "new" && video.VodRemarks != "" {
+		return video.VodName + " " + video.VodRemarks
+	}
+	return video.VodName
+}
+
+// feedEntrySummary 优先使用简介，没有时截取详情
+func feedEntrySummary(video *models.Video) string {
+	summary := utils.StripHTML(video.VodBlurb)
+	if summary == "" {
+		summary = utils.StripHTML(video.VodContent)
+	}
+	if runes := []rune(summary); len(runes) > 200 {
+		summary = string(runes[:200]) + "…"
+	}
+	return summary
+}
+
+func feedEntryCategories(video *models.Video) []string {
+	var categories []string
+	for _, name := range []string{video.StandardCategoryName, video.StandardSubCategoryName} {
+		if name != "" {
+			categories = append(categories, name)
+		}
+	}
+	return categories
+}
+
+// ============ RSS 2.0 ============
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	Language      string      `xml:"language"`
+	LastBuildDate string      `xml:"lastBuildDate"`
+	AtomLink      rssAtomLink `xml:"atom:link"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Description string        `xml:"description,omitempty"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func buildRSSFeed(f *feed, lastModified time.Time) *rssFeed {
+	channel := rssChannel{
+		Title:         "VodCMS - " + f.Title,
+		Link:          f.SiteURL,
+		Description:   "VodCMS " + f.Title,
+		Language:      "zh-cn",
+		LastBuildDate: lastModified.Format(time.RFC1123Z),
+		AtomLink:      rssAtomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		Items:         make([]rssItem, 0, len(f.Entries)),
+	}
+	for i := range f.Entries {
+		entry := &f.Entries[i]
+		link := videoPageURL(f.SiteURL, entry.Video.VodID, entry.Video.VodPinyin)
+		item := rssItem{
+			Title:       feedEntryTitle(f, &entry.Video),
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: !entry.PerUpdate, Value: feedEntryID(link, entry)},
+			PubDate:     entry.Published.Format(time.RFC1123Z),
+			Description: feedEntrySummary(&entry.Video),
+			Categories:  feedEntryCategories(&entry.Video),
+		}
+		if entry.Video.VodPic != "" {
+			item.Enclosure = &rssEnclosure{URL: entry.Video.VodPic, Type: "image/jpeg"}
+		}
+		channel.Items = append(channel.Items, item)
+	}
+	return &rssFeed{Version: "2.0", AtomNS: "http://www.w3.org/2005/Atom", Channel: channel}
+}
+
+// ============ Atom ============
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+func buildAtomFeed(f *feed, lastModified time.Time) *atomFeed {
+	doc := &atomFeed{
+		Title:   "VodCMS - " + f.Title,
+		ID:      f.SelfURL,
+		Updated: lastModified.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "VodCMS"},
+		Links: []atomLink{
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.SiteURL, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: make([]atomEntry, 0, len(f.Entries)),
+	}
+	for i := range f.Entries {
+		entry := &f.Entries[i]
+		link := videoPageURL(f.SiteURL, entry.Video.VodID, entry.Video.VodPinyin)
+		item := atomEntry{
+			Title:     feedEntryTitle(f, &entry.Video),
+			ID:        feedEntryID(link, entry),
+			Published: entry.Published.UTC().Format(time.RFC3339),
+			Updated:   entry.Updated.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Href: link, Rel: "alternate", Type: "text/html"}},
+			Summary:   feedEntrySummary(&entry.Video),
+		}
+		if entry.Video.VodPic != "" {
+			item.Links = append(item.Links, atomLink{Href: entry.Video.VodPic, Rel: "enclosure", Type: "image/jpeg"})
+		}
+		for _, name := range feedEntryCategories(&entry.Video) {
+			item.Categories = append(item.Categories, atomCategory{Term: name})
+		}
+		doc.Entries = append(doc.Entries, item)
+	}
+	return doc
+}
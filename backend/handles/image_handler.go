@@ -0,0 +1,74 @@
+package handles
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"vodcms/config"
+	"vodcms/models"
+	"vodcms/utils"
+)
+
+// 封面图片代理
+// 只代理数据库中视频的封面地址（按 vod_id 查找），不接受任意地址，避免成为开放代理
+// 下载失败时重定向到原始地址，由浏览器自行加载
+
+// imageCacheControl 地址不随封面变化，缓存时间较短，过期后用 ETag 重新验证（封面未变时返回 304）
+const imageCacheControl = "public, max-age=3600, must-revalidate"
+
+// GetVideoImage 获取视频封面（缓存到本地磁盘，可按宽度缩放）
+// GET /api/images/videos/:vod_id?w=240
+func GetVideoImage(c *gin.Context) {
+	vodID, err := strconv.Atoi(c.Param("vod_id"))
+	if err != nil || vodID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的视频ID",
+		})
+		return
+	}
+	width, _ := strconv.Atoi(c.Query("w"))
+
+	// 多个资源站的封面可能不同，优先使用最近采集的记录
+	var video models.Video
+	if err := config.GetDB().Select("id, vod_pic").
+		Where("vod_id = ? AND vod_pic <> ''", vodID).
+		Order("collected_at DESC").
+		First(&video).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "封面不存在",
+		})
+		return
+	}
+
+	img, err := utils.GetCachedImage(video.VodPic, width)
+	if err != nil {
+		fmt.Printf("⚠️ 封面代理失败 (vod_id:%d): %v\n", vodID, err)
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, video.VodPic)
+		return
+	}
+
+	f, err := os.Open(img.Path)
+	if err != nil {
+		c.Redirect(http.StatusFound, video.VodPic)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		c.Redirect(http.StatusFound, video.VodPic)
+		return
+	}
+
+	// ServeContent 负责处理 If-None-Match / If-Modified-Since / Range
+	c.Header("Content-Type", img.ContentType)
+	c.Header("ETag", img.ETag)
+	c.Header("Cache-Control", imageCacheControl)
+	http.ServeContent(c.Writer, c.Request, "", stat.ModTime(), f)
+}
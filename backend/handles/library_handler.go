@@ -0,0 +1,94 @@
+package handles
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"vodcms/config"
+	"vodcms/utils"
+)
+
+// LibraryExportJob 媒体库导出任务状态
+type LibraryExportJob struct {
+	Status     string                   `json:"status"` // idle, running, success, failed
+	Dir        string                   `json:"dir"`
+	Stats      utils.LibraryExportStats `json:"stats"`
+	Error      string                   `json:"error,omitempty"`
+	StartedAt  *time.Time               `json:"started_at,omitempty"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+}
+
+var (
+	libraryJobMu sync.Mutex
+	libraryJob   = LibraryExportJob{Status: "idle"}
+)
+
+// StartLibraryExport 启动媒体库导出任务（同一时间只允许一个任务）
+// POST /api/admin/library/export
+func StartLibraryExport(c *gin.Context) {
+	libraryJobMu.Lock()
+	if libraryJob.Status == "running" {
+		job := libraryJob
+		libraryJobMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{
+			"code": 409,
+			"msg":  "已有导出任务正在运行",
+			"data": job,
+		})
+		return
+	}
+	now := time.Now()
+	libraryJob = LibraryExportJob{
+		Status:    "running",
+		Dir:       config.AppConfig.LibraryDir,
+		StartedAt: &now,
+	}
+	job := libraryJob
+	libraryJobMu.Unlock()
+
+	// 异步执行导出任务
+	go func(dir string) {
+		stats, err := utils.ExportLibrary(config.GetDB(), dir, func(stats utils.LibraryExportStats) {
+			libraryJobMu.Lock()
+			libraryJob.Stats = stats
+			libraryJobMu.Unlock()
+		})
+
+		libraryJobMu.Lock()
+		defer libraryJobMu.Unlock()
+		finished := time.Now()
+		libraryJob.FinishedAt = &finished
+		if stats != nil {
+			libraryJob.Stats = *stats
+		}
+		if err != nil {
+			libraryJob.Status = "failed"
+			libraryJob.Error = err.Error()
+			return
+		}
+		libraryJob.Status = "success"
+	}(job.Dir)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "媒体库导出任务已启动",
+		"data": job,
+	})
+}
+
+// GetLibraryExportStatus 获取媒体库导出任务状态
+// GET /api/admin/library/export
+func GetLibraryExportStatus(c *gin.Context) {
+	libraryJobMu.Lock()
+	job := libraryJob
+	libraryJobMu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": job,
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+	"vodcms/config"
 	"vodcms/models"
+	"vodcms/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -71,6 +73,9 @@ func (h *MappingAdminHandler) ApplyCategoryMapping(c *gin.Context) {
 		return
 	}
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "apply_unmapped")
+	defer change.End()
+
 	// 创建映射规则
 	rule := models.MappingRule{
 		SourceKey:     unmapped.SourceKey,
@@ -98,6 +103,7 @@ func (h *MappingAdminHandler) ApplyCategoryMapping(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新状态失败: " + err.Error()})
 		return
 	}
+	afterMappingRuleChange(c, h.db, ruleScope{rule.SourceKey, rule.SourceTypeID})
 
 	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "映射应用成功", "data": rule})
 }
@@ -117,8 +123,15 @@ func (h *MappingAdminHandler) AddMappingRule(c *gin.Context) {
 	if rule.MatchType == "" {
 		rule.MatchType = "exact"
 	}
+	if err := utils.ValidateMappingRuleMatch(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
 	rule.IsActive = true
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "add_rule")
+	defer change.End()
+
 	// 检查是否已存在
 	var existing models.MappingRule
 	err := h.db.Where("source_key = ? AND source_type_id = ?", rule.SourceKey, rule.SourceTypeID).First(&existing).Error
@@ -135,6 +148,7 @@ func (h *MappingAdminHandler) AddMappingRule(c *gin.Context) {
 		}
 		rule = existing
 	}
+	afterMappingRuleChange(c, h.db, ruleScope{rule.SourceKey, rule.SourceTypeID})
 
 	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "规则保存成功", "data": rule})
 }
@@ -181,16 +195,21 @@ func (h *MappingAdminHandler) DeleteMappingRule(c *gin.Context) {
 		return
 	}
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "delete_rule")
+	defer change.End()
+
 	if err := h.db.Model(&rule).Update("is_active", false).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除规则失败: " + err.Error()})
 		return
 	}
+	afterMappingRuleChange(c, h.db, ruleScope{rule.SourceKey, rule.SourceTypeID})
 
 	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "规则已删除"})
 }
 
-// AddFuzzyMatchRule 添加模糊匹配规则
+// AddFuzzyMatchRule 添加模糊匹配规则（pattern 为正则，创建时校验）
 // POST /api/fuzzy-rules
+// Body: {"pattern": "动作|武侠", "keywords": "[\"功夫\"]", "match_fields": "type_name,vod_class", "standard_id": 1, "standard_sub_id": 101}
 func (h *MappingAdminHandler) AddFuzzyMatchRule(c *gin.Context) {
 	var rule models.FuzzyMatchRule
 	if err := c.ShouldBindJSON(&rule); err != nil {
@@ -198,15 +217,23 @@ func (h *MappingAdminHandler) AddFuzzyMatchRule(c *gin.Context) {
 		return
 	}
 
+	if err := utils.ValidateFuzzyMatchRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
 	if rule.Priority == 0 {
 		rule.Priority = 200
 	}
 	rule.IsActive = true
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "add_fuzzy_rule")
+	defer change.End()
+
 	if err := h.db.Create(&rule).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "添加模糊规则失败: " + err.Error()})
 		return
 	}
+	afterMappingRuleChange(c, h.db, ruleScope{}) // 模糊规则对所有资源站生效
 
 	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "模糊规则添加成功", "data": rule})
 }
@@ -230,6 +257,144 @@ func (h *MappingAdminHandler) GetFuzzyMatchRules(c *gin.Context) {
 	})
 }
 
+// GetSubCategoryRules 获取子分类推断规则
+// GET /api/admin/subcategory-rules?standard_id=2
+func (h *MappingAdminHandler) GetSubCategoryRules(c *gin.Context) {
+	query := h.db.Model(&models.SubCategoryRule{})
+	if standardID := c.Query("standard_id"); standardID != "" {
+		query = query.Where("standard_id = ?", standardID)
+	}
+
+	var rules []models.SubCategoryRule
+	if err := query.Order("standard_id ASC, priority ASC, id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取子分类规则失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": len(rules),
+			"rules": rules,
+		},
+	})
+}
+
+// AddSubCategoryRule 添加子分类推断规则
+// POST /api/admin/subcategory-rules
+// Body: {"standard_sub_id": 205, "field": "vod_area", "pattern": "韩国", "confidence": 0.9, "priority": 100}
+func (h *MappingAdminHandler) AddSubCategoryRule(c *gin.Context) {
+	var rule models.SubCategoryRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+	rule.ID = 0
+	if err := utils.ValidateSubCategoryRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if rule.Priority == 0 {
+		rule.Priority = 100
+	}
+	rule.IsActive = true
+
+	change := utils.BeginMappingChange(h.db, adminUser(c), "add_subcategory_rule")
+	defer change.End()
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "添加子分类规则失败: " + err.Error()})
+		return
+	}
+	afterMappingRuleChange(c, h.db, ruleScope{})
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "子分类规则添加成功", "data": rule})
+}
+
+// UpdateSubCategoryRule 修改子分类推断规则（未提供的字段保持不变）
+// PUT /api/admin/subcategory-rules/:id
+func (h *MappingAdminHandler) UpdateSubCategoryRule(c *gin.Context) {
+	var rule models.SubCategoryRule
+	if err := h.db.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "规则不存在"})
+		return
+	}
+
+	var req struct {
+		StandardID    *int     `json:"standard_id"`
+		StandardSubID *int     `json:"standard_sub_id"`
+		Field         *string  `json:"field"`
+		Pattern       *string  `json:"pattern"`
+		Confidence    *float64 `json:"confidence"`
+		Priority      *int     `json:"priority"`
+		IsActive      *bool    `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if req.StandardSubID != nil {
+		rule.StandardSubID = *req.StandardSubID
+		if req.StandardID == nil {
+			rule.StandardID = 0 // 按子分类重新确定一级分类
+		}
+	}
+	if req.StandardID != nil {
+		rule.StandardID = *req.StandardID
+	}
+	if req.Field != nil {
+		rule.Field = *req.Field
+	}
+	if req.Pattern != nil {
+		rule.Pattern = *req.Pattern
+	}
+	if req.Confidence != nil {
+		rule.Confidence = *req.Confidence
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	if err := utils.ValidateSubCategoryRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	change := utils.BeginMappingChange(h.db, adminUser(c), "update_subcategory_rule")
+	defer change.End()
+
+	if err := h.db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "修改子分类规则失败: " + err.Error()})
+		return
+	}
+	afterMappingRuleChange(c, h.db, ruleScope{})
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "子分类规则已修改", "data": rule})
+}
+
+// DeleteSubCategoryRule 删除（停用）子分类推断规则
+// DELETE /api/admin/subcategory-rules/:id
+func (h *MappingAdminHandler) DeleteSubCategoryRule(c *gin.Context) {
+	var rule models.SubCategoryRule
+	if err := h.db.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "规则不存在"})
+		return
+	}
+
+	change := utils.BeginMappingChange(h.db, adminUser(c), "delete_subcategory_rule")
+	defer change.End()
+
+	if err := h.db.Model(&rule).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除规则失败: " + err.Error()})
+		return
+	}
+	afterMappingRuleChange(c, h.db, ruleScope{})
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "规则已删除"})
+}
+
 // GetMappingStats 获取映射统计信息
 // GET /api/mapping-stats
 func (h *MappingAdminHandler) GetMappingStats(c *gin.Context) {
@@ -257,9 +422,11 @@ func (h *MappingAdminHandler) PreviewMappingRules(c *gin.Context) {
 
 	type RulePreview struct {
 		models.MappingRule
-		VideoCount      int    `json:"video_count"`       // 使用该规则的视频数量
-		StandardName    string `json:"standard_name"`     // 标准分类名称
-		StandardSubName string `json:"standard_sub_name"` // 标准子分类名称
+		VideoCount      int                    `json:"video_count"`       // 使用该规则的视频数量
+		StandardName    string                 `json:"standard_name"`     // 标准分类名称
+		StandardSubName string                 `json:"standard_sub_name"` // 标准子分类名称
+		Effective       bool                   `json:"effective"`         // 映射引擎当前是否使用该规则
+		Decision        utils.CategoryDecision `json:"decision"`          // 映射引擎对该资源站分类的实际结果
 	}
 
 	query := h.db.Model(&models.MappingRule{})
@@ -284,6 +451,7 @@ func (h *MappingAdminHandler) PreviewMappingRules(c *gin.Context) {
 	}
 
 	// 增强规则信息
+	mapper := utils.NewCategoryMapper(h.db)
 	var previews []RulePreview
 	for _, rule := range rules {
 		preview := RulePreview{MappingRule: rule}
@@ -296,8 +464,9 @@ func (h *MappingAdminHandler) PreviewMappingRules(c *gin.Context) {
 		).Count(&count)
 		preview.VideoCount = int(count)
 
-		// TODO: 获取标准分类名称（从category_mapping.json）
-		// 这里简化处理，实际可以加载配置文件
+		preview.StandardName, preview.StandardSubName = utils.StandardCategoryNames(rule.StandardID, rule.StandardSubID)
+		preview.Decision = mapper.Map(utils.CategoryInput{SourceKey: rule.SourceKey, SourceTypeID: rule.SourceTypeID, TypeName: rule.SourceName})
+		preview.Effective = preview.Decision.RuleID == rule.ID && preview.Decision.Stage == utils.MappingStageExact
 
 		previews = append(previews, preview)
 	}
@@ -343,11 +512,15 @@ func (h *MappingAdminHandler) BatchUpdateMappingRules(c *gin.Context) {
 		return
 	}
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "batch_update")
+	defer change.End()
+
 	result := h.db.Model(&models.MappingRule{}).Where("id IN ?", req.RuleIDs).Updates(updates)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新失败: " + result.Error.Error()})
 		return
 	}
+	afterMappingRuleChange(c, h.db, h.ruleScopes(req.RuleIDs)...)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -375,11 +548,15 @@ func (h *MappingAdminHandler) BatchDeleteMappingRules(c *gin.Context) {
 		return
 	}
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "batch_delete")
+	defer change.End()
+
 	result := h.db.Model(&models.MappingRule{}).Where("id IN ?", req.RuleIDs).Update("is_active", false)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除失败: " + result.Error.Error()})
 		return
 	}
+	afterMappingRuleChange(c, h.db, h.ruleScopes(req.RuleIDs)...)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -412,14 +589,16 @@ func (h *MappingAdminHandler) ReviewUnmappedCategories(c *gin.Context) {
 		return
 	}
 
+	taxonomy := utils.GetTaxonomy()
 	var reviews []UnmappedReview
 	for _, cat := range categories {
 		review := UnmappedReview{UnmappedCategory: cat}
 
 		if cat.SuggestedID != nil {
-			review.SuggestedMapping = "建议映射到分类 " + strconv.Itoa(*cat.SuggestedID)
-			if cat.SuggestedSubID != nil {
-				review.SuggestedMapping += "-" + strconv.Itoa(*cat.SuggestedSubID)
+			name, subName := taxonomy.Names(*cat.SuggestedID, cat.SuggestedSubID)
+			review.SuggestedMapping = "建议映射到 " + name
+			if subName != "" {
+				review.SuggestedMapping += "/" + subName
 			}
 		} else {
 			review.SuggestedMapping = "需要手动指定"
@@ -453,9 +632,13 @@ func (h *MappingAdminHandler) BatchApplyUnmappedCategories(c *gin.Context) {
 		return
 	}
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "batch_apply_unmapped")
+	defer change.End()
+
 	successCount := 0
 	failCount := 0
 	var errors []string
+	var scopes []ruleScope
 
 	for _, mapping := range req.Mappings {
 		var unmapped models.UnmappedCategory
@@ -496,7 +679,9 @@ func (h *MappingAdminHandler) BatchApplyUnmappedCategories(c *gin.Context) {
 		}
 
 		successCount++
+		scopes = append(scopes, ruleScope{unmapped.SourceKey, unmapped.SourceTypeID})
 	}
+	afterMappingRuleChange(c, h.db, scopes...)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -508,3 +693,123 @@ func (h *MappingAdminHandler) BatchApplyUnmappedCategories(c *gin.Context) {
 		},
 	})
 }
+
+// ExplainCategoryMapping 查看映射引擎对某个资源站分类的映射结果和原因
+// GET /api/admin/category-mapping/explain?source_key=snzy&source_type_id=6&type_name=动作片&vod_class=&vod_area=&title=&content=
+// type_name 省略时取该分类下任一视频的分类名称
+func (h *MappingAdminHandler) ExplainCategoryMapping(c *gin.Context) {
+	in := utils.CategoryInput{
+		SourceKey: c.Query("source_key"),
+		TypeName:  c.Query("type_name"),
+		VodClass:  c.Query("vod_class"),
+		VodArea:   c.Query("vod_area"),
+		Title:     c.Query("title"),
+		Content:   c.Query("content"),
+	}
+	in.SourceTypeID, _ = strconv.Atoi(c.Query("source_type_id"))
+	if in.SourceKey == "" && in.TypeName == "" && in.VodClass == "" && in.VodArea == "" && in.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "请至少指定 source_key、type_name、vod_class、vod_area、title 之一"})
+		return
+	}
+	if in.TypeName == "" && in.SourceTypeID != 0 {
+		h.db.Model(&models.Video{}).Where("source_key = ? AND type_id = ?", in.SourceKey, in.SourceTypeID).
+			Limit(1).Pluck("type_name", &in.TypeName)
+	}
+
+	var videoCount int64
+	if in.SourceKey != "" {
+		h.db.Model(&models.Video{}).Where("source_key = ? AND type_id = ?", in.SourceKey, in.SourceTypeID).Count(&videoCount)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"input":       in,
+			"decision":    utils.MapCategory(h.db, in),
+			"video_count": videoCount,
+		},
+	})
+}
+
+// Recategorize 按当前映射规则重新分类已入库的视频
+// POST /api/admin/recategorize
+// Body: {"source_key": "snzy", "source_type_id": 6, "dry_run": true}，均可省略（省略时为全部视频）
+// dry_run 为 true 时同步返回分类变化统计，否则在后台执行（已有任务运行时返回409）
+func (h *MappingAdminHandler) Recategorize(c *gin.Context) {
+	var opts utils.RecategorizeOptions
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+			return
+		}
+	}
+	if opts.SourceTypeID != 0 && opts.SourceKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "指定 source_type_id 时必须同时指定 source_key"})
+		return
+	}
+
+	if opts.DryRun {
+		result, err := utils.RunRecategorize(h.db, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "预览失败: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": fmt.Sprintf("共 %d 个视频，%d 个分类会变化", result.Scanned, result.Changed),
+			"data":    result,
+		})
+		return
+	}
+
+	job, ok := utils.StartRecategorizeJob(h.db, opts, "manual")
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": "已有重新分类任务正在运行", "data": job})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "重新分类任务已启动", "data": job})
+}
+
+// GetRecategorizeStatus 获取重新分类任务状态
+// GET /api/admin/recategorize
+func (h *MappingAdminHandler) GetRecategorizeStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": utils.GetRecategorizeJob()})
+}
+
+// ruleScope 映射规则对应的资源站分类
+type ruleScope struct {
+	sourceKey    string
+	sourceTypeID int
+}
+
+// ruleScopes 获取规则对应的资源站分类
+func (h *MappingAdminHandler) ruleScopes(ruleIDs []uint) []ruleScope {
+	var rules []models.MappingRule
+	h.db.Select("source_key, source_type_id").Where("id IN ?", ruleIDs).Find(&rules)
+	scopes := make([]ruleScope, 0, len(rules))
+	for _, rule := range rules {
+		scopes = append(scopes, ruleScope{rule.SourceKey, rule.SourceTypeID})
+	}
+	return scopes
+}
+
+// afterMappingRuleChange 映射规则变化后同步未映射分类的状态，并按配置重新分类受影响的视频
+// 请求参数 recategorize=1/0 优先于 RECATEGORIZE_ON_RULE_CHANGE 配置
+func afterMappingRuleChange(c *gin.Context, db *gorm.DB, scopes ...ruleScope) {
+	if len(scopes) == 0 {
+		return
+	}
+	opts := utils.RecategorizeOptions{SourceKey: scopes[0].sourceKey, SourceTypeID: scopes[0].sourceTypeID}
+	for _, scope := range scopes[1:] {
+		opts = utils.MergeRecategorizeScope(opts, utils.RecategorizeOptions{SourceKey: scope.sourceKey, SourceTypeID: scope.sourceTypeID})
+	}
+	utils.SyncUnmappedCategories(db, opts.SourceKey)
+
+	enabled := config.AppConfig != nil && config.AppConfig.RecategorizeOnRuleChange
+	if v := c.Query("recategorize"); v != "" {
+		enabled = v == "1" || v == "true"
+	}
+	if enabled {
+		utils.TriggerRecategorize(db, opts)
+	}
+}
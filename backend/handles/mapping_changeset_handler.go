@@ -0,0 +1,176 @@
+package handles
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"vodcms/models"
+	"vodcms/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// adminUser 操作人（X-Admin-User 请求头，未提供时为 admin）
+func adminUser(c *gin.Context) string {
+	if user := c.GetHeader("X-Admin-User"); user != "" {
+		return user
+	}
+	return "admin"
+}
+
+// ListMappingChangesets 获取映射规则变更集
+// GET /api/admin/mapping-changesets?page=1&page_size=20&author=admin&action=batch_delete
+func (h *MappingAdminHandler) ListMappingChangesets(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.MappingChangeset{})
+	if author := c.Query("author"); author != "" {
+		query = query.Where("author = ?", author)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var changesets []models.MappingChangeset
+	if err := query.Omit("changes").Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&changesets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取变更集失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"list":      changesets,
+		},
+	})
+}
+
+// GetMappingChangeset 获取变更集详情（含每条规则修改前后的内容）
+// GET /api/admin/mapping-changesets/:id
+func (h *MappingAdminHandler) GetMappingChangeset(c *gin.Context) {
+	var changeset models.MappingChangeset
+	if err := h.db.First(&changeset, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "变更集不存在"})
+		return
+	}
+	changes, err := utils.ParseMappingChanges(&changeset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+	changeset.Changes = ""
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"changeset": changeset,
+			"changes":   changes,
+		},
+	})
+}
+
+// DiffMappingChangesets 对比两个时间点之间映射规则的净变化
+// GET /api/admin/mapping-changesets/diff?from=12&to=2026-01-02 15:04:05
+// from/to 可以是变更集ID或时间；from 为空表示最初，to 为空表示当前
+func (h *MappingAdminHandler) DiffMappingChangesets(c *gin.Context) {
+	fromID, err := utils.ResolveMappingChangesetPoint(h.db, c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "from 参数错误: " + err.Error()})
+		return
+	}
+	toID, err := utils.ResolveMappingChangesetPoint(h.db, c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "to 参数错误: " + err.Error()})
+		return
+	}
+	if fromID > toID {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "from 不能晚于 to"})
+		return
+	}
+
+	changes, count, err := utils.DiffMappingChangesets(h.db, fromID, toID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "对比失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"from_changeset":  fromID,
+			"to_changeset":    toID,
+			"changeset_count": count,
+			"total":           len(changes),
+			"changes":         changes,
+		},
+	})
+}
+
+// RollbackMappingChangeset 回滚变更集
+// POST /api/admin/mapping-changesets/:id/rollback?force=1&recategorize=1
+// 规则在该变更集之后又被修改过时返回 409 和冲突列表，force=1 强制回滚
+func (h *MappingAdminHandler) RollbackMappingChangeset(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的变更集ID"})
+		return
+	}
+	force := c.Query("force") == "1" || c.Query("force") == "true"
+
+	changeset, changes, conflicts, err := utils.RollbackMappingChangeset(h.db, uint(id), adminUser(c), force)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "变更集不存在"})
+		return
+	case errors.Is(err, utils.ErrChangesetRolledBack):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+		return
+	case errors.Is(err, utils.ErrRollbackConflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": err.Error() + "，确认后可使用 force=1 强制回滚",
+			"data":    gin.H{"conflicts": conflicts},
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "回滚失败: " + err.Error()})
+		return
+	}
+
+	// 规则已是修改前的状态时只记录回滚标记（空变更集），不需要重新分类
+	message := "规则已是修改前的状态，无需回滚"
+	if changeset == nil || changeset.ChangeCount > 0 {
+		scopes := make([]ruleScope, 0, len(changes))
+		for _, change := range changes {
+			scope := change.Scope()
+			scopes = append(scopes, ruleScope{scope.SourceKey, scope.SourceTypeID})
+		}
+		afterMappingRuleChange(c, h.db, scopes...)
+		message = "回滚成功"
+	}
+	if changeset != nil {
+		changeset.Changes = "" // 详情通过 GET /mapping-changesets/:id 查看
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": message,
+		"data": gin.H{
+			"changeset": changeset,
+			"conflicts": conflicts,
+		},
+	})
+}
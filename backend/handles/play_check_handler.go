@@ -0,0 +1,134 @@
+package handles
+
+import (
+	"net/http"
+	"strconv"
+
+	"vodcms/models"
+	"vodcms/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PlayCheckHandler 播放地址检测处理器（检测任务、检测结果、资源站可用性）
+type PlayCheckHandler struct {
+	db *gorm.DB
+}
+
+// NewPlayCheckHandler 创建播放地址检测处理器
+func NewPlayCheckHandler(db *gorm.DB) *PlayCheckHandler {
+	return &PlayCheckHandler{db: db}
+}
+
+// StartPlayCheck 启动检测任务（同一时间只允许一个任务）
+// POST /api/admin/play-checks/run
+// Body: {"source_key": "snzy", "per_source": 200}，均可省略
+func (h *PlayCheckHandler) StartPlayCheck(c *gin.Context) {
+	var opts utils.PlayCheckOptions
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+			return
+		}
+	}
+	if opts.PerSource > 1000 {
+		opts.PerSource = 1000
+	}
+
+	job, ok := utils.StartPlayCheckJob(h.db, opts, "manual")
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": "已有检测任务正在运行", "data": job})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "检测任务已启动", "data": job})
+}
+
+// GetPlayCheckStatus 获取检测任务状态
+// GET /api/admin/play-checks/run
+func (h *PlayCheckHandler) GetPlayCheckStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": utils.GetPlayCheckJob()})
+}
+
+// CheckVideo 立即检测一个视频在所有资源站的播放地址
+// POST /api/admin/play-checks/videos/:vod_id
+func (h *PlayCheckHandler) CheckVideo(c *gin.Context) {
+	vodID, err := strconv.Atoi(c.Param("vod_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的视频ID"})
+		return
+	}
+
+	var videos []models.Video
+	h.db.Where("vod_id = ?", vodID).Find(&videos)
+	if len(videos) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "视频不存在"})
+		return
+	}
+
+	checks := make([]models.PlayCheck, 0)
+	for i := range videos {
+		result, err := utils.CheckVideoPlayGroups(h.db, &videos[i])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "保存检测结果失败: " + err.Error()})
+			return
+		}
+		checks = append(checks, result...)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "检测完成", "data": checks})
+}
+
+// ListPlayChecks 获取检测结果
+// GET /api/admin/play-checks?page=1&page_size=20&source_key=snzy&status=dead&vod_id=123
+func (h *PlayCheckHandler) ListPlayChecks(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.PlayCheck{})
+	if sourceKey := c.Query("source_key"); sourceKey != "" {
+		query = query.Where("source_key = ?", sourceKey)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if vodID := c.Query("vod_id"); vodID != "" {
+		query = query.Where("vod_id = ?", vodID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var checks []models.PlayCheck
+	if err := query.Order("checked_at DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&checks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取检测结果失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"list":      checks,
+		},
+	})
+}
+
+// GetSourceLinkHealth 按资源站汇总播放地址可用性
+// GET /api/admin/play-checks/sources?source_key=snzy
+func (h *PlayCheckHandler) GetSourceLinkHealth(c *gin.Context) {
+	list, err := utils.GetSourceLinkHealth(h.db, c.Query("source_key"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "统计失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": list})
+}
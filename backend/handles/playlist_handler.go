@@ -0,0 +1,215 @@
+package handles
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"vodcms/config"
+	"vodcms/models"
+	"vodcms/utils"
+)
+
+// 播放列表导出（扩展M3U，可直接导入 VLC、IPTV 播放器）
+// 1. 每个视频取合并后的第一个播放组（优先最近采集的资源站），可用 from=播放组名称 指定
+// 2. group-title 为标准分类名称，tvg-logo 为封面
+// 3. 整个分类或全站导出时分批查询并边查边写，不会一次性加载到内存
+
+// playlistBatchSize 流式导出时每批查询的视频数
+const playlistBatchSize = 200
+
+// ExportVideoPlaylist 导出单个视频的播放列表
+// GET /api/playlist/videos/:vod_id?from=m3u8&format=m3u8
+func ExportVideoPlaylist(c *gin.Context) {
+	vodID, err := strconv.Atoi(c.Param("vod_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "vod_id 参数无效",
+		})
+		return
+	}
+
+	var sources []models.Video
+	config.GetDB().Where("vod_id = ?", vodID).Order("collected_at DESC, id DESC").Find(&sources)
+	if len(sources) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "视频不存在",
+		})
+		return
+	}
+
+	w := startPlaylist(c, sources[0].VodName)
+	writeVideoPlaylist(w, sources, c.Query("from"))
+	w.Flush()
+}
+
+// ExportCategoryPlaylist 导出整个标准分类的播放列表
+// GET /api/playlist/categories/:category_id?sub_category_id=201
+func ExportCategoryPlaylist(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Param("category_id"))
+	if err != nil || categoryID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "category_id 参数无效",
+		})
+		return
+	}
+
+	filter, err := parseVideoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	filter.CategoryID = categoryID
+
+	name := fmt.Sprintf("category_%d", categoryID)
+	if categoryName, _ := utils.StandardCategoryNames(categoryID, nil); categoryName != "" {
+		name = categoryName
+	}
+	exportFilteredPlaylist(c, filter, nil, name)
+}
+
+// ExportPlaylist 按列表页的筛选条件导出播放列表，不带条件时导出全站
+// GET /api/playlist?keyword=&category_id=&year_from=&lang=...（参数与 /api/videos 相同）
+func ExportPlaylist(c *gin.Context) {
+	filter, err := parseVideoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	var search *utils.VideoSearch
+	if keyword := c.Query("keyword"); keyword != "" {
+		search = utils.ParseVideoSearch(keyword)
+	}
+	exportFilteredPlaylist(c, filter, search, "vodcms")
+}
+
+// exportFilteredPlaylist 分批查询符合条件的视频并流式写出
+func exportFilteredPlaylist(c *gin.Context, filter *VideoFilter, search *utils.VideoSearch, name string) {
+	db := config.GetDB()
+
+	subQuery := db.Table("videos").
+		Select("MAX(id) as id").
+		Group("vod_id")
+	query := db.Model(&models.Video{}).
+		Where("id IN (?)", subQuery)
+	if search != nil {
+		query = search.Apply(query)
+	}
+	query = filter.Apply(query, "")
+
+	from := c.Query("from")
+	w := startPlaylist(c, name)
+
+	var videos []models.Video
+	err := query.FindInBatches(&videos, playlistBatchSize, func(tx *gorm.DB, batch int) error {
+		vodIDs := make([]int, 0, len(videos))
+		for _, video := range videos {
+			vodIDs = append(vodIDs, video.VodID)
+		}
+		var rows []models.Video
+		if err := db.Where("vod_id IN ?", vodIDs).Order("collected_at DESC, id DESC").Find(&rows).Error; err != nil {
+			return err
+		}
+		sources := make(map[int][]models.Video)
+		for _, row := range rows {
+			sources[row.VodID] = append(sources[row.VodID], row)
+		}
+
+		for _, video := range videos {
+			writeVideoPlaylist(w, sources[video.VodID], from)
+		}
+		if err := w.Flush(); err != nil {
+			return err // 客户端已断开
+		}
+		c.Writer.Flush()
+		return nil
+	}).Error
+	if err != nil {
+		// 响应头已发送，只能在列表末尾注明
+		fmt.Fprintf(w, "# 导出中断: %v\n", err)
+		fmt.Printf("⚠️ 导出播放列表中断: %v\n", err)
+	}
+	w.Flush()
+}
+
+// startPlaylist 写入响应头和 #EXTM3U
+func startPlaylist(c *gin.Context, name string) *bufio.Writer {
+	ext := "m3u8"
+	if c.Query("format") == "m3u" {
+		ext = "m3u"
+	}
+	filename := url.PathEscape(name + "." + ext)
+
+	c.Header("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+filename)
+	c.Status(http.StatusOK)
+
+	w := bufio.NewWriterSize(c.Writer, 32*1024)
+	w.WriteString("#EXTM3U\n")
+	return w
+}
+
+// writeVideoPlaylist 写出一个视频的所有剧集
+func writeVideoPlaylist(w *bufio.Writer, sources []models.Video, from string) {
+	if len(sources) == 0 {
+		return
+	}
+	video := sources[0]
+
+	var groups []utils.PlayGroup
+	for i := range sources {
+		groups = append(groups, utils.VideoPlayGroups(&sources[i])...)
+	}
+	groups = utils.MergePlayGroups(groups)
+	if len(groups) == 0 {
+		return
+	}
+
+	group := groups[0]
+	for _, g := range groups {
+		if from != "" && g.From == from {
+			group = g
+			break
+		}
+	}
+
+	for _, episode := range group.Episodes {
+		title := video.VodName
+		if len(group.Episodes) > 1 {
+			title += " " + episode.Name
+		}
+		fmt.Fprintf(w, "#EXTINF:-1 tvg-id=\"%d\" tvg-name=\"%s\" tvg-logo=\"%s\" group-title=\"%s\",%s\n%s\n",
+			video.VodID,
+			m3uAttr(video.VodName),
+			m3uAttr(video.VodPic),
+			m3uAttr(video.StandardCategoryName),
+			m3uLine(title),
+			m3uLine(episode.URL))
+	}
+}
+
+// m3uAttr 属性值中不能出现双引号和换行
+func m3uAttr(s string) string {
+	return strings.ReplaceAll(m3uLine(s), `"`, "'")
+}
+
+// m3uLine 去掉换行，避免破坏列表结构
+func m3uLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(strings.TrimSpace(s))
+}
@@ -0,0 +1,417 @@
+package handles
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"vodcms/config"
+	"vodcms/models"
+	"vodcms/utils"
+)
+
+// 苹果CMS资源站接口（api.php/provide/vod），让其他苹果CMS站点可以直接从本站采集
+// 1. 视频ID使用 vod_id（与列表页一致，同一 vod_id 的多个资源站合并为一条）
+// 2. 分类使用标准分类：有子分类时 type_id 为子分类ID，type_id_1 为一级分类ID
+// 3. 多个资源站的播放组合并输出，同名播放组只保留剧集最多的一组
+
+const (
+	provideDefaultPageSize = 20
+	provideMaxPageSize     = 100
+	provideTimeLayout      = "2006-01-02 15:04:05"
+)
+
+// provideClass 分类
+type provideClass struct {
+	TypeID   int    `json:"type_id"`
+	TypePID  int    `json:"type_pid"`
+	TypeName string `json:"type_name"`
+}
+
+// provideListItem 列表模式（ac=list）的视频字段
+type provideListItem struct {
+	VodID       int    `json:"vod_id"`
+	VodName     string `json:"vod_name"`
+	TypeID      int    `json:"type_id"`
+	TypeName    string `json:"type_name"`
+	VodEn       string `json:"vod_en"`
+	VodTime     string `json:"vod_time"`
+	VodRemarks  string `json:"vod_remarks"`
+	VodPlayFrom string `json:"vod_play_from"`
+}
+
+// provideDetailItem 详情模式（ac=videolist / detail）的视频字段
+type provideDetailItem struct {
+	provideListItem
+	TypeID1        int     `json:"type_id_1"`
+	VodLetter      string  `json:"vod_letter"`
+	VodClass       string  `json:"vod_class"`
+	VodPic         string  `json:"vod_pic"`
+	VodActor       string  `json:"vod_actor"`
+	VodDirector    string  `json:"vod_director"`
+	VodWriter      string  `json:"vod_writer"`
+	VodBlurb       string  `json:"vod_blurb"`
+	VodPubdate     string  `json:"vod_pubdate"`
+	VodArea        string  `json:"vod_area"`
+	VodLang        string  `json:"vod_lang"`
+	VodYear        string  `json:"vod_year"`
+	VodSerial      string  `json:"vod_serial"`
+	VodState       string  `json:"vod_state"`
+	VodIsEnd       int     `json:"vod_isend"`
+	VodDuration    string  `json:"vod_duration"`
+	VodScore       string  `json:"vod_score"`
+	VodScoreAll    int     `json:"vod_score_all"`
+	VodScoreNum    int     `json:"vod_score_num"`
+	VodDoubanID    int     `json:"vod_douban_id"`
+	VodDoubanScore float64 `json:"vod_douban_score"`
+	VodHits        int     `json:"vod_hits"`
+	VodHitsDay     int     `json:"vod_hits_day"`
+	VodHitsWeek    int     `json:"vod_hits_week"`
+	VodHitsMonth   int     `json:"vod_hits_month"`
+	VodContent     string  `json:"vod_content"`
+	VodPlayServer  string  `json:"vod_play_server"`
+	VodPlayNote    string  `json:"vod_play_note"`
+	VodPlayURL     string  `json:"vod_play_url"`
+	VodDownFrom    string  `json:"vod_down_from"`
+	VodDownServer  string  `json:"vod_down_server"`
+	VodDownNote    string  `json:"vod_down_note"`
+	VodDownURL     string  `json:"vod_down_url"`
+}
+
+// ProvideVod 苹果CMS资源站接口
+// GET /api.php/provide/vod?ac=list|videolist|detail&t=类型ID&pg=页码&h=小时&ids=1,2,3&wd=关键词&at=xml
+func ProvideVod(c *gin.Context) {
+	db := config.GetDB()
+
+	format := strings.ToLower(c.Query("at"))
+	if format == "" {
+		format = strings.ToLower(c.Param("at"))
+	}
+	detail := false
+	switch c.DefaultQuery("ac", "list") {
+	case "videolist", "detail":
+		detail = true
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("pg", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pagesize", strconv.Itoa(provideDefaultPageSize)))
+	if pageSize < 1 || pageSize > provideMaxPageSize {
+		pageSize = provideDefaultPageSize
+	}
+
+	categories, err := utils.LoadStandardCategories()
+	if err != nil {
+		fmt.Printf("⚠️ 读取标准分类失败: %v\n", err)
+	}
+	parentOf := make(map[int]int, len(categories))
+	for _, cat := range categories {
+		parentOf[cat.ID] = cat.ParentID
+	}
+
+	// 与列表页一致，每个vod_id只取最新的一条
+	subQuery := db.Table("videos").
+		Select("MAX(id) as id").
+		Group("vod_id")
+	query := db.Model(&models.Video{}).
+		Where("id IN (?)", subQuery)
+
+	if t, _ := strconv.Atoi(c.Query("t")); t > 0 {
+		parent, ok := parentOf[t]
+		switch {
+		case !ok:
+			query = query.Where("1 = 0")
+		case parent == 0:
+			query = query.Where("standard_category_id = ?", t)
+		default:
+			query = query.Where("standard_sub_category_id = ?", t)
+		}
+	}
+	if ids := c.Query("ids"); ids != "" {
+		var vodIDs []int
+		for _, s := range strings.Split(ids, ",") {
+			if id, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+				vodIDs = append(vodIDs, id)
+			}
+		}
+		query = query.Where("vod_id IN ?", vodIDs)
+	}
+	if h, _ := strconv.Atoi(c.Query("h")); h > 0 {
+		query = query.Where("collected_at >= ?", time.Now().Add(-time.Duration(h)*time.Hour))
+	}
+	if wd := strings.TrimSpace(c.Query("wd")); wd != "" {
+		query = utils.ParseVideoSearch(wd).Apply(query)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		provideError(c, format, err.Error())
+		return
+	}
+
+	var videos []models.Video
+	if err := query.Order("collected_at DESC, videos.id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Find(&videos).Error; err != nil {
+		provideError(c, format, err.Error())
+		return
+	}
+
+	// 读取这些视频在所有资源站的记录，用于合并播放组
+	sources := make(map[int][]models.Video)
+	if len(videos) > 0 {
+		vodIDs := make([]int, 0, len(videos))
+		for _, video := range videos {
+			vodIDs = append(vodIDs, video.VodID)
+		}
+		var rows []models.Video
+		db.Where("vod_id IN ?", vodIDs).Order("collected_at DESC, id DESC").Find(&rows)
+		for _, row := range rows {
+			sources[row.VodID] = append(sources[row.VodID], row)
+		}
+	}
+
+	items := make([]provideDetailItem, 0, len(videos))
+	for i := range videos {
+		items = append(items, buildProvideItem(&videos[i], sources[videos[i].VodID]))
+	}
+
+	pageCount := int((total + int64(pageSize) - 1) / int64(pageSize))
+	var classes []provideClass
+	if !detail {
+		for _, cat := range categories {
+			classes = append(classes, provideClass{TypeID: cat.ID, TypePID: cat.ParentID, TypeName: cat.Name})
+		}
+	}
+
+	if format == "xml" {
+		provideXML(c, detail, page, pageCount, pageSize, total, items, classes)
+		return
+	}
+
+	var list interface{}
+	if detail {
+		list = items
+	} else {
+		listItems := make([]provideListItem, 0, len(items))
+		for _, item := range items {
+			listItems = append(listItems, item.provideListItem)
+		}
+		list = listItems
+	}
+
+	response := gin.H{
+		"code":      1,
+		"msg":       "数据列表",
+		"page":      page,
+		"pagecount": pageCount,
+		"limit":     strconv.Itoa(pageSize),
+		"total":     total,
+		"list":      list,
+	}
+	if !detail {
+		response["class"] = classes
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// buildProvideItem 组装输出字段，播放组和下载组按资源站采集时间合并
+func buildProvideItem(video *models.Video, sources []models.Video) provideDetailItem {
+	if len(sources) == 0 {
+		sources = []models.Video{*video}
+	}
+
+	var playGroups, downGroups []utils.PlayGroup
+	latest := video.CollectedAt
+	for i := range sources {
+		playGroups = append(playGroups, utils.VideoPlayGroups(&sources[i])...)
+		downGroups = append(downGroups, utils.VideoDownGroups(&sources[i])...)
+		if sources[i].CollectedAt.After(latest) {
+			latest = sources[i].CollectedAt
+		}
+	}
+	playFrom, playServer, playNote, playURL := utils.FormatPlayGroups(utils.MergePlayGroups(playGroups))
+	downFrom, downServer, downNote, downURL := utils.FormatPlayGroups(utils.MergePlayGroups(downGroups))
+
+	typeID, typeName, typeID1 := video.StandardCategoryID, video.StandardCategoryName, 0
+	if video.StandardSubCategoryID != nil && *video.StandardSubCategoryID > 0 {
+		typeID, typeName, typeID1 = *video.StandardSubCategoryID, video.StandardSubCategoryName, video.StandardCategoryID
+	}
+
+	return provideDetailItem{
+		provideListItem: provideListItem{
+			VodID:       video.VodID,
+			VodName:     video.VodName,
+			TypeID:      typeID,
+			TypeName:    typeName,
+			VodEn:       video.VodEn,
+			VodTime:     latest.Format(provideTimeLayout),
+			VodRemarks:  video.VodRemarks,
+			VodPlayFrom: playFrom,
+		},
+		TypeID1:        typeID1,
+		VodLetter:      video.VodLetter,
+		VodClass:       video.VodClass,
+		VodPic:         video.VodPic,
+		VodActor:       video.VodActor,
+		VodDirector:    video.VodDirector,
+		VodWriter:      video.VodWriter,
+		VodBlurb:       video.VodBlurb,
+		VodPubdate:     video.VodPubdate,
+		VodArea:        video.VodArea,
+		VodLang:        video.VodLang,
+		VodYear:        video.VodYear,
+		VodSerial:      video.VodSerial,
+		VodState:       video.VodState,
+		VodIsEnd:       video.VodIsEnd,
+		VodDuration:    video.VodDuration,
+		VodScore:       video.VodScore,
+		VodScoreAll:    video.VodScoreAll,
+		VodScoreNum:    video.VodScoreNum,
+		VodDoubanID:    video.VodDoubanID,
+		VodDoubanScore: video.VodDoubanScore,
+		VodHits:        video.VodHits,
+		VodHitsDay:     video.VodHitsDay,
+		VodHitsWeek:    video.VodHitsWeek,
+		VodHitsMonth:   video.VodHitsMonth,
+		VodContent:     video.VodContent,
+		VodPlayServer:  playServer,
+		VodPlayNote:    playNote,
+		VodPlayURL:     playURL,
+		VodDownFrom:    downFrom,
+		VodDownServer:  downServer,
+		VodDownNote:    downNote,
+		VodDownURL:     downURL,
+	}
+}
+
+// provideError 按请求格式返回错误
+func provideError(c *gin.Context, format, msg string) {
+	if format == "xml" {
+		c.XML(http.StatusInternalServerError, gin.H{"code": 0, "msg": msg})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"code": 0, "msg": msg})
+}
+
+// ============ XML格式（at=xml） ============
+
+type xmlCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type provideXMLRSS struct {
+	XMLName xml.Name           `xml:"rss"`
+	Version string             `xml:"version,attr"`
+	List    provideXMLList     `xml:"list"`
+	Class   *provideXMLClasses `xml:"class,omitempty"`
+}
+
+type provideXMLList struct {
+	Page        int               `xml:"page,attr"`
+	PageCount   int               `xml:"pagecount,attr"`
+	PageSize    int               `xml:"pagesize,attr"`
+	RecordCount int64             `xml:"recordcount,attr"`
+	Videos      []provideXMLVideo `xml:"video"`
+}
+
+type provideXMLVideo struct {
+	Last     string        `xml:"last"`
+	ID       int           `xml:"id"`
+	TID      int           `xml:"tid"`
+	Name     xmlCDATA      `xml:"name"`
+	Type     string        `xml:"type"`
+	Dt       string        `xml:"dt,omitempty"`
+	Pic      string        `xml:"pic,omitempty"`
+	Lang     string        `xml:"lang,omitempty"`
+	Area     string        `xml:"area,omitempty"`
+	Year     string        `xml:"year,omitempty"`
+	State    string        `xml:"state,omitempty"`
+	Note     xmlCDATA      `xml:"note"`
+	Actor    *xmlCDATA     `xml:"actor,omitempty"`
+	Director *xmlCDATA     `xml:"director,omitempty"`
+	DL       *provideXMLDL `xml:"dl,omitempty"`
+	Des      *xmlCDATA     `xml:"des,omitempty"`
+}
+
+type provideXMLDL struct {
+	DD []provideXMLDD `xml:"dd"`
+}
+
+type provideXMLDD struct {
+	Flag  string `xml:"flag,attr"`
+	Value string `xml:",cdata"`
+}
+
+type provideXMLClasses struct {
+	Types []provideXMLType `xml:"ty"`
+}
+
+type provideXMLType struct {
+	ID   int    `xml:"id,attr"`
+	Name string `xml:",chardata"`
+}
+
+// provideXML 输出苹果CMS XML格式
+func provideXML(c *gin.Context, detail bool, page, pageCount, pageSize int, total int64, items []provideDetailItem, classes []provideClass) {
+	rss := provideXMLRSS{
+		Version: "5.1",
+		List: provideXMLList{
+			Page:        page,
+			PageCount:   pageCount,
+			PageSize:    pageSize,
+			RecordCount: total,
+		},
+	}
+
+	for _, item := range items {
+		video := provideXMLVideo{
+			Last: item.VodTime,
+			ID:   item.VodID,
+			TID:  item.TypeID,
+			Name: xmlCDATA{item.VodName},
+			Type: item.TypeName,
+			Note: xmlCDATA{item.VodRemarks},
+		}
+		if !detail {
+			video.Dt = item.VodPlayFrom
+		} else {
+			video.Pic = item.VodPic
+			video.Lang = item.VodLang
+			video.Area = item.VodArea
+			video.Year = item.VodYear
+			video.State = item.VodSerial
+			video.Actor = &xmlCDATA{item.VodActor}
+			video.Director = &xmlCDATA{item.VodDirector}
+			video.Des = &xmlCDATA{item.VodContent}
+
+			dl := &provideXMLDL{}
+			for _, group := range utils.ParsePlayGroups(item.VodPlayFrom, "", "", item.VodPlayURL) {
+				dl.DD = append(dl.DD, provideXMLDD{Flag: group.From, Value: utils.FormatEpisodes(group.Episodes)})
+			}
+			video.DL = dl
+		}
+		rss.List.Videos = append(rss.List.Videos, video)
+	}
+
+	if len(classes) > 0 {
+		rss.Class = &provideXMLClasses{}
+		for _, cls := range classes {
+			rss.Class.Types = append(rss.Class.Types, provideXMLType{ID: cls.TypeID, Name: cls.TypeName})
+		}
+	}
+
+	data, err := xml.Marshal(rss)
+	if err != nil {
+		provideError(c, "xml", err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), data...))
+}
@@ -0,0 +1,261 @@
+package handles
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+
+	"vodcms/config"
+	"vodcms/utils"
+)
+
+// 站点地图
+// 1. /sitemap.xml 为索引，按 vod_id 分页，每页最多 50000 个视频
+// 2. /sitemaps/videos-1.xml 为分页，每个视频一条，lastmod 取所有资源站中最近的采集时间
+// 3. 视频地址由 SITE_URL + VIDEO_URL_TEMPLATE 生成
+// 4. 生成结果缓存在内存中，导入或增删视频后失效，最长缓存1小时（见 sitemapCache）
+
+const (
+	sitemapPageSize        = 50000
+	sitemapCacheTTL        = time.Hour
+	sitemapCacheMaxEntries = 32
+)
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// sitemapRow 每个视频一行，LastMod 为 Unix 时间戳
+type sitemapRow struct {
+	VodID     int
+	VodPinyin string
+	LastMod   int64
+}
+
+type sitemapCacheEntry struct {
+	data    []byte
+	version int64
+	builtAt time.Time
+}
+
+// sitemapCache 缓存只在读写时加锁；同一份站点地图同一时间只生成一次（singleflight），不阻塞其他请求
+// 索引与请求地址无关（生成时使用占位符，输出时替换），分页按 SITE_URL 缓存；
+// 未配置 SITE_URL 时分页按请求地址缓存，最多保留 sitemapCacheMaxEntries 份，写入时清除过期版本
+var sitemapCache = struct {
+	sync.Mutex
+	entries map[string]*sitemapCacheEntry
+	group   singleflight.Group
+}{entries: make(map[string]*sitemapCacheEntry)}
+
+// sitemapBasePlaceholder 索引中站点地图地址的占位符
+const sitemapBasePlaceholder = "{sitemap_base}"
+
+// GetSitemapIndex 站点地图索引
+// GET /sitemap.xml
+func GetSitemapIndex(c *gin.Context) {
+	entry, ok := cachedSitemap(c, "index", func() ([]byte, error) {
+		return buildSitemapIndex(config.GetDB(), sitemapBasePlaceholder)
+	})
+	if !ok {
+		return
+	}
+	var base bytes.Buffer
+	xml.EscapeText(&base, []byte(requestBaseURL(c)))
+	writeSitemap(c, entry, bytes.ReplaceAll(entry.data, []byte(sitemapBasePlaceholder), base.Bytes()))
+}
+
+// GetSitemap 站点地图分页
+// GET /sitemaps/videos-1.xml
+func GetSitemap(c *gin.Context) {
+	file := c.Param("file")
+	page, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(file, "videos-"), ".xml"))
+	if err != nil || page <= 0 || !strings.HasPrefix(file, "videos-") || !strings.HasSuffix(file, ".xml") {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "站点地图不存在",
+		})
+		return
+	}
+
+	siteURL := siteBaseURL(c)
+	entry, ok := cachedSitemap(c, fmt.Sprintf("videos-%d|%s", page, siteURL), func() ([]byte, error) {
+		return buildSitemapPage(config.GetDB(), siteURL, page)
+	})
+	if ok {
+		writeSitemap(c, entry, entry.data)
+	}
+}
+
+// cachedSitemap 读取缓存，失效时重新生成；build 返回 nil 表示分页不存在
+// 出错或分页不存在时直接输出错误并返回 false
+func cachedSitemap(c *gin.Context, key string, build func() ([]byte, error)) (*sitemapCacheEntry, bool) {
+	version := utils.CatalogVersion()
+	sitemapCache.Lock()
+	entry := sitemapCache.entries[key]
+	sitemapCache.Unlock()
+
+	if entry == nil || entry.version != version || time.Since(entry.builtAt) > sitemapCacheTTL {
+		result, err, _ := sitemapCache.group.Do(fmt.Sprintf("%s|%d", key, version), func() (interface{}, error) {
+			data, err := build()
+			if err != nil {
+				return nil, err
+			}
+			built := &sitemapCacheEntry{data: data, version: version, builtAt: time.Now()}
+			if data != nil {
+				storeSitemap(key, built) // 不存在的分页不缓存
+			}
+			return built, nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": 500,
+				"msg":  "生成站点地图失败: " + err.Error(),
+			})
+			return nil, false
+		}
+		entry = result.(*sitemapCacheEntry)
+	}
+
+	if entry.data == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "站点地图不存在",
+		})
+		return nil, false
+	}
+	return entry, true
+}
+
+// storeSitemap 写入缓存，清除过期版本；超出上限时淘汰最早生成的
+func storeSitemap(key string, entry *sitemapCacheEntry) {
+	sitemapCache.Lock()
+	defer sitemapCache.Unlock()
+	for k, e := range sitemapCache.entries {
+		if e.version != entry.version {
+			delete(sitemapCache.entries, k)
+		}
+	}
+	for len(sitemapCache.entries) >= sitemapCacheMaxEntries {
+		oldest := ""
+		for k, e := range sitemapCache.entries {
+			if oldest == "" || e.builtAt.Before(sitemapCache.entries[oldest].builtAt) {
+				oldest = k
+			}
+		}
+		delete(sitemapCache.entries, oldest)
+	}
+	sitemapCache.entries[key] = entry
+}
+
+func writeSitemap(c *gin.Context, entry *sitemapCacheEntry, data []byte) {
+	c.Header("Last-Modified", entry.builtAt.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", data)
+}
+
+// sitemapQuery 按 vod_id 去重，lastmod 取最近一次采集
+func sitemapQuery(db *gorm.DB) *gorm.DB {
+	return db.Table("videos").
+		Select("vod_id, MAX(vod_pinyin) AS vod_pinyin, CAST(strftime('%s', MAX(collected_at)) AS INTEGER) AS last_mod").
+		Group("vod_id").
+		Order("vod_id ASC")
+}
+
+// buildSitemapIndex 逐行扫描所有视频，统计分页数和每页的最近更新时间
+func buildSitemapIndex(db *gorm.DB, baseURL string) ([]byte, error) {
+	rows, err := sitemapQuery(db).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lastMods []int64
+	count := 0
+	for rows.Next() {
+		var row sitemapRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return nil, err
+		}
+		page := count / sitemapPageSize
+		if page == len(lastMods) {
+			lastMods = append(lastMods, 0)
+		}
+		if row.LastMod > lastMods[page] {
+			lastMods[page] = row.LastMod
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(lastMods) == 0 {
+		lastMods = append(lastMods, 0) // 没有视频时也输出一页空的站点地图
+	}
+
+	index := sitemapIndex{Sitemaps: make([]sitemapURL, 0, len(lastMods))}
+	for i, lastMod := range lastMods {
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{
+			Loc:     fmt.Sprintf("%s/sitemaps/videos-%d.xml", baseURL, i+1),
+			LastMod: sitemapLastMod(lastMod),
+		})
+	}
+	return marshalSitemap(index)
+}
+
+// buildSitemapPage 生成一页站点地图，超出范围的分页返回 nil
+func buildSitemapPage(db *gorm.DB, siteURL string, page int) ([]byte, error) {
+	var rows []sitemapRow
+	if err := sitemapQuery(db).
+		Offset((page - 1) * sitemapPageSize).
+		Limit(sitemapPageSize).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 && page > 1 {
+		return nil, nil
+	}
+
+	set := sitemapURLSet{URLs: make([]sitemapURL, 0, len(rows))}
+	for _, row := range rows {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     videoPageURL(siteURL, row.VodID, row.VodPinyin),
+			LastMod: sitemapLastMod(row.LastMod),
+		})
+	}
+	return marshalSitemap(set)
+}
+
+func sitemapLastMod(unix int64) string {
+	if unix <= 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+func marshalSitemap(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
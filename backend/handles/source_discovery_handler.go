@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"vodcms/models"
+	"vodcms/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -26,16 +26,19 @@ func NewSourceDiscoveryHandler(db *gorm.DB) *SourceDiscoveryHandler {
 
 // CategoryPreview 分类预览
 type CategoryPreview struct {
-	TypeID           int    `json:"type_id"`
-	TypeName         string `json:"type_name"`
-	Count            int    `json:"count"`
-	Mapped           bool   `json:"mapped"`             // 是否已映射
-	MappedTo         string `json:"mapped_to"`          // 映射到的标准分类（格式：1-101）
-	SuggestedID      *int   `json:"suggested_id"`       // AI建议的标准分类ID
-	SuggestedSubID   *int   `json:"suggested_sub_id"`   // AI建议的子分类ID
-	SuggestedName    string `json:"suggested_name"`     // 建议的分类名称
-	SuggestedSubName string `json:"suggested_sub_name"` // 建议的子分类名称
-	Confidence       string `json:"confidence"`         // 置信度: high/medium/low
+	TypeID           int     `json:"type_id"`
+	TypeName         string  `json:"type_name"`
+	Count            int     `json:"count"`
+	Mapped           bool    `json:"mapped"`             // 是否已映射
+	MappedTo         string  `json:"mapped_to"`          // 映射到的标准分类（格式：1-101）
+	SuggestedID      *int    `json:"suggested_id"`       // AI建议的标准分类ID
+	SuggestedSubID   *int    `json:"suggested_sub_id"`   // AI建议的子分类ID
+	SuggestedName    string  `json:"suggested_name"`     // 建议的分类名称
+	SuggestedSubName string  `json:"suggested_sub_name"` // 建议的子分类名称
+	Confidence       string  `json:"confidence"`         // 置信度: high/medium/low
+	Score            float64 `json:"score"`              // 评分（0-1，有分类器时为校准后的评分）
+	Stage            string  `json:"stage"`              // 映射引擎命中的阶段
+	Explanation      string  `json:"explanation"`        // 映射说明
 }
 
 // DiscoverSourceCategories 发现资源站的分类
@@ -104,28 +107,29 @@ func (h *SourceDiscoveryHandler) DiscoverSourceCategories(c *gin.Context) {
 		}
 	}
 
-	// 检查哪些分类已经有映射规则，并为未映射的提供智能建议
+	// 用映射引擎检查每个分类：命中数据库精确规则的为已映射，其余给出建议
+	mapper := utils.NewCategoryMapper(h.db)
 	for typeID, cat := range categoryMap {
-		var rule models.MappingRule
-		err := h.db.Where("source_key = ? AND source_type_id = ? AND is_active = ?",
-			req.SourceKey, typeID, true).First(&rule).Error
+		decision := mapper.Map(utils.CategoryInput{SourceKey: req.SourceKey, SourceTypeID: typeID, TypeName: cat.TypeName})
+		cat.Stage = decision.Stage
+		cat.Explanation = decision.Explanation
 
-		if err == nil {
+		if decision.Stage == utils.MappingStageExact && decision.RuleID != 0 {
 			// 已有映射规则
 			cat.Mapped = true
-			cat.MappedTo = fmt.Sprintf("%d", rule.StandardID)
-			if rule.StandardSubID != nil {
-				cat.MappedTo += fmt.Sprintf("-%d", *rule.StandardSubID)
+			cat.MappedTo = fmt.Sprintf("%d", decision.StandardID)
+			if decision.StandardSubID != nil {
+				cat.MappedTo += fmt.Sprintf("-%d", *decision.StandardSubID)
 			}
 		} else {
-			// 未映射，提供智能建议
+			// 未映射，提供建议
 			cat.Mapped = false
-			suggestion := h.suggestMapping(cat.TypeName)
-			cat.SuggestedID = suggestion.StandardID
-			cat.SuggestedSubID = suggestion.StandardSubID
-			cat.SuggestedName = suggestion.StandardName
-			cat.SuggestedSubName = suggestion.StandardSubName
-			cat.Confidence = suggestion.Confidence
+			cat.SuggestedID = intPtr(decision.StandardID)
+			cat.SuggestedSubID = decision.StandardSubID
+			cat.SuggestedName = decision.StandardName
+			cat.SuggestedSubName = decision.StandardSubName
+			cat.Confidence = decision.Confidence
+			cat.Score = decision.Score
 		}
 	}
 
@@ -173,6 +177,9 @@ func (h *SourceDiscoveryHandler) QuickMapCategory(c *gin.Context) {
 		return
 	}
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "quick_map")
+	defer change.End()
+
 	// 创建映射规则
 	rule := models.MappingRule{
 		SourceKey:     req.SourceKey,
@@ -201,6 +208,7 @@ func (h *SourceDiscoveryHandler) QuickMapCategory(c *gin.Context) {
 		}
 		rule = existing
 	}
+	afterMappingRuleChange(c, h.db, ruleScope{rule.SourceKey, rule.SourceTypeID})
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -228,9 +236,13 @@ func (h *SourceDiscoveryHandler) BatchQuickMap(c *gin.Context) {
 		return
 	}
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "batch_quick_map")
+	defer change.End()
+
 	successCount := 0
 	failCount := 0
 	var errors []string
+	var scopes []ruleScope
 
 	for _, mapping := range req.Mappings {
 		rule := models.MappingRule{
@@ -261,7 +273,9 @@ func (h *SourceDiscoveryHandler) BatchQuickMap(c *gin.Context) {
 			}
 		}
 		successCount++
+		scopes = append(scopes, ruleScope{req.SourceKey, mapping.SourceTypeID})
 	}
+	afterMappingRuleChange(c, h.db, scopes...)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
@@ -299,150 +313,6 @@ func (h *SourceDiscoveryHandler) GetSourceMappingStatus(c *gin.Context) {
 	})
 }
 
-// MappingSuggestion 映射建议结构
-type MappingSuggestion struct {
-	StandardID      *int
-	StandardSubID   *int
-	StandardName    string
-	StandardSubName string
-	Confidence      string // high/medium/low
-}
-
-// suggestMapping 智能映射建议
-// 基于分类名称提供映射建议
-func (h *SourceDiscoveryHandler) suggestMapping(typeName string) MappingSuggestion {
-	typeName = strings.TrimSpace(typeName)
-	lowerName := strings.ToLower(typeName)
-
-	// 高置信度匹配规则（精确匹配）
-	highConfidenceRules := map[string]MappingSuggestion{
-		// 电影
-		"电影":   {StandardID: intPtr(1), StandardSubID: nil, StandardName: "电影", Confidence: "high"},
-		"电影片":  {StandardID: intPtr(1), StandardSubID: nil, StandardName: "电影", Confidence: "high"},
-		"动作片":  {StandardID: intPtr(1), StandardSubID: intPtr(101), StandardName: "电影", StandardSubName: "动作片", Confidence: "high"},
-		"喜剧片":  {StandardID: intPtr(1), StandardSubID: intPtr(102), StandardName: "电影", StandardSubName: "喜剧片", Confidence: "high"},
-		"爱情片":  {StandardID: intPtr(1), StandardSubID: intPtr(103), StandardName: "电影", StandardSubName: "爱情片", Confidence: "high"},
-		"科幻片":  {StandardID: intPtr(1), StandardSubID: intPtr(104), StandardName: "电影", StandardSubName: "科幻片", Confidence: "high"},
-		"恐怖片":  {StandardID: intPtr(1), StandardSubID: intPtr(105), StandardName: "电影", StandardSubName: "恐怖片", Confidence: "high"},
-		"剧情片":  {StandardID: intPtr(1), StandardSubID: intPtr(106), StandardName: "电影", StandardSubName: "剧情片", Confidence: "high"},
-		"战争片":  {StandardID: intPtr(1), StandardSubID: intPtr(107), StandardName: "电影", StandardSubName: "战争片", Confidence: "high"},
-		"悬疑片":  {StandardID: intPtr(1), StandardSubID: intPtr(108), StandardName: "电影", StandardSubName: "悬疑片", Confidence: "high"},
-		"犯罪片":  {StandardID: intPtr(1), StandardSubID: intPtr(109), StandardName: "电影", StandardSubName: "犯罪片", Confidence: "high"},
-		"奇幻片":  {StandardID: intPtr(1), StandardSubID: intPtr(110), StandardName: "电影", StandardSubName: "奇幻片", Confidence: "high"},
-		"灾难片":  {StandardID: intPtr(1), StandardSubID: intPtr(111), StandardName: "电影", StandardSubName: "灾难片", Confidence: "high"},
-		"伦理片":  {StandardID: intPtr(1), StandardSubID: intPtr(112), StandardName: "电影", StandardSubName: "伦理片", Confidence: "high"},
-		"伦理":   {StandardID: intPtr(1), StandardSubID: intPtr(112), StandardName: "电影", StandardSubName: "伦理片", Confidence: "high"},
-		"4k电影": {StandardID: intPtr(1), StandardSubID: intPtr(113), StandardName: "电影", StandardSubName: "4K电影", Confidence: "high"},
-
-		// 电视剧
-		"电视剧": {StandardID: intPtr(2), StandardSubID: nil, StandardName: "电视剧", Confidence: "high"},
-		"连续剧": {StandardID: intPtr(2), StandardSubID: nil, StandardName: "电视剧", Confidence: "high"},
-		"国产剧": {StandardID: intPtr(2), StandardSubID: intPtr(201), StandardName: "电视剧", StandardSubName: "国产剧", Confidence: "high"},
-		"大陆剧": {StandardID: intPtr(2), StandardSubID: intPtr(201), StandardName: "电视剧", StandardSubName: "国产剧", Confidence: "high"},
-		"内地剧": {StandardID: intPtr(2), StandardSubID: intPtr(201), StandardName: "电视剧", StandardSubName: "国产剧", Confidence: "high"},
-		"港澳剧": {StandardID: intPtr(2), StandardSubID: intPtr(202), StandardName: "电视剧", StandardSubName: "港澳剧", Confidence: "high"},
-		"香港剧": {StandardID: intPtr(2), StandardSubID: intPtr(202), StandardName: "电视剧", StandardSubName: "港澳剧", Confidence: "high"},
-		"港剧":  {StandardID: intPtr(2), StandardSubID: intPtr(202), StandardName: "电视剧", StandardSubName: "港澳剧", Confidence: "high"},
-		"台湾剧": {StandardID: intPtr(2), StandardSubID: intPtr(203), StandardName: "电视剧", StandardSubName: "台湾剧", Confidence: "high"},
-		"台剧":  {StandardID: intPtr(2), StandardSubID: intPtr(203), StandardName: "电视剧", StandardSubName: "台湾剧", Confidence: "high"},
-		"欧美剧": {StandardID: intPtr(2), StandardSubID: intPtr(204), StandardName: "电视剧", StandardSubName: "欧美剧", Confidence: "high"},
-		"美剧":  {StandardID: intPtr(2), StandardSubID: intPtr(204), StandardName: "电视剧", StandardSubName: "欧美剧", Confidence: "high"},
-		"韩剧":  {StandardID: intPtr(2), StandardSubID: intPtr(205), StandardName: "电视剧", StandardSubName: "韩剧", Confidence: "high"},
-		"韩国剧": {StandardID: intPtr(2), StandardSubID: intPtr(205), StandardName: "电视剧", StandardSubName: "韩剧", Confidence: "high"},
-		"日剧":  {StandardID: intPtr(2), StandardSubID: intPtr(206), StandardName: "电视剧", StandardSubName: "日剧", Confidence: "high"},
-		"日本剧": {StandardID: intPtr(2), StandardSubID: intPtr(206), StandardName: "电视剧", StandardSubName: "日剧", Confidence: "high"},
-		"泰剧":  {StandardID: intPtr(2), StandardSubID: intPtr(207), StandardName: "电视剧", StandardSubName: "泰剧", Confidence: "high"},
-		"马泰剧": {StandardID: intPtr(2), StandardSubID: intPtr(207), StandardName: "电视剧", StandardSubName: "泰剧", Confidence: "high"},
-		"海外剧": {StandardID: intPtr(2), StandardSubID: intPtr(208), StandardName: "电视剧", StandardSubName: "海外剧", Confidence: "high"},
-
-		// 综艺
-		"综艺":   {StandardID: intPtr(3), StandardSubID: nil, StandardName: "综艺", Confidence: "high"},
-		"大陆综艺": {StandardID: intPtr(3), StandardSubID: intPtr(301), StandardName: "综艺", StandardSubName: "大陆综艺", Confidence: "high"},
-		"港台综艺": {StandardID: intPtr(3), StandardSubID: intPtr(302), StandardName: "综艺", StandardSubName: "港台综艺", Confidence: "high"},
-		"日韩综艺": {StandardID: intPtr(3), StandardSubID: intPtr(303), StandardName: "综艺", StandardSubName: "日韩综艺", Confidence: "high"},
-		"欧美综艺": {StandardID: intPtr(3), StandardSubID: intPtr(304), StandardName: "综艺", StandardSubName: "欧美综艺", Confidence: "high"},
-
-		// 动漫
-		"动漫":   {StandardID: intPtr(4), StandardSubID: nil, StandardName: "动漫", Confidence: "high"},
-		"动画":   {StandardID: intPtr(4), StandardSubID: nil, StandardName: "动漫", Confidence: "high"},
-		"国产动漫": {StandardID: intPtr(4), StandardSubID: intPtr(401), StandardName: "动漫", StandardSubName: "国产动漫", Confidence: "high"},
-		"中国动漫": {StandardID: intPtr(4), StandardSubID: intPtr(401), StandardName: "动漫", StandardSubName: "国产动漫", Confidence: "high"},
-		"日韩动漫": {StandardID: intPtr(4), StandardSubID: intPtr(402), StandardName: "动漫", StandardSubName: "日韩动漫", Confidence: "high"},
-		"日本动漫": {StandardID: intPtr(4), StandardSubID: intPtr(402), StandardName: "动漫", StandardSubName: "日韩动漫", Confidence: "high"},
-		"欧美动漫": {StandardID: intPtr(4), StandardSubID: intPtr(403), StandardName: "动漫", StandardSubName: "欧美动漫", Confidence: "high"},
-		"港台动漫": {StandardID: intPtr(4), StandardSubID: intPtr(404), StandardName: "动漫", StandardSubName: "港台动漫", Confidence: "high"},
-		"动画片":  {StandardID: intPtr(4), StandardSubID: intPtr(405), StandardName: "动漫", StandardSubName: "动画片", Confidence: "high"},
-		"动漫电影": {StandardID: intPtr(4), StandardSubID: nil, StandardName: "动漫", Confidence: "high"},
-
-		// 纪录片
-		"纪录片": {StandardID: intPtr(5), StandardSubID: nil, StandardName: "纪录片", Confidence: "high"},
-		"记录片": {StandardID: intPtr(5), StandardSubID: nil, StandardName: "纪录片", Confidence: "high"},
-
-		// 短剧
-		"短剧":   {StandardID: intPtr(6), StandardSubID: nil, StandardName: "短剧", Confidence: "high"},
-		"爽文短剧": {StandardID: intPtr(6), StandardSubID: intPtr(601), StandardName: "短剧", StandardSubName: "爽文短剧", Confidence: "high"},
-		"女频恋爱": {StandardID: intPtr(6), StandardSubID: intPtr(602), StandardName: "短剧", StandardSubName: "女频恋爱", Confidence: "high"},
-		"反转爽剧": {StandardID: intPtr(6), StandardSubID: intPtr(603), StandardName: "短剧", StandardSubName: "反转爽剧", Confidence: "high"},
-		"古装仙侠": {StandardID: intPtr(6), StandardSubID: intPtr(604), StandardName: "短剧", StandardSubName: "古装仙侠", Confidence: "high"},
-		"年代穿越": {StandardID: intPtr(6), StandardSubID: intPtr(605), StandardName: "短剧", StandardSubName: "年代穿越", Confidence: "high"},
-		"脑洞悬疑": {StandardID: intPtr(6), StandardSubID: intPtr(606), StandardName: "短剧", StandardSubName: "脑洞悬疑", Confidence: "high"},
-		"现代都市": {StandardID: intPtr(6), StandardSubID: intPtr(607), StandardName: "短剧", StandardSubName: "现代都市", Confidence: "high"},
-	}
-
-	// 精确匹配
-	if suggestion, ok := highConfidenceRules[typeName]; ok {
-		return suggestion
-	}
-
-	// 中等置信度匹配（包含关键词）
-	mediumConfidenceRules := []struct {
-		keywords   []string
-		suggestion MappingSuggestion
-	}{
-		{[]string{"动作", "武侠", "功夫"}, MappingSuggestion{intPtr(1), intPtr(101), "电影", "动作片", "medium"}},
-		{[]string{"喜剧", "搞笑", "comedy"}, MappingSuggestion{intPtr(1), intPtr(102), "电影", "喜剧片", "medium"}},
-		{[]string{"爱情", "浪漫", "言情"}, MappingSuggestion{intPtr(1), intPtr(103), "电影", "爱情片", "medium"}},
-		{[]string{"科幻", "魔幻", "sci-fi"}, MappingSuggestion{intPtr(1), intPtr(104), "电影", "科幻片", "medium"}},
-		{[]string{"恐怖", "惊悚", "鬼片", "horror"}, MappingSuggestion{intPtr(1), intPtr(105), "电影", "恐怖片", "medium"}},
-		{[]string{"剧情", "文艺", "drama"}, MappingSuggestion{intPtr(1), intPtr(106), "电影", "剧情片", "medium"}},
-		{[]string{"战争", "军事"}, MappingSuggestion{intPtr(1), intPtr(107), "电影", "战争片", "medium"}},
-		{[]string{"悬疑", "推理", "mystery"}, MappingSuggestion{intPtr(1), intPtr(108), "电影", "悬疑片", "medium"}},
-		{[]string{"movie", "film"}, MappingSuggestion{intPtr(1), nil, "电影", "", "medium"}},
-
-		{[]string{"国产", "大陆", "内地", "chinese"}, MappingSuggestion{intPtr(2), intPtr(201), "电视剧", "国产剧", "medium"}},
-		{[]string{"香港", "hk", "tvb"}, MappingSuggestion{intPtr(2), intPtr(202), "电视剧", "港澳剧", "medium"}},
-		{[]string{"韩国", "korea", "korean"}, MappingSuggestion{intPtr(2), intPtr(205), "电视剧", "韩剧", "medium"}},
-		{[]string{"日本", "japan", "japanese"}, MappingSuggestion{intPtr(2), intPtr(206), "电视剧", "日剧", "medium"}},
-		{[]string{"欧美", "美国", "英国", "american", "usa"}, MappingSuggestion{intPtr(2), intPtr(204), "电视剧", "欧美剧", "medium"}},
-		{[]string{"tv", "series", "剧集"}, MappingSuggestion{intPtr(2), nil, "电视剧", "", "medium"}},
-
-		{[]string{"综艺", "真人秀", "variety"}, MappingSuggestion{intPtr(3), nil, "综艺", "", "medium"}},
-
-		{[]string{"动漫", "动画", "anime", "cartoon"}, MappingSuggestion{intPtr(4), nil, "动漫", "", "medium"}},
-
-		{[]string{"纪录", "记录", "documentary"}, MappingSuggestion{intPtr(5), nil, "纪录片", "", "medium"}},
-
-		{[]string{"短剧", "微电影", "微剧"}, MappingSuggestion{intPtr(6), nil, "短剧", "", "medium"}},
-	}
-
-	for _, rule := range mediumConfidenceRules {
-		for _, keyword := range rule.keywords {
-			if strings.Contains(lowerName, keyword) {
-				return rule.suggestion
-			}
-		}
-	}
-
-	// 低置信度 - 默认分类
-	return MappingSuggestion{
-		StandardID:      intPtr(99),
-		StandardSubID:   nil,
-		StandardName:    "其他",
-		StandardSubName: "",
-		Confidence:      "low",
-	}
-}
-
 // intPtr 辅助函数：创建int指针
 func intPtr(i int) *int {
 	return &i
@@ -450,12 +320,13 @@ func intPtr(i int) *int {
 
 // AutoApplySuggestedMappings 自动应用建议的映射
 // POST /api/source/auto-map
-// Body: {"source_key": "newzy", "api_url": "http://xxx.com/api.php/provide/vod/", "confidence_threshold": "medium"}
+// Body: {"source_key": "newzy", "api_url": "http://xxx.com/api.php/provide/vod/", "confidence_threshold": "medium", "min_score": 0.9}
 func (h *SourceDiscoveryHandler) AutoApplySuggestedMappings(c *gin.Context) {
 	var req struct {
-		SourceKey           string `json:"source_key" binding:"required"`
-		APIURL              string `json:"api_url" binding:"required"`
-		ConfidenceThreshold string `json:"confidence_threshold"` // high/medium/low，默认 medium
+		SourceKey           string  `json:"source_key" binding:"required"`
+		APIURL              string  `json:"api_url" binding:"required"`
+		ConfidenceThreshold string  `json:"confidence_threshold"` // high/medium/low，默认 medium
+		MinScore            float64 `json:"min_score"`            // 最低评分（0-1），设置后代替 confidence_threshold
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -494,10 +365,14 @@ func (h *SourceDiscoveryHandler) AutoApplySuggestedMappings(c *gin.Context) {
 		return
 	}
 
+	change := utils.BeginMappingChange(h.db, adminUser(c), "auto_map")
+	defer change.End()
+
 	createdCount := 0
 	skippedCount := 0
 	lowConfidenceCount := 0
 	var createdRules []models.MappingRule
+	mapper := utils.NewCategoryMapper(h.db)
 
 	for _, class := range apiResp.Class {
 		// 检查是否已存在映射
@@ -511,17 +386,21 @@ func (h *SourceDiscoveryHandler) AutoApplySuggestedMappings(c *gin.Context) {
 		}
 
 		// 获取映射建议
-		suggestion := h.suggestMapping(class.TypeName)
+		suggestion := mapper.Map(utils.CategoryInput{SourceKey: req.SourceKey, SourceTypeID: class.TypeID, TypeName: class.TypeName})
 
 		// 根据置信度阈值决定是否创建
 		shouldCreate := false
-		switch req.ConfidenceThreshold {
-		case "high":
-			shouldCreate = suggestion.Confidence == "high"
-		case "medium":
-			shouldCreate = suggestion.Confidence == "high" || suggestion.Confidence == "medium"
-		case "low":
-			shouldCreate = true
+		if req.MinScore > 0 {
+			shouldCreate = suggestion.Score >= req.MinScore
+		} else {
+			switch req.ConfidenceThreshold {
+			case "high":
+				shouldCreate = suggestion.Confidence == "high"
+			case "medium":
+				shouldCreate = suggestion.Confidence == "high" || suggestion.Confidence == "medium"
+			case "low":
+				shouldCreate = true
+			}
 		}
 
 		if !shouldCreate {
@@ -530,26 +409,27 @@ func (h *SourceDiscoveryHandler) AutoApplySuggestedMappings(c *gin.Context) {
 		}
 
 		// 创建映射规则
-		if suggestion.StandardID != nil {
-			rule := models.MappingRule{
-				SourceKey:     req.SourceKey,
-				SourceTypeID:  class.TypeID,
-				SourceName:    class.TypeName,
-				StandardID:    *suggestion.StandardID,
-				StandardSubID: suggestion.StandardSubID,
-				Priority:      100,
-				MatchType:     "exact",
-				IsActive:      true,
-			}
-
-			if err := h.db.Create(&rule).Error; err != nil {
-				fmt.Printf("创建映射失败: %v\n", err)
-				continue
-			}
+		rule := models.MappingRule{
+			SourceKey:     req.SourceKey,
+			SourceTypeID:  class.TypeID,
+			SourceName:    class.TypeName,
+			StandardID:    suggestion.StandardID,
+			StandardSubID: suggestion.StandardSubID,
+			Priority:      100,
+			MatchType:     "exact",
+			IsActive:      true,
+		}
 
-			createdRules = append(createdRules, rule)
-			createdCount++
+		if err := h.db.Create(&rule).Error; err != nil {
+			fmt.Printf("创建映射失败: %v\n", err)
+			continue
 		}
+
+		createdRules = append(createdRules, rule)
+		createdCount++
+	}
+	if createdCount > 0 {
+		afterMappingRuleChange(c, h.db, ruleScope{sourceKey: req.SourceKey})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -560,6 +440,7 @@ func (h *SourceDiscoveryHandler) AutoApplySuggestedMappings(c *gin.Context) {
 			"skipped_count":        skippedCount,
 			"low_confidence_count": lowConfidenceCount,
 			"confidence_threshold": req.ConfidenceThreshold,
+			"min_score":            req.MinScore,
 			"created_rules":        createdRules,
 		},
 		"message": fmt.Sprintf("自动映射完成：创建 %d 个，跳过 %d 个，低置信度 %d 个", createdCount, skippedCount, lowConfidenceCount),
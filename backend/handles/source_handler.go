@@ -43,6 +43,13 @@ func CreateSource(c *gin.Context) {
 		})
 		return
 	}
+	if source.Priority < 0 || source.Priority > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "priority 取值范围为 0-100",
+		})
+		return
+	}
 
 	result := db.Create(&source)
 	if result.Error != nil {
@@ -72,6 +79,13 @@ func UpdateSource(c *gin.Context) {
 		})
 		return
 	}
+	if source.Priority < 0 || source.Priority > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "priority 取值范围为 0-100",
+		})
+		return
+	}
 
 	result := db.Save(&source)
 	if result.Error != nil {
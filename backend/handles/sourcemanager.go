@@ -9,10 +9,11 @@ import (
 
 // 数据源配置
 type Source struct {
-	Name    string `json:"name"`     // 源名称
-	BaseURL string `json:"base_url"` // API地址
-	Key     string `json:"key"`      // 源标识 (用于文件名)
-	Enabled bool   `json:"enabled"`  // 是否启用
+	Name    string `json:"name"`              // 源名称
+	BaseURL string `json:"base_url"`          // API地址
+	Key     string `json:"key"`               // 源标识 (用于文件名)
+	Enabled bool   `json:"enabled"`           // 是否启用
+	Quality string `json:"quality,omitempty"` // 默认画质（未识别到分辨率时使用）
 }
 
 // 源管理器
@@ -37,6 +38,7 @@ func (sm *SourceManager) GetDefaultSources() []Source {
 			BaseURL: "https://hhzyapi.com/api.php/provide/vod/from/hhm3u8/at/json",
 			Key:     "hhzy",
 			Enabled: true,
+			Quality: "标清",
 		},
 		{
 			Name:    "光速资源",
@@ -49,6 +51,7 @@ func (sm *SourceManager) GetDefaultSources() []Source {
 			BaseURL: "https://suoniapi.com/api.php/provide/vod/from/snm3u8/at/json",
 			Key:     "snzy",
 			Enabled: true,
+			Quality: "高清",
 		},
 		{
 			Name:    "红牛资源",
@@ -0,0 +1,25 @@
+package handles
+
+import "testing"
+
+// 配置文件中的默认画质与内置默认数据源一致
+func TestLoadSourcesQuality(t *testing.T) {
+	sm := NewSourceManager("../sources_config.json")
+	if err := sm.LoadSources(); err != nil {
+		t.Fatalf("加载数据源配置失败: %v", err)
+	}
+	loaded := make(map[string]Source)
+	for _, s := range sm.GetAllSources() {
+		loaded[s.Key] = s
+	}
+	for _, want := range sm.GetDefaultSources() {
+		got, ok := loaded[want.Key]
+		if !ok {
+			t.Errorf("配置文件缺少数据源 %s", want.Key)
+			continue
+		}
+		if got.Quality != want.Quality {
+			t.Errorf("%s: quality = %q，期望 %q", want.Key, got.Quality, want.Quality)
+		}
+	}
+}
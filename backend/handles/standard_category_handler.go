@@ -0,0 +1,465 @@
+package handles
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"vodcms/models"
+	"vodcms/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// StandardCategoryHandler 标准分类管理处理器（分类、子分类的增删改查、排序、导入导出）
+type StandardCategoryHandler struct {
+	db *gorm.DB
+}
+
+// NewStandardCategoryHandler 创建标准分类管理处理器
+func NewStandardCategoryHandler(db *gorm.DB) *StandardCategoryHandler {
+	return &StandardCategoryHandler{db: db}
+}
+
+// standardCategoryRequest 创建/更新分类的请求（更新时只修改传入的字段）
+type standardCategoryRequest struct {
+	ID        int     `json:"id"`
+	Name      *string `json:"name"`
+	SortOrder *int    `json:"sort_order"`
+	IsVisible *bool   `json:"is_visible"`
+	Icon      *string `json:"icon"`
+}
+
+// ListStandardCategories 获取全部标准分类（包含隐藏的分类和各分类的视频数）
+// GET /api/admin/standard-categories
+func (h *StandardCategoryHandler) ListStandardCategories(c *gin.Context) {
+	var counts []struct {
+		ID    int
+		Count int64
+	}
+	h.db.Model(&models.Video{}).Select("standard_category_id AS id, COUNT(*) AS count").Group("standard_category_id").Scan(&counts)
+	var subCounts []struct {
+		ID    int
+		Count int64
+	}
+	h.db.Model(&models.Video{}).Select("standard_sub_category_id AS id, COUNT(*) AS count").
+		Where("standard_sub_category_id IS NOT NULL").Group("standard_sub_category_id").Scan(&subCounts)
+
+	videoCounts := make(map[int]int64)
+	for _, row := range counts {
+		videoCounts[row.ID] = row.Count
+	}
+	subVideoCounts := make(map[int]int64)
+	for _, row := range subCounts {
+		subVideoCounts[row.ID] = row.Count
+	}
+
+	type subItem struct {
+		models.StandardSubCategory
+		VideoCount int64 `json:"video_count"`
+	}
+	type categoryItem struct {
+		models.StandardCategory
+		VideoCount    int64     `json:"video_count"`
+		Subcategories []subItem `json:"subcategories"`
+	}
+
+	categories := utils.GetTaxonomy().Categories
+	list := make([]categoryItem, 0, len(categories))
+	for _, cat := range categories {
+		item := categoryItem{StandardCategory: cat.StandardCategory, VideoCount: videoCounts[cat.ID], Subcategories: make([]subItem, 0, len(cat.Subcategories))}
+		for _, sub := range cat.Subcategories {
+			item.Subcategories = append(item.Subcategories, subItem{StandardSubCategory: sub, VideoCount: subVideoCounts[sub.ID]})
+		}
+		list = append(list, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total":       len(list),
+			"list":        list,
+			"other_id":    utils.OtherCategoryID,
+			"other_count": videoCounts[utils.OtherCategoryID],
+		},
+	})
+}
+
+// CreateStandardCategory 创建一级分类
+// POST /api/admin/standard-categories
+// Body: {"id": 7, "name": "体育", "sort_order": 7, "is_visible": true, "icon": "sports"}
+func (h *StandardCategoryHandler) CreateStandardCategory(c *gin.Context) {
+	var req standardCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if err := h.checkNewCategoryID(req.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	cat := models.StandardCategory{ID: req.ID, SortOrder: req.ID, IsVisible: true}
+	if err := applyStandardCategoryRequest(&cat.Name, &cat.SortOrder, &cat.IsVisible, &cat.Icon, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if err := utils.UpsertStandardCategory(h.db, &cat); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "创建分类失败: " + err.Error()})
+		return
+	}
+	h.reload()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "分类创建成功", "data": cat})
+}
+
+// UpdateStandardCategory 更新一级分类（改名时同步更新视频中的分类名称）
+// PUT /api/admin/standard-categories/:id
+func (h *StandardCategoryHandler) UpdateStandardCategory(c *gin.Context) {
+	var cat models.StandardCategory
+	if !h.loadRow(c, &cat, "分类不存在") {
+		return
+	}
+	var req standardCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	oldName := cat.Name
+	if err := applyStandardCategoryRequest(&cat.Name, &cat.SortOrder, &cat.IsVisible, &cat.Icon, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	var renamed int64
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&cat).Error; err != nil {
+			return err
+		}
+		if cat.Name == oldName {
+			return nil
+		}
+		result := tx.Model(&models.Video{}).Where("standard_category_id = ?", cat.ID).Update("standard_category_name", cat.Name)
+		renamed = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新分类失败: " + err.Error()})
+		return
+	}
+	h.reload()
+	if renamed > 0 {
+		utils.TouchCatalog()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "分类已更新", "data": gin.H{"category": cat, "renamed_videos": renamed}})
+}
+
+// DeleteStandardCategory 删除一级分类及其子分类（仍有视频或映射规则使用时不允许删除）
+// DELETE /api/admin/standard-categories/:id
+func (h *StandardCategoryHandler) DeleteStandardCategory(c *gin.Context) {
+	var cat models.StandardCategory
+	if !h.loadRow(c, &cat, "分类不存在") {
+		return
+	}
+	if !h.checkUnused(c, "standard_category_id = ?", "standard_id = ?", cat.ID) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("category_id = ?", cat.ID).Delete(&models.StandardSubCategory{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&cat).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除分类失败: " + err.Error()})
+		return
+	}
+	h.reload()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "分类已删除"})
+}
+
+// CreateStandardSubCategory 创建子分类
+// POST /api/admin/standard-categories/:id/subcategories
+// Body: {"id": 116, "name": "动画电影", "sort_order": 116}
+func (h *StandardCategoryHandler) CreateStandardSubCategory(c *gin.Context) {
+	var cat models.StandardCategory
+	if !h.loadRow(c, &cat, "分类不存在") {
+		return
+	}
+	var req standardCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if err := h.checkNewSubCategoryID(req.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	sub := models.StandardSubCategory{ID: req.ID, CategoryID: cat.ID, SortOrder: req.ID, IsVisible: true}
+	if err := applyStandardCategoryRequest(&sub.Name, &sub.SortOrder, &sub.IsVisible, &sub.Icon, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if err := utils.UpsertStandardSubCategory(h.db, &sub); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "创建子分类失败: " + err.Error()})
+		return
+	}
+	h.reload()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "子分类创建成功", "data": sub})
+}
+
+// UpdateStandardSubCategory 更新子分类（改名时同步更新视频中的子分类名称）
+// PUT /api/admin/standard-subcategories/:id
+func (h *StandardCategoryHandler) UpdateStandardSubCategory(c *gin.Context) {
+	var sub models.StandardSubCategory
+	if !h.loadRow(c, &sub, "子分类不存在") {
+		return
+	}
+	var req standardCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	oldName := sub.Name
+	if err := applyStandardCategoryRequest(&sub.Name, &sub.SortOrder, &sub.IsVisible, &sub.Icon, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	var renamed int64
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&sub).Error; err != nil {
+			return err
+		}
+		if sub.Name == oldName {
+			return nil
+		}
+		result := tx.Model(&models.Video{}).Where("standard_sub_category_id = ?", sub.ID).Update("standard_sub_category_name", sub.Name)
+		renamed = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新子分类失败: " + err.Error()})
+		return
+	}
+	h.reload()
+	if renamed > 0 {
+		utils.TouchCatalog()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "子分类已更新", "data": gin.H{"subcategory": sub, "renamed_videos": renamed}})
+}
+
+// DeleteStandardSubCategory 删除子分类（仍有视频或映射规则使用时不允许删除）
+// DELETE /api/admin/standard-subcategories/:id
+func (h *StandardCategoryHandler) DeleteStandardSubCategory(c *gin.Context) {
+	var sub models.StandardSubCategory
+	if !h.loadRow(c, &sub, "子分类不存在") {
+		return
+	}
+	if !h.checkUnused(c, "standard_sub_category_id = ?", "standard_sub_id = ?", sub.ID) {
+		return
+	}
+	if err := h.db.Delete(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除子分类失败: " + err.Error()})
+		return
+	}
+	h.reload()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "子分类已删除"})
+}
+
+// ReorderStandardCategories 按给定顺序重排分类或某个分类下的子分类（sort_order 依次为 1, 2, 3...）
+// POST /api/admin/standard-categories/reorder
+// Body: {"ids": [2, 1, 4, 3]} 或 {"category_id": 1, "ids": [102, 101]}
+func (h *StandardCategoryHandler) ReorderStandardCategories(c *gin.Context) {
+	var req struct {
+		CategoryID int   `json:"category_id"` // 为0时重排一级分类
+		IDs        []int `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range req.IDs {
+			var result *gorm.DB
+			if req.CategoryID == 0 {
+				result = tx.Model(&models.StandardCategory{}).Where("id = ?", id).Update("sort_order", i+1)
+			} else {
+				result = tx.Model(&models.StandardSubCategory{}).Where("id = ? AND category_id = ?", id, req.CategoryID).Update("sort_order", i+1)
+			}
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("分类 %d 不存在", id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "排序失败: " + err.Error()})
+		return
+	}
+	h.reload()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "排序已更新"})
+}
+
+// ExportStandardCategories 导出标准分类（category_mapping.json 的 standard_categories 格式）
+// GET /api/admin/standard-categories/export?save=1（save=1 时同时写回 category_mapping.json）
+func (h *StandardCategoryHandler) ExportStandardCategories(c *gin.Context) {
+	if c.Query("save") == "1" {
+		if err := utils.SaveTaxonomyToFile(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "写入分类文件失败: " + err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"standard_categories": utils.ExportTaxonomy()},
+	})
+}
+
+// ImportStandardCategories 导入标准分类（按ID新增或覆盖，不删除已有分类；分类改名时同步更新视频）
+// POST /api/admin/standard-categories/import
+// Body 为 category_mapping.json 格式（包含 standard_categories）；Body 为空时读取服务器上的 category_mapping.json
+func (h *StandardCategoryHandler) ImportStandardCategories(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "读取请求失败: " + err.Error()})
+		return
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		if data, err = os.ReadFile(utils.CategoryMappingFile); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "读取分类文件失败: " + err.Error()})
+			return
+		}
+	}
+
+	categories, subcategories, err := utils.ImportTaxonomy(h.db, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	h.reload()
+	renamed := utils.SyncVideoCategoryNames(h.db)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": fmt.Sprintf("已导入 %d 个分类，%d 个子分类", categories, subcategories),
+		"data":    gin.H{"categories": categories, "subcategories": subcategories, "renamed_videos": renamed},
+	})
+}
+
+// applyStandardCategoryRequest 校验并写入请求中的字段
+func applyStandardCategoryRequest(name *string, sortOrder *int, isVisible *bool, icon *string, req *standardCategoryRequest) error {
+	if req.Name != nil {
+		*name = strings.TrimSpace(*req.Name)
+	}
+	if *name == "" {
+		return fmt.Errorf("name 不能为空")
+	}
+	if len([]rune(*name)) > 50 {
+		return fmt.Errorf("name 不能超过50个字符")
+	}
+	if req.SortOrder != nil {
+		*sortOrder = *req.SortOrder
+	}
+	if req.IsVisible != nil {
+		*isVisible = *req.IsVisible
+	}
+	if req.Icon != nil {
+		*icon = strings.TrimSpace(*req.Icon)
+	}
+	return nil
+}
+
+// checkNewCategoryID 一级分类ID需为正数，不能是"其他"，也不能与已有分类或子分类重复
+// 一级分类和子分类的ID不能重叠，视频筛选和映射引擎按ID区分两者
+func (h *StandardCategoryHandler) checkNewCategoryID(id int) error {
+	if id <= 0 {
+		return fmt.Errorf("id 必须为正整数")
+	}
+	if id == utils.OtherCategoryID {
+		return fmt.Errorf("id %d 为内置的\"%s\"分类", id, utils.OtherCategoryName)
+	}
+	if _, ok := utils.GetTaxonomy().Category(id); ok {
+		return fmt.Errorf("分类 %d 已存在", id)
+	}
+	var count int64
+	h.db.Model(&models.StandardSubCategory{}).Where("id = ?", id).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("id %d 已被子分类使用", id)
+	}
+	return nil
+}
+
+// checkNewSubCategoryID 子分类ID需为正数，在所有子分类中唯一，且不能与一级分类ID重复
+func (h *StandardCategoryHandler) checkNewSubCategoryID(id int) error {
+	if id <= 0 {
+		return fmt.Errorf("id 必须为正整数")
+	}
+	if id == utils.OtherCategoryID {
+		return fmt.Errorf("id %d 为内置的\"%s\"分类", id, utils.OtherCategoryName)
+	}
+	var categories int64
+	h.db.Model(&models.StandardCategory{}).Where("id = ?", id).Count(&categories)
+	if categories > 0 {
+		return fmt.Errorf("id %d 已被一级分类使用", id)
+	}
+	var count int64
+	h.db.Model(&models.StandardSubCategory{}).Where("id = ?", id).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("子分类 %d 已存在", id)
+	}
+	return nil
+}
+
+// checkUnused 检查分类是否仍被视频或映射规则使用
+func (h *StandardCategoryHandler) checkUnused(c *gin.Context, videoCond, ruleCond string, id int) bool {
+	var videos, rules, fuzzyRules int64
+	h.db.Model(&models.Video{}).Where(videoCond, id).Count(&videos)
+	h.db.Model(&models.MappingRule{}).Where(ruleCond, id).Count(&rules)
+	h.db.Model(&models.FuzzyMatchRule{}).Where(ruleCond, id).Count(&fuzzyRules)
+	rules += fuzzyRules
+	if videos > 0 || rules > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": fmt.Sprintf("仍有 %d 个视频、%d 条映射规则使用该分类，请先调整后再删除", videos, rules),
+			"data":    gin.H{"videos": videos, "mapping_rules": rules},
+		})
+		return false
+	}
+	return true
+}
+
+func (h *StandardCategoryHandler) loadRow(c *gin.Context, row interface{}, notFound string) bool {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的分类ID"})
+		return false
+	}
+	if err := h.db.First(row, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": notFound})
+		return false
+	}
+	return true
+}
+
+func (h *StandardCategoryHandler) reload() {
+	if err := utils.ReloadTaxonomy(h.db); err != nil {
+		fmt.Printf("⚠️ 刷新标准分类缓存失败: %v\n", err)
+	}
+}
@@ -0,0 +1,398 @@
+package handles
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"vodcms/config"
+	"vodcms/models"
+	"vodcms/utils"
+)
+
+// TVBox（CatVod T4 接口）说明：
+// 1. 同一个地址按参数区分：play=播放，ids=详情，wd=搜索，t=分类，其余为首页
+// 2. 分类筛选通过 ext 参数传入 base64 编码的 JSON，例如 {"sub":"201","year":"2024","by":"score"}
+// 3. 详情的播放组与苹果CMS接口一致，合并所有资源站
+
+const tvboxPageSize = 20
+
+// tvboxVod TVBox 列表项
+type tvboxVod struct {
+	VodID      string `json:"vod_id"`
+	VodName    string `json:"vod_name"`
+	VodPic     string `json:"vod_pic"`
+	VodRemarks string `json:"vod_remarks"`
+}
+
+// tvboxDetail TVBox 详情
+type tvboxDetail struct {
+	tvboxVod
+	TypeName    string `json:"type_name"`
+	VodYear     string `json:"vod_year"`
+	VodArea     string `json:"vod_area"`
+	VodLang     string `json:"vod_lang"`
+	VodActor    string `json:"vod_actor"`
+	VodDirector string `json:"vod_director"`
+	VodContent  string `json:"vod_content"`
+	VodPlayFrom string `json:"vod_play_from"`
+	VodPlayURL  string `json:"vod_play_url"`
+}
+
+// tvboxFilter 筛选项
+type tvboxFilter struct {
+	Key   string             `json:"key"`
+	Name  string             `json:"name"`
+	Value []tvboxFilterValue `json:"value"`
+}
+
+type tvboxFilterValue struct {
+	N string `json:"n"`
+	V string `json:"v"`
+}
+
+// tvboxSortOptions 排序筛选项（v 对应 videoSortColumns）
+var tvboxSortOptions = []tvboxFilterValue{
+	{N: "最近更新", V: "collected"},
+	{N: "最高评分", V: "score"},
+	{N: "最多播放", V: "hits"},
+	{N: "上映年份", V: "year"},
+}
+
+// TVBoxVod TVBox 爬虫接口
+// GET /api/tvbox/vod                       首页（分类 + 筛选 + 推荐）
+// GET /api/tvbox/vod?t=1&pg=1&ext=base64   分类列表
+// GET /api/tvbox/vod?ids=1,2               详情
+// GET /api/tvbox/vod?wd=关键词              搜索
+// GET /api/tvbox/vod?play=地址&flag=播放组   播放
+func TVBoxVod(c *gin.Context) {
+	switch {
+	case c.Query("play") != "":
+		tvboxPlay(c)
+	case c.Query("ids") != "":
+		tvboxDetailList(c)
+	case c.Query("wd") != "":
+		tvboxSearch(c)
+	case c.Query("t") != "":
+		tvboxCategory(c)
+	default:
+		tvboxHome(c)
+	}
+}
+
+// tvboxBaseQuery 每个vod_id只取最新的一条（与列表页一致）
+func tvboxBaseQuery() *gorm.DB {
+	db := config.GetDB()
+	subQuery := db.Table("videos").
+		Select("MAX(id) as id").
+		Group("vod_id")
+	return db.Model(&models.Video{}).
+		Where("id IN (?)", subQuery)
+}
+
+func toTVBoxVod(video *models.Video) tvboxVod {
+	return tvboxVod{
+		VodID:      strconv.Itoa(video.VodID),
+		VodName:    video.VodName,
+		VodPic:     video.VodPic,
+		VodRemarks: video.VodRemarks,
+	}
+}
+
+// tvboxHome 首页：一级分类、每个分类的筛选项、最近更新的视频
+func tvboxHome(c *gin.Context) {
+	categories, err := utils.LoadStandardCategories()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	commonFilters := []tvboxFilter{
+		{Key: "year", Name: "年份", Value: tvboxYearValues()},
+		{Key: "area", Name: "地区", Value: tvboxTopValues("vod_area")},
+		{Key: "lang", Name: "语言", Value: tvboxTopValues("vod_lang")},
+		{Key: "by", Name: "排序", Value: tvboxSortOptions},
+	}
+
+	classes := make([]gin.H, 0)
+	filters := make(map[string][]tvboxFilter)
+	subs := make(map[int][]tvboxFilterValue)
+	for _, cat := range categories {
+		if cat.ParentID != 0 {
+			subs[cat.ParentID] = append(subs[cat.ParentID], tvboxFilterValue{N: cat.Name, V: strconv.Itoa(cat.ID)})
+		}
+	}
+	for _, cat := range categories {
+		if cat.ParentID != 0 {
+			continue
+		}
+		typeID := strconv.Itoa(cat.ID)
+		classes = append(classes, gin.H{"type_id": typeID, "type_name": cat.Name})
+
+		var catFilters []tvboxFilter
+		if len(subs[cat.ID]) > 0 {
+			values := append([]tvboxFilterValue{{N: "全部", V: ""}}, subs[cat.ID]...)
+			catFilters = append(catFilters, tvboxFilter{Key: "sub", Name: "类型", Value: values})
+		}
+		filters[typeID] = append(catFilters, commonFilters...)
+	}
+
+	var videos []models.Video
+	tvboxBaseQuery().Order("collected_at DESC").Limit(tvboxPageSize).Find(&videos)
+	list := make([]tvboxVod, 0, len(videos))
+	for i := range videos {
+		list = append(list, toTVBoxVod(&videos[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"class":   classes,
+		"filters": filters,
+		"list":    list,
+	})
+}
+
+// tvboxYearValues 年份筛选项（今年起往前15年）
+func tvboxYearValues() []tvboxFilterValue {
+	values := []tvboxFilterValue{{N: "全部", V: ""}}
+	year := time.Now().Year()
+	for y := year; y > year-15; y-- {
+		values = append(values, tvboxFilterValue{N: strconv.Itoa(y), V: strconv.Itoa(y)})
+	}
+	return values
+}
+
+// tvboxTopValues 取某个字段最常见的取值作为筛选项
+func tvboxTopValues(column string) []tvboxFilterValue {
+	var rows []struct {
+		Value string
+	}
+	tvboxBaseQuery().
+		Select(column + " AS value").
+		Where(column + " != ''").
+		Group(column).
+		Order("COUNT(*) DESC").
+		Limit(15).
+		Scan(&rows)
+
+	values := []tvboxFilterValue{{N: "全部", V: ""}}
+	for _, row := range rows {
+		values = append(values, tvboxFilterValue{N: row.Value, V: row.Value})
+	}
+	return values
+}
+
+// tvboxCategory 分类列表
+func tvboxCategory(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Query("t"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "t 参数无效"})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("pg", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	ext, err := decodeTVBoxExt(c.Query("ext"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+
+	filter := &VideoFilter{
+		CategoryID: categoryID,
+		Area:       ext["area"],
+		Lang:       ext["lang"],
+	}
+	if sub, err := strconv.Atoi(ext["sub"]); err == nil {
+		filter.SubCategoryID = sub
+	}
+	if year, err := strconv.Atoi(ext["year"]); err == nil {
+		filter.YearFrom, filter.YearTo = year, year
+	}
+	orderBy := videoSort{Name: "collected", Expr: "collected_at", Desc: true}
+	if column, ok := videoSortColumns[ext["by"]]; ok {
+		orderBy = videoSort{Name: ext["by"], Expr: column, Desc: true}
+	}
+
+	var total int64
+	filter.Apply(tvboxBaseQuery(), "").Count(&total)
+
+	var videos []models.Video
+	if err := filter.Apply(tvboxBaseQuery(), "").
+		Order(orderBy.OrderClause()).
+		Limit(tvboxPageSize).Offset((page - 1) * tvboxPageSize).
+		Find(&videos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	list := make([]tvboxVod, 0, len(videos))
+	for i := range videos {
+		list = append(list, toTVBoxVod(&videos[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"page":      page,
+		"pagecount": (total + tvboxPageSize - 1) / tvboxPageSize,
+		"limit":     tvboxPageSize,
+		"total":     total,
+		"list":      list,
+	})
+}
+
+// decodeTVBoxExt 解析分类筛选参数（base64编码的JSON，值统一转为字符串）
+func decodeTVBoxExt(ext string) (map[string]string, error) {
+	result := make(map[string]string)
+	if ext == "" {
+		return result, nil
+	}
+
+	// 未编码的 + 在查询参数中会变成空格
+	ext = strings.ReplaceAll(ext, " ", "+")
+
+	var data []byte
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, err = enc.DecodeString(ext); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ext 参数无效")
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("ext 参数无效")
+	}
+	for k, v := range raw {
+		result[k] = strings.TrimSpace(fmt.Sprint(v))
+	}
+	return result, nil
+}
+
+// tvboxDetailList 详情（播放组合并所有资源站）
+func tvboxDetailList(c *gin.Context) {
+	var vodIDs []int
+	for _, s := range strings.Split(c.Query("ids"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			vodIDs = append(vodIDs, id)
+		}
+	}
+
+	var rows []models.Video
+	if len(vodIDs) > 0 {
+		config.GetDB().Where("vod_id IN ?", vodIDs).Order("collected_at DESC, id DESC").Find(&rows)
+	}
+	sources := make(map[int][]models.Video)
+	for _, row := range rows {
+		sources[row.VodID] = append(sources[row.VodID], row)
+	}
+
+	list := make([]tvboxDetail, 0, len(vodIDs))
+	for _, vodID := range vodIDs {
+		videos, ok := sources[vodID]
+		if !ok {
+			continue
+		}
+		main := videos[0]
+		item := buildProvideItem(&main, videos)
+		list = append(list, tvboxDetail{
+			tvboxVod:    toTVBoxVod(&main),
+			TypeName:    item.TypeName,
+			VodYear:     main.VodYear,
+			VodArea:     main.VodArea,
+			VodLang:     main.VodLang,
+			VodActor:    main.VodActor,
+			VodDirector: main.VodDirector,
+			VodContent:  main.VodContent,
+			VodPlayFrom: item.VodPlayFrom,
+			VodPlayURL:  item.VodPlayURL,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"list": list})
+}
+
+// tvboxSearch 搜索
+func tvboxSearch(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("pg", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	search := utils.ParseVideoSearch(c.Query("wd"))
+	order := "collected_at DESC"
+	if search.UsesFTS() {
+		order = utils.VideoSearchRankColumn + " ASC, collected_at DESC"
+	}
+
+	var videos []models.Video
+	if err := search.Apply(tvboxBaseQuery()).
+		Order(order).
+		Limit(tvboxPageSize).Offset((page - 1) * tvboxPageSize).
+		Find(&videos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	list := make([]tvboxVod, 0, len(videos))
+	for i := range videos {
+		list = append(list, toTVBoxVod(&videos[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"list": list})
+}
+
+// tvboxPlay 播放：剧集地址是直链（m3u8/mp4等）时直接播放，否则交给客户端解析
+func tvboxPlay(c *gin.Context) {
+	playURL := c.Query("play")
+	parse := 1
+	if utils.IsDirectMediaURL(playURL) {
+		parse = 0
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"parse":  parse,
+		"jx":     0,
+		"url":    playURL,
+		"header": gin.H{},
+	})
+}
+
+// TVBoxConfig 生成 TVBox 站点配置（可直接填入 TVBox 的配置地址）
+// GET /api/tvbox/config
+func TVBoxConfig(c *gin.Context) {
+	api := requestBaseURL(c) + "/api/tvbox/vod"
+
+	c.JSON(http.StatusOK, gin.H{
+		"sites": []gin.H{{
+			"key":         "vodcms",
+			"name":        c.DefaultQuery("name", "VodCMS"),
+			"type":        4,
+			"api":         api,
+			"searchable":  1,
+			"quickSearch": 1,
+			"filterable":  1,
+		}},
+		"lives":  []gin.H{},
+		"parses": []gin.H{},
+	})
+}
+
+// requestBaseURL 根据请求推断本站地址（兼容反向代理的 X-Forwarded-Proto）
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,470 @@
+package handles
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"vodcms/models"
+	"vodcms/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// VideoAdminHandler 视频管理处理器（增删改查、变更历史、恢复、字段锁定）
+type VideoAdminHandler struct {
+	db *gorm.DB
+}
+
+// NewVideoAdminHandler 创建视频管理处理器
+func NewVideoAdminHandler(db *gorm.DB) *VideoAdminHandler {
+	return &VideoAdminHandler{db: db}
+}
+
+// ListVideos 获取视频列表（管理端，不去重，可按锁定状态筛选）
+// GET /api/admin/videos?page=1&page_size=20&source_key=xxx&keyword=xxx&locked=1
+func (h *VideoAdminHandler) ListVideos(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.Video{})
+	if sourceKey := c.Query("source_key"); sourceKey != "" {
+		query = query.Where("source_key = ?", sourceKey)
+	}
+	if vodID := c.Query("vod_id"); vodID != "" {
+		query = query.Where("vod_id = ?", vodID)
+	}
+	if categoryID := c.Query("standard_category_id"); categoryID != "" {
+		query = query.Where("standard_category_id = ?", categoryID)
+	}
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("vod_name LIKE ?", "%"+keyword+"%")
+	}
+	switch c.Query("locked") {
+	case "1", "true":
+		query = query.Where("vod_lock = ?", 1)
+	case "0", "false":
+		query = query.Where("vod_lock = ?", 0)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var videos []models.Video
+	if err := query.Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&videos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取视频列表失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"list":      videos,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// GetVideo 获取单条视频记录（包含锁定字段和可编辑字段列表）
+// GET /api/admin/videos/:id
+func (h *VideoAdminHandler) GetVideo(c *gin.Context) {
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"video":           video,
+			"locked_fields":   utils.LockedFields(video),
+			"editable_fields": utils.VideoFieldNames(),
+		},
+	})
+}
+
+// manualVodIDStart 手动添加且未指定 vod_id 的视频从该值开始分配，避开资源站的ID
+const manualVodIDStart = 900000000
+
+// manualVodIDMu 保证并发创建时分配的 vod_id 不重复
+var manualVodIDMu sync.Mutex
+
+// CreateVideo 手动添加视频
+// POST /api/admin/videos
+// Body: {"fields": {"vod_name": "xxx", "vod_pic": "xxx", ...}}
+// 指定 vod_id 时作为该视频的一个新来源（同一来源不能重复）；未指定时分配新的 vod_id
+func (h *VideoAdminHandler) CreateVideo(c *gin.Context) {
+	var req struct {
+		Fields map[string]interface{} `json:"fields" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	video := models.Video{CollectedAt: time.Now()}
+	if err := applyVideoFields(&video, req.Fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if video.VodName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "vod_name 不能为空"})
+		return
+	}
+	if video.SourceKey == "" {
+		video.SourceKey = "manual"
+	}
+	if video.SourceName == "" {
+		video.SourceName = "手动添加"
+	}
+	if video.StandardCategoryID == 0 {
+		video.StandardCategoryID = 99
+		video.StandardCategoryName = "其他"
+	}
+	if video.VodID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "vod_id 不能为负数"})
+		return
+	}
+
+	manualVodIDMu.Lock()
+	defer manualVodIDMu.Unlock()
+	if video.VodID == 0 {
+		var maxID int
+		h.db.Model(&models.Video{}).Where("vod_id >= ?", manualVodIDStart).Select("COALESCE(MAX(vod_id), 0)").Scan(&maxID)
+		video.VodID = max(maxID+1, manualVodIDStart)
+	} else {
+		var count int64
+		h.db.Model(&models.Video{}).Where("vod_id = ? AND source_key = ?", video.VodID, video.SourceKey).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"code": 409, "message": fmt.Sprintf("资源站 %s 已有 vod_id 为 %d 的视频", video.SourceKey, video.VodID)})
+			return
+		}
+	}
+
+	if err := h.db.Create(&video).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "创建视频失败: " + err.Error()})
+		return
+	}
+	utils.TouchCatalog()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "视频创建成功", "data": video})
+}
+
+// UpdateVideo 编辑视频字段，默认锁定被编辑的字段，防止下次采集覆盖
+// PUT /api/admin/videos/:id
+// Body: {"fields": {"vod_name": "庆余年 第二季", "standard_category_id": 2}, "lock": true}
+func (h *VideoAdminHandler) UpdateVideo(c *gin.Context) {
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Fields map[string]interface{} `json:"fields" binding:"required"`
+		Lock   *bool                  `json:"lock"` // 默认 true
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if len(req.Fields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "没有需要更新的字段"})
+		return
+	}
+
+	updated := *video
+	if err := applyVideoFields(&updated, req.Fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+
+	changes := utils.DiffVideoFields(video, &updated)
+	if req.Lock == nil || *req.Lock {
+		fields := utils.LockedFields(video)
+		for field := range req.Fields {
+			fields = append(fields, field)
+		}
+		utils.SetLockedFields(&updated, fields)
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&updated).Error; err != nil {
+			return err
+		}
+		return utils.RecordVideoHistory(tx, &updated, changes, "admin", 0)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新视频失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": fmt.Sprintf("已更新 %d 个字段", len(changes)),
+		"data": gin.H{
+			"video":         updated,
+			"changes":       changes,
+			"locked_fields": utils.LockedFields(&updated),
+		},
+	})
+}
+
+// DeleteVideo 删除视频记录（变更历史保留；若源站仍有该视频，下次采集会重新创建）
+// DELETE /api/admin/videos/:id
+func (h *VideoAdminHandler) DeleteVideo(c *gin.Context) {
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(video).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除视频失败: " + err.Error()})
+		return
+	}
+	h.db.Where("video_id = ?", video.ID).Delete(&models.PlayCheck{})
+	utils.TouchCatalog()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "视频已删除"})
+}
+
+// applyVideoFields 把请求中的字段写入视频，并保持标准分类ID与名称一致
+func applyVideoFields(video *models.Video, fields map[string]interface{}) error {
+	for field, value := range fields {
+		if !utils.IsVideoField(field) {
+			return fmt.Errorf("不支持编辑的字段: %s", field)
+		}
+		if err := utils.SetVideoField(video, field, value); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := fields["vod_name"]; ok {
+		// 片名变化时重新生成拼音，显式传入的拼音和首字母优先
+		utils.FillVideoPinyin(video)
+		for _, field := range []string{"vod_pinyin", "vod_pinyin_initials", "vod_letter"} {
+			if value, ok := fields[field]; ok {
+				if err := utils.SetVideoField(video, field, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	_, hasCategory := fields["standard_category_id"]
+	_, hasSubCategory := fields["standard_sub_category_id"]
+	if hasCategory && !hasSubCategory {
+		// 更换一级分类但未指定子分类时，清空原子分类
+		video.StandardSubCategoryID = nil
+		video.StandardSubCategoryName = ""
+	}
+	if hasCategory || hasSubCategory {
+		name, subName := utils.StandardCategoryNames(video.StandardCategoryID, video.StandardSubCategoryID)
+		if _, ok := fields["standard_category_name"]; !ok && name != "" {
+			video.StandardCategoryName = name
+		}
+		if _, ok := fields["standard_sub_category_name"]; !ok {
+			video.StandardSubCategoryName = subName
+		}
+		if _, ok := fields["standard_sub_category_confidence"]; !ok {
+			// 人工指定的子分类置信度为1
+			video.StandardSubCategoryConfidence = 0
+			if video.StandardSubCategoryID != nil {
+				video.StandardSubCategoryConfidence = 1
+			}
+		}
+	}
+	return nil
+}
+
+// VideoHistoryItem 历史记录（变更内容已解析）
+type VideoHistoryItem struct {
+	models.VideoHistory
+	Changes map[string]utils.FieldChange `json:"changes"`
+}
+
+// GetVideoHistory 获取视频的字段变更历史
+// GET /api/admin/videos/:id/history?page=1&page_size=20&field=vod_name
+func (h *VideoAdminHandler) GetVideoHistory(c *gin.Context) {
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.VideoHistory{}).Where("video_id = ?", video.ID)
+	if field := c.Query("field"); field != "" {
+		query = query.Where("(',' || changed_fields || ',') LIKE ?", "%,"+field+",%")
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var histories []models.VideoHistory
+	if err := query.Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&histories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取变更历史失败: " + err.Error()})
+		return
+	}
+
+	items := make([]VideoHistoryItem, 0, len(histories))
+	for _, history := range histories {
+		changes, err := utils.ParseHistoryChanges(&history)
+		if err != nil {
+			changes = map[string]utils.FieldChange{}
+		}
+		items = append(items, VideoHistoryItem{VideoHistory: history, Changes: changes})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"video_id":      video.ID,
+			"locked_fields": utils.LockedFields(video),
+			"total":         total,
+			"page":          page,
+			"page_size":     pageSize,
+			"list":          items,
+		},
+	})
+}
+
+// RestoreVideoHistory 恢复到某条历史记录之前的版本
+// POST /api/admin/videos/history/restore
+// Body: {"history_id": 12, "lock": true}
+func (h *VideoAdminHandler) RestoreVideoHistory(c *gin.Context) {
+	var req struct {
+		HistoryID uint `json:"history_id" binding:"required"`
+		Lock      bool `json:"lock"` // 恢复后锁定这些字段，防止再次被采集覆盖
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	video, changes, err := utils.RestoreVideoHistory(h.db, req.HistoryID, req.Lock)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": fmt.Sprintf("已恢复 %d 个字段", len(changes)),
+		"data": gin.H{
+			"video":   video,
+			"changes": changes,
+		},
+	})
+}
+
+// LockVideoFields 锁定视频字段（采集时不再覆盖）
+// POST /api/admin/videos/:id/lock
+// Body: {"fields": ["vod_name", "vod_pic"]}
+func (h *VideoAdminHandler) LockVideoFields(c *gin.Context) {
+	h.updateLockedFields(c, true)
+}
+
+// UnlockVideoFields 解锁视频字段，fields 为空时解锁全部
+// POST /api/admin/videos/:id/unlock
+// Body: {"fields": ["vod_name"]}
+func (h *VideoAdminHandler) UnlockVideoFields(c *gin.Context) {
+	h.updateLockedFields(c, false)
+}
+
+func (h *VideoAdminHandler) updateLockedFields(c *gin.Context, lock bool) {
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Fields []string `json:"fields"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	for _, field := range req.Fields {
+		if !utils.IsVideoField(field) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "不支持锁定的字段: " + field})
+			return
+		}
+	}
+
+	var fields []string
+	if lock {
+		if len(req.Fields) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "字段列表不能为空"})
+			return
+		}
+		fields = append(utils.LockedFields(video), req.Fields...)
+	} else if len(req.Fields) > 0 {
+		remove := make(map[string]bool)
+		for _, field := range utils.ExpandLockFields(req.Fields) {
+			remove[field] = true
+		}
+		for _, field := range utils.LockedFields(video) {
+			if !remove[field] {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	utils.SetLockedFields(video, fields)
+	err := h.db.Model(video).Updates(map[string]interface{}{
+		"vod_lock":        video.VodLock,
+		"vod_lock_fields": video.VodLockFields,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新锁定字段失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "锁定字段已更新",
+		"data": gin.H{
+			"video_id":      video.ID,
+			"locked_fields": utils.LockedFields(video),
+		},
+	})
+}
+
+// loadVideo 根据路径参数 :id 加载视频，失败时直接写入响应
+func (h *VideoAdminHandler) loadVideo(c *gin.Context) (*models.Video, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的视频ID"})
+		return nil, false
+	}
+
+	var video models.Video
+	if err := h.db.First(&video, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "视频不存在"})
+		return nil, false
+	}
+	return &video, true
+}
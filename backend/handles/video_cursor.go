@@ -0,0 +1,216 @@
+package handles
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// videoSort 列表排序方式（排序字段 + id 组成唯一顺序，游标分页依赖这一点）
+type videoSort struct {
+	Name string // 排序名称，写入游标用于校验
+	Expr string // 排序表达式
+	Desc bool
+}
+
+// OrderClause 排序子句，id 与主排序同向作为次序键
+func (s videoSort) OrderClause() string {
+	dir := "ASC"
+	if s.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("%s %s, videos.id %s", s.Expr, dir, dir)
+}
+
+// videoCursor 游标内容（编码后对客户端不透明）
+type videoCursor struct {
+	Sort  string          `json:"s"`
+	Type  string          `json:"t"` // 排序值类型: int, float, str, time, null
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// encodeVideoCursor 根据最后一条记录的排序值和ID生成游标
+func encodeVideoCursor(sortName string, value interface{}, id uint) (string, error) {
+	cursor := videoCursor{Sort: sortName, ID: id}
+
+	var raw interface{}
+	switch v := value.(type) {
+	case nil:
+		cursor.Type = "null"
+	case int64:
+		cursor.Type, raw = "int", v
+	case float64:
+		cursor.Type, raw = "float", v
+	case string:
+		cursor.Type, raw = "str", v
+	case []byte:
+		cursor.Type, raw = "str", string(v)
+	case time.Time:
+		cursor.Type, raw = "time", v.Format(time.RFC3339Nano)
+	default:
+		return "", fmt.Errorf("不支持的排序值类型 %T", value)
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return "", err
+	}
+	cursor.Value = data
+
+	data, err = json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeVideoCursor 解析游标，返回排序值（按类型还原）
+func decodeVideoCursor(token string) (*videoCursor, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cursor 无效")
+	}
+	var cursor videoCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, nil, fmt.Errorf("cursor 无效")
+	}
+
+	var value interface{}
+	switch cursor.Type {
+	case "null":
+		value = nil
+	case "int":
+		var n int64
+		err = json.Unmarshal(cursor.Value, &n)
+		value = n
+	case "float":
+		var f float64
+		err = json.Unmarshal(cursor.Value, &f)
+		value = f
+	case "str":
+		var s string
+		err = json.Unmarshal(cursor.Value, &s)
+		value = s
+	case "time":
+		var s string
+		if err = json.Unmarshal(cursor.Value, &s); err == nil {
+			value, err = time.Parse(time.RFC3339Nano, s)
+		}
+	default:
+		err = fmt.Errorf("未知类型")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("cursor 无效")
+	}
+	return &cursor, value, nil
+}
+
+// applyVideoCursor 添加“位于游标之后”的条件（行值比较，配合 OrderClause 使用）
+func applyVideoCursor(query *gorm.DB, s videoSort, value interface{}, id uint) *gorm.DB {
+	if value == nil {
+		// 排序值为空时只能按ID继续
+		if s.Desc {
+			return query.Where(fmt.Sprintf("%s IS NULL AND videos.id < ?", s.Expr), id)
+		}
+		return query.Where(fmt.Sprintf("(%s IS NULL AND videos.id > ?) OR %s IS NOT NULL", s.Expr, s.Expr), id)
+	}
+	op := ">"
+	if s.Desc {
+		op = "<"
+	}
+	return query.Where(fmt.Sprintf("(%s, videos.id) %s (?, ?)", s.Expr, op), value, id)
+}
+
+// videoCountCache 列表总数缓存，避免无限滚动和爬虫每次请求都执行去重 COUNT
+type videoCountCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]videoCountEntry
+}
+
+type videoCountEntry struct {
+	total     int64
+	expiresAt time.Time
+}
+
+// videoCountCacheMaxEntries 缓存条目上限，超出时清理过期条目
+const videoCountCacheMaxEntries = 1000
+
+var videoTotals = &videoCountCache{
+	ttl:     time.Minute,
+	entries: make(map[string]videoCountEntry),
+}
+
+// Get 读取未过期的缓存
+func (c *videoCountCache) Get(key string) (int64, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return 0, time.Time{}, false
+	}
+	return entry.total, entry.expiresAt, true
+}
+
+// Set 写入缓存
+func (c *videoCountCache) Set(key string, total int64) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= videoCountCacheMaxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= videoCountCacheMaxEntries {
+			c.entries = make(map[string]videoCountEntry)
+		}
+	}
+
+	expiresAt := now.Add(c.ttl)
+	c.entries[key] = videoCountEntry{total: total, expiresAt: expiresAt}
+	return expiresAt
+}
+
+// videoCountKeyIgnored 不影响总数的参数（分页、排序、返回内容）
+var videoCountKeyIgnored = map[string]bool{
+	"page":      true,
+	"page_size": true,
+	"cursor":    true,
+	"sort":      true,
+	"order":     true,
+	"total":     true,
+	"facets":    true,
+}
+
+// videoCountKey 根据筛选参数生成缓存键（参数排序后拼接）
+func videoCountKey(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if !videoCountKeyIgnored[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		b.WriteString(url.QueryEscape(k))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(strings.Join(values, ",")))
+		b.WriteByte('&')
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package handles
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVideoCursorRoundTrip(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 123456789, time.FixedZone("CST", 8*3600))
+	tests := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{nil, nil},
+		{int64(42), int64(42)},
+		{8.5, 8.5},
+		{"庆余年", "庆余年"},
+		{[]byte("qingyunian"), "qingyunian"},
+	}
+	for _, tt := range tests {
+		token, err := encodeVideoCursor("score", tt.value, 7)
+		if err != nil {
+			t.Fatalf("%v: 编码失败: %v", tt.value, err)
+		}
+		cursor, value, err := decodeVideoCursor(token)
+		if err != nil {
+			t.Fatalf("%v: 解析失败: %v", tt.value, err)
+		}
+		if cursor.Sort != "score" || cursor.ID != 7 || value != tt.want {
+			t.Errorf("%v: 解析为 %s/%d/%v", tt.value, cursor.Sort, cursor.ID, value)
+		}
+	}
+
+	token, err := encodeVideoCursor("updated", now, 9)
+	if err != nil {
+		t.Fatalf("编码时间失败: %v", err)
+	}
+	_, value, err := decodeVideoCursor(token)
+	if got, ok := value.(time.Time); err != nil || !ok || !got.Equal(now) {
+		t.Errorf("时间解析为 %v，期望 %v（%v）", value, now, err)
+	}
+
+	if _, err := encodeVideoCursor("score", 3, 1); err == nil {
+		t.Error("不支持的类型应返回错误")
+	}
+}
+
+func TestDecodeVideoCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tokens := []string{
+		"",
+		"not base64!",
+		encode("not json"),
+		encode(`{"s":"score","t":"int","v":1}`), // 缺少ID
+		encode(`{"s":"score","t":"int","v":"x","id":1}`), // 类型不符
+		encode(`{"s":"score","t":"bool","v":true,"id":1}`),
+		encode(`{"s":"score","t":"time","v":"yesterday","id":1}`),
+	}
+	for _, token := range tokens {
+		if _, _, err := decodeVideoCursor(token); err == nil {
+			t.Errorf("%q 应解析失败", token)
+		}
+	}
+}
+
+func TestVideoCountKey(t *testing.T) {
+	a, _ := url.ParseQuery("lang=国语&area=大陆&area=香港&page=2&sort=score&cursor=abc")
+	b, _ := url.ParseQuery("area=香港&area=大陆&lang=国语&total=cached&facets=1")
+	if videoCountKey(a) != videoCountKey(b) {
+		t.Errorf("筛选条件相同时缓存键应一致: %q / %q", videoCountKey(a), videoCountKey(b))
+	}
+	c, _ := url.ParseQuery("lang=粤语&area=大陆&area=香港")
+	if videoCountKey(a) == videoCountKey(c) {
+		t.Error("筛选条件不同时缓存键应不同")
+	}
+}
@@ -0,0 +1,242 @@
+package handles
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"vodcms/utils"
+)
+
+// VideoFilter 公开视频列表的筛选条件
+type VideoFilter struct {
+	SourceKey     string
+	TypeName      string
+	Area          string
+	Lang          string
+	Letter        string  // 首字母：A-Z 或 0-9
+	YearFrom      int     // 年份下限（含）
+	YearTo        int     // 年份上限（含）
+	CategoryID    int     // 标准一级分类ID
+	SubCategoryID int     // 标准二级分类ID
+	IsEnd         *int    // 1 已完结，0 连载中
+	MinScore      float64 // 最低豆瓣评分
+}
+
+// 筛选维度（用于分面统计时排除自身条件）
+const (
+	facetArea        = "area"
+	facetLang        = "lang"
+	facetYear        = "year"
+	facetCategory    = "category"
+	facetSubCategory = "sub_category"
+	facetIsEnd       = "is_end"
+	facetScore       = "score"
+)
+
+// videoYearExpr 年份字段为字符串，比较和排序时转换为整数
+const videoYearExpr = "CAST(vod_year AS INTEGER)"
+
+// videoSortColumns 列表支持的排序字段
+var videoSortColumns = map[string]string{
+	"score":     "vod_douban_score",
+	"hits":      "vod_hits",
+	"year":      videoYearExpr,
+	"updated":   "updated_at",
+	"collected": "collected_at",
+}
+
+// videoScoreFacets 评分分面的档位（统计评分不低于该值的数量）
+var videoScoreFacets = []float64{9, 8, 7, 6}
+
+// parseVideoFilter 从查询参数解析筛选条件
+func parseVideoFilter(c *gin.Context) (*VideoFilter, error) {
+	filter := &VideoFilter{
+		SourceKey: c.Query("source_key"),
+		TypeName:  c.Query("type_name"),
+		Area:      c.Query("area"),
+		Lang:      c.Query("lang"),
+		Letter:    strings.ToUpper(c.Query("letter")),
+	}
+
+	if filter.Letter != "" && !utils.IsValidVodLetter(filter.Letter) {
+		return nil, fmt.Errorf("letter 参数只能是 A-Z 或 0-9")
+	}
+
+	intParams := []struct {
+		name   string
+		target *int
+	}{
+		{"year_from", &filter.YearFrom},
+		{"year_to", &filter.YearTo},
+		{"category_id", &filter.CategoryID},
+		{"sub_category_id", &filter.SubCategoryID},
+	}
+	for _, p := range intParams {
+		value := c.Query(p.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s 参数无效: %s", p.name, value)
+		}
+		*p.target = n
+	}
+	if filter.YearFrom > 0 && filter.YearTo > 0 && filter.YearFrom > filter.YearTo {
+		return nil, fmt.Errorf("year_from 不能大于 year_to")
+	}
+
+	if value := c.Query("is_end"); value != "" {
+		if value != "0" && value != "1" {
+			return nil, fmt.Errorf("is_end 参数只能是 0 或 1")
+		}
+		isEnd, _ := strconv.Atoi(value)
+		filter.IsEnd = &isEnd
+	}
+
+	if value := c.Query("min_score"); value != "" {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || score < 0 || score > 10 {
+			return nil, fmt.Errorf("min_score 参数无效: %s", value)
+		}
+		filter.MinScore = score
+	}
+
+	return filter, nil
+}
+
+// Apply 为查询添加筛选条件，skip 指定的维度不参与（分面统计时使用）
+func (f *VideoFilter) Apply(query *gorm.DB, skip string) *gorm.DB {
+	if f.SourceKey != "" {
+		query = query.Where("source_key = ?", f.SourceKey)
+	}
+	if f.TypeName != "" {
+		query = query.Where("type_name = ?", f.TypeName)
+	}
+	if f.Letter != "" {
+		query = query.Where("vod_letter = ?", f.Letter)
+	}
+	if f.Area != "" && skip != facetArea {
+		query = query.Where("vod_area = ?", f.Area)
+	}
+	if f.Lang != "" && skip != facetLang {
+		query = query.Where("vod_lang = ?", f.Lang)
+	}
+	if skip != facetYear {
+		if f.YearFrom > 0 {
+			query = query.Where(videoYearExpr+" >= ?", f.YearFrom)
+		}
+		if f.YearTo > 0 {
+			query = query.Where(videoYearExpr+" BETWEEN 1 AND ?", f.YearTo)
+		}
+	}
+	if f.CategoryID > 0 && skip != facetCategory {
+		query = query.Where("standard_category_id = ?", f.CategoryID)
+	}
+	if f.SubCategoryID > 0 && skip != facetSubCategory {
+		query = query.Where("standard_sub_category_id = ?", f.SubCategoryID)
+	}
+	if f.IsEnd != nil && skip != facetIsEnd {
+		query = query.Where("vod_is_end = ?", *f.IsEnd)
+	}
+	if f.MinScore > 0 && skip != facetScore {
+		query = query.Where("vod_douban_score >= ?", f.MinScore)
+	}
+	return query
+}
+
+// parseVideoSort 解析排序参数，未指定时返回 nil（由调用方决定默认排序）
+// GET 参数: sort=score|hits|year|updated|collected&order=desc|asc
+func parseVideoSort(c *gin.Context) (*videoSort, error) {
+	name := c.Query("sort")
+	if name == "" {
+		return nil, nil
+	}
+	column, ok := videoSortColumns[name]
+	if !ok {
+		return nil, fmt.Errorf("sort 参数只能是 score、hits、year、updated 或 collected")
+	}
+
+	order := strings.ToUpper(c.DefaultQuery("order", "desc"))
+	if order != "DESC" && order != "ASC" {
+		return nil, fmt.Errorf("order 参数只能是 asc 或 desc")
+	}
+	return &videoSort{Name: name + "_" + strings.ToLower(order), Expr: column, Desc: order == "DESC"}, nil
+}
+
+// FacetValue 分面统计项
+type FacetValue struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// videoFacets 统计每个筛选维度下各取值的视频数量
+// 每个维度应用除自身以外的全部筛选条件，前端切换该维度的取值时数量依然准确
+func videoFacets(base func() *gorm.DB, filter *VideoFilter) (map[string][]FacetValue, error) {
+	facets := make(map[string][]FacetValue)
+
+	groupFacets := []struct {
+		name   string
+		column string
+		label  string
+		where  string
+		order  string
+	}{
+		{facetArea, "vod_area", "", "vod_area != ''", "count DESC"},
+		{facetLang, "vod_lang", "", "vod_lang != ''", "count DESC"},
+		{facetYear, "vod_year", "", videoYearExpr + " > 0", "value DESC"},
+		{facetCategory, "standard_category_id", "MAX(standard_category_name)", "standard_category_id > 0", "value ASC"},
+		{facetSubCategory, "standard_sub_category_id", "MAX(standard_sub_category_name)", "standard_sub_category_id IS NOT NULL", "value ASC"},
+		{facetIsEnd, "vod_is_end", "", "", "value DESC"},
+	}
+	for _, g := range groupFacets {
+		label := "''"
+		if g.label != "" {
+			label = g.label
+		}
+		query := filter.Apply(base(), g.name).
+			Select(fmt.Sprintf("%s AS value, %s AS name, COUNT(*) AS count", g.column, label)).
+			Group(g.column).
+			Order(g.order)
+		if g.where != "" {
+			query = query.Where(g.where)
+		}
+
+		var rows []struct {
+			Value string
+			Name  string
+			Count int64
+		}
+		if err := query.Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("统计 %s 失败: %w", g.name, err)
+		}
+		values := make([]FacetValue, 0, len(rows))
+		for _, row := range rows {
+			values = append(values, FacetValue{Value: row.Value, Name: row.Name, Count: row.Count})
+		}
+		facets[g.name] = values
+	}
+
+	// 评分按档位统计（不低于该分数）
+	scoreValues := make([]FacetValue, 0, len(videoScoreFacets))
+	for _, score := range videoScoreFacets {
+		var count int64
+		if err := filter.Apply(base(), facetScore).
+			Where("vod_douban_score >= ?", score).
+			Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("统计 %s 失败: %w", facetScore, err)
+		}
+		scoreValues = append(scoreValues, FacetValue{
+			Value: strconv.FormatFloat(score, 'f', -1, 64),
+			Count: count,
+		})
+	}
+	facets[facetScore] = scoreValues
+
+	return facets, nil
+}
@@ -1,16 +1,22 @@
 package handles
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
 	"vodcms/config"
 	"vodcms/models"
+	"vodcms/utils"
 )
 
 // GetVideos 获取视频列表（列表页去重，每个视频只显示一个版本）
+// GET /api/videos?keyword=&letter=&year_from=&year_to=&lang=&category_id=&sub_category_id=&is_end=&min_score=&sort=score&order=desc
+// 游标分页: GET /api/videos?cursor=&page_size=20&total=none，之后用返回的 next_cursor 继续请求，为空表示没有更多
+// 分面统计: facets=1 时在 data.facets 中返回各筛选项的数量
 func GetVideos(c *gin.Context) {
 	db := config.GetDB()
 
@@ -24,10 +30,23 @@ func GetVideos(c *gin.Context) {
 		pageSize = 20
 	}
 
-	// 获取筛选参数
-	sourceKey := c.Query("source_key")
-	typeName := c.Query("type_name")
-	area := c.Query("area")
+	// 获取筛选和排序参数
+	filter, err := parseVideoFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	sortBy, err := parseVideoSort(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
 	keyword := c.Query("keyword")
 
 	// 方案：使用子查询获取每个vod_id的最新记录（按采集时间）
@@ -36,31 +55,62 @@ func GetVideos(c *gin.Context) {
 		Select("MAX(id) as id").
 		Group("vod_id")
 
-	// 构建主查询
-	query := db.Model(&models.Video{}).
-		Where("id IN (?)", subQuery)
-
-	if sourceKey != "" {
-		query = query.Where("source_key = ?", sourceKey)
-	}
-	if typeName != "" {
-		query = query.Where("type_name = ?", typeName)
+	// 关键词检索：优先使用FTS5全文索引（按bm25相关度排序），不可用时回退为LIKE
+	var search *utils.VideoSearch
+	if keyword != "" {
+		search = utils.ParseVideoSearch(keyword)
 	}
-	if area != "" {
-		query = query.Where("vod_area = ?", area)
+
+	// 基础查询（去重 + 关键词），筛选条件和分面统计都在此基础上叠加
+	baseQuery := func() *gorm.DB {
+		query := db.Model(&models.Video{}).
+			Where("id IN (?)", subQuery)
+		if search != nil {
+			query = search.Apply(query)
+		}
+		return query
 	}
-	if keyword != "" {
-		query = query.Where("vod_name LIKE ?", "%"+keyword+"%")
+
+	// 排序：显式指定 > 关键词相关度 > 首字母浏览按拼音 > 采集时间
+	if sortBy == nil {
+		switch {
+		case search != nil && search.UsesFTS():
+			sortBy = &videoSort{Name: "relevance", Expr: utils.VideoSearchRankColumn}
+		case filter.Letter != "":
+			sortBy = &videoSort{Name: "pinyin", Expr: "vod_pinyin"}
+		default:
+			sortBy = &videoSort{Name: "collected_desc", Expr: "collected_at", Desc: true}
+		}
 	}
 
-	// 获取总数（去重后的）
-	var total int64
-	query.Count(&total)
+	// 分页方式：传 cursor 参数（首页传空值）使用游标分页，翻页时结果不会因采集写入而错位
+	cursorToken, cursorMode := c.GetQuery("cursor")
 
-	// 分页查询
+	// 构建主查询
+	query := filter.Apply(baseQuery(), "").Order(sortBy.OrderClause())
+	if cursorToken != "" {
+		cursor, value, err := decodeVideoCursor(cursorToken)
+		if err == nil && cursor.Sort != sortBy.Name {
+			err = fmt.Errorf("cursor 与当前排序方式不一致")
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		query = applyVideoCursor(query, *sortBy, value, cursor.ID)
+	}
+
+	// 分页查询（游标模式多取一条判断是否还有下一页）
 	var videos []models.Video
-	offset := (page - 1) * pageSize
-	result := query.Order("collected_at DESC").Limit(pageSize).Offset(offset).Find(&videos)
+	if cursorMode {
+		query = query.Limit(pageSize + 1)
+	} else {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+	result := query.Find(&videos)
 
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -70,20 +120,95 @@ func GetVideos(c *gin.Context) {
 		return
 	}
 
+	data := gin.H{
+		"page_size": pageSize,
+	}
+	if cursorMode {
+		nextCursor := ""
+		if len(videos) > pageSize {
+			videos = videos[:pageSize]
+			last := videos[len(videos)-1]
+
+			// 读取最后一条的排序值（排序表达式可能不在模型字段中，例如相关度）
+			var values []interface{}
+			if err := filter.Apply(baseQuery(), "").
+				Where("videos.id = ?", last.ID).
+				Pluck(sortBy.Expr, &values).Error; err == nil && len(values) > 0 {
+				nextCursor, err = encodeVideoCursor(sortBy.Name, values[0], last.ID)
+				if err != nil {
+					fmt.Printf("⚠️ 生成分页游标失败: %v\n", err)
+				}
+			}
+		}
+		data["next_cursor"] = nextCursor
+	} else {
+		data["page"] = page
+	}
+	data["list"] = videos
+
+	// 总数：exact 精确统计（翻页模式默认），cached 使用缓存（最多延迟1分钟），none 不统计（游标模式默认）
+	totalMode := c.Query("total")
+	if totalMode == "" {
+		totalMode = "exact"
+		if cursorMode {
+			totalMode = "none"
+		}
+	}
+	switch totalMode {
+	case "exact":
+		var total int64
+		filter.Apply(baseQuery(), "").Count(&total)
+		data["total"] = total
+	case "cached":
+		key := videoCountKey(c.Request.URL.Query())
+		total, expiresAt, ok := videoTotals.Get(key)
+		if !ok {
+			filter.Apply(baseQuery(), "").Count(&total)
+			expiresAt = videoTotals.Set(key, total)
+		}
+		data["total"] = total
+		data["total_expires_at"] = expiresAt
+	case "none":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "total 参数只能是 exact、cached 或 none",
+		})
+		return
+	}
+	data["total_mode"] = totalMode
+
+	if search != nil {
+		ids := make([]uint, 0, len(videos))
+		for _, video := range videos {
+			ids = append(ids, video.ID)
+		}
+		data["search_mode"] = search.Mode()
+		data["highlights"] = search.Highlights(db, ids)
+	}
+
+	// 分面统计（每个维度一次分组查询，开销较大，需 facets=1 显式开启）
+	if c.Query("facets") == "1" || c.Query("facets") == "true" {
+		facets, err := videoFacets(baseQuery, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": 500,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		data["facets"] = facets
+	}
+
 	// 返回结果
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
-		"data": gin.H{
-			"list":      videos,
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-		},
+		"data": data,
 	})
 }
 
-// GetVideoByID 获取单个视频详情（包含所有源的播放地址）
+// GetVideoByID 获取单个视频详情（包含所有源的播放地址，按综合得分排序）
 func GetVideoByID(c *gin.Context) {
 	db := config.GetDB()
 
@@ -127,16 +252,25 @@ func GetVideoByID(c *gin.Context) {
 	var allSources []models.Video
 	db.Where("vod_id = ?", mainVideo.VodID).Order("collected_at DESC").Find(&allSources)
 
-	// 构建播放源列表
+	// 构建播放源列表（附带播放地址检测结果，按综合得分排序）
+	health := utils.VideoPlayHealth(db, videoIDs(allSources))
+	sourceQualities := utils.SourceQualities(db)
 	var playSources []map[string]interface{}
-	for _, source := range allSources {
+	for _, item := range utils.RankPlaySources(allSources, health, utils.SourcePriorities(db)) {
+		source := item.Video
+		quality, detected := utils.PlayQuality(item.Health, sourceQualities[source.SourceKey])
 		playSources = append(playSources, map[string]interface{}{
-			"source_key":    source.SourceKey,
-			"source_name":   source.SourceName,
-			"vod_play_url":  source.VodPlayURL,
-			"vod_play_from": source.VodPlayFrom,
-			"collected_at":  source.CollectedAt,
-			"vod_remarks":   source.VodRemarks,
+			"source_key":       source.SourceKey,
+			"source_name":      source.SourceName,
+			"vod_play_url":     source.VodPlayURL,
+			"vod_play_from":    source.VodPlayFrom,
+			"collected_at":     source.CollectedAt,
+			"vod_remarks":      source.VodRemarks,
+			"health":           item.Health,
+			"quality":          quality,
+			"quality_detected": detected,
+			"episodes":         item.Episodes,
+			"score":            item.Score,
 		})
 	}
 
@@ -192,7 +326,59 @@ func GetVideoStats(c *gin.Context) {
 	})
 }
 
-// GetVideoPlayURL 获取视频播放地址
+// GetVideoLetters 获取首字母列表及每个字母下的视频数量（用于按字母浏览）
+// GET /api/videos/letters?source_key=xxx&type_name=xxx
+func GetVideoLetters(c *gin.Context) {
+	db := config.GetDB()
+
+	// 与列表页一致，每个vod_id只统计一次
+	subQuery := db.Table("videos").
+		Select("MAX(id) as id").
+		Group("vod_id")
+
+	query := db.Model(&models.Video{}).
+		Where("id IN (?)", subQuery)
+	if sourceKey := c.Query("source_key"); sourceKey != "" {
+		query = query.Where("source_key = ?", sourceKey)
+	}
+	if typeName := c.Query("type_name"); typeName != "" {
+		query = query.Where("type_name = ?", typeName)
+	}
+
+	var rows []struct {
+		Letter string
+		Count  int64
+	}
+	if err := query.Select("vod_letter as letter, COUNT(*) as count").
+		Group("vod_letter").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Letter] = row.Count
+	}
+
+	// 固定输出 A-Z 和 0-9，没有视频的字母数量为0
+	letters := make([]gin.H, 0, 27)
+	for ch := 'A'; ch <= 'Z'; ch++ {
+		letters = append(letters, gin.H{"letter": string(ch), "count": counts[string(ch)]})
+	}
+	letters = append(letters, gin.H{"letter": "0-9", "count": counts["0-9"]})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": letters,
+	})
+}
+
+// GetVideoPlayURL 获取视频播放地址（播放源按综合得分从高到低排列，score 为得分明细）
 // GET /api/videos/play?vod_id=xxx&source_key=xxx
 func GetVideoPlayURL(c *gin.Context) {
 	db := config.GetDB()
@@ -236,21 +422,28 @@ func GetVideoPlayURL(c *gin.Context) {
 
 	// 构建播放源列表
 	type PlaySource struct {
-		SourceKey    string `json:"source_key"`
-		SourceName   string `json:"source_name"`
-		PlayFrom     string `json:"play_from"`     // 播放来源标识（如m3u8, mp4等）
-		PlayURL      string `json:"play_url"`      // 播放URL列表
-		PlayServer   string `json:"play_server"`   // 播放服务器
-		PlayNote     string `json:"play_note"`     // 播放说明
-		DownFrom     string `json:"down_from"`     // 下载来源
-		DownURL      string `json:"down_url"`      // 下载地址
-		VodRemarks   string `json:"vod_remarks"`   // 备注（如更新状态）
-		CollectedAt  string `json:"collected_at"`  // 采集时间
-		Quality      string `json:"quality"`       // 画质标识
+		SourceKey       string                `json:"source_key"`
+		SourceName      string                `json:"source_name"`
+		PlayFrom        string                `json:"play_from"`        // 播放来源标识（如m3u8, mp4等）
+		PlayURL         string                `json:"play_url"`         // 播放URL列表
+		PlayServer      string                `json:"play_server"`      // 播放服务器
+		PlayNote        string                `json:"play_note"`        // 播放说明
+		DownFrom        string                `json:"down_from"`        // 下载来源
+		DownURL         string                `json:"down_url"`         // 下载地址
+		VodRemarks      string                `json:"vod_remarks"`      // 备注（如更新状态）
+		CollectedAt     string                `json:"collected_at"`     // 采集时间
+		Quality         string                `json:"quality"`          // 画质标识
+		QualityDetected bool                  `json:"quality_detected"` // 画质是否从播放列表识别（否则为资源站默认画质）
+		Health          *utils.PlayLinkHealth `json:"health"`           // 播放地址检测结果
+		Episodes        int                   `json:"episodes"`         // 剧集数（取剧集最多的播放组）
+		Score           utils.PlaySourceScore `json:"score"`            // 排序得分明细
 	}
 
+	health := utils.VideoPlayHealth(db, videoIDs(videos))
+	sourceQualities := utils.SourceQualities(db)
 	var playSources []PlaySource
-	for _, video := range videos {
+	for _, item := range utils.RankPlaySources(videos, health, utils.SourcePriorities(db)) {
+		video := item.Video
 		source := PlaySource{
 			SourceKey:   video.SourceKey,
 			SourceName:  video.SourceName,
@@ -262,16 +455,13 @@ func GetVideoPlayURL(c *gin.Context) {
 			DownURL:     video.VodDownURL,
 			VodRemarks:  video.VodRemarks,
 			CollectedAt: video.CollectedAt.Format("2006-01-02 15:04:05"),
+			Health:      item.Health,
+			Episodes:    item.Episodes,
+			Score:       item.Score,
 		}
 
-		// 简单判断画质
-		if video.SourceKey == "snzy" {
-			source.Quality = "高清"
-		} else if video.SourceKey == "hhzy" {
-			source.Quality = "标清"
-		} else {
-			source.Quality = "标准"
-		}
+		// 画质：优先使用播放列表识别结果，其次使用资源站默认画质
+		source.Quality, source.QualityDetected = utils.PlayQuality(item.Health, sourceQualities[video.SourceKey])
 
 		playSources = append(playSources, source)
 	}
@@ -297,3 +487,12 @@ func GetVideoPlayURL(c *gin.Context) {
 		},
 	})
 }
+
+// videoIDs 提取视频记录的数据库ID
+func videoIDs(videos []models.Video) []uint {
+	ids := make([]uint, 0, len(videos))
+	for _, video := range videos {
+		ids = append(ids, video.ID)
+	}
+	return ids
+}
@@ -0,0 +1,273 @@
+package handles
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"vodcms/models"
+	"vodcms/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WebhookHandler Webhook 管理处理器（订阅增删改查、测试、投递记录、重新投递）
+type WebhookHandler struct {
+	db *gorm.DB
+}
+
+// NewWebhookHandler 创建 Webhook 管理处理器
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{db: db}
+}
+
+// webhookRequest 创建/更新 Webhook 的请求（更新时只修改传入的字段）
+type webhookRequest struct {
+	Name     *string  `json:"name"`
+	URL      *string  `json:"url"`
+	Secret   *string  `json:"secret"`
+	Events   []string `json:"events"`
+	IsActive *bool    `json:"is_active"`
+}
+
+// webhookItem 返回给管理端的 Webhook（不返回密钥）
+type webhookItem struct {
+	models.Webhook
+	HasSecret bool `json:"has_secret"`
+}
+
+// GetWebhookEvents 获取可订阅的事件列表
+// GET /api/admin/webhooks/events
+func (h *WebhookHandler) GetWebhookEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 200, "data": utils.WebhookEvents})
+}
+
+// ListWebhooks 获取 Webhook 列表
+// GET /api/admin/webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	var hooks []models.Webhook
+	if err := h.db.Order("id ASC").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取Webhook失败: " + err.Error()})
+		return
+	}
+
+	items := make([]webhookItem, 0, len(hooks))
+	for _, hook := range hooks {
+		items = append(items, webhookItem{Webhook: hook, HasSecret: hook.Secret != ""})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": len(items),
+			"list":  items,
+		},
+	})
+}
+
+// CreateWebhook 创建 Webhook
+// POST /api/admin/webhooks
+// Body: {"name": "Telegram", "url": "https://...", "secret": "xxx", "events": ["video.created"]}
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if req.URL == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "url 不能为空"})
+		return
+	}
+
+	hook := models.Webhook{IsActive: true}
+	if err := applyWebhookRequest(&hook, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	active := hook.IsActive
+	if err := h.db.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "创建Webhook失败: " + err.Error()})
+		return
+	}
+	if !active {
+		// 零值会被 default:true 覆盖，需要单独更新
+		h.db.Model(&hook).Update("is_active", false)
+	}
+	utils.ReloadWebhooks()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Webhook创建成功", "data": webhookItem{Webhook: hook, HasSecret: hook.Secret != ""}})
+}
+
+// UpdateWebhook 更新 Webhook
+// PUT /api/admin/webhooks/:id
+// Body: {"events": ["collection.failed"], "is_active": false}，secret 传空字符串表示清除
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if err := applyWebhookRequest(hook, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if err := h.db.Save(hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新Webhook失败: " + err.Error()})
+		return
+	}
+	utils.ReloadWebhooks()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Webhook已更新", "data": webhookItem{Webhook: *hook, HasSecret: hook.Secret != ""}})
+}
+
+// DeleteWebhook 删除 Webhook（投递记录保留）
+// DELETE /api/admin/webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Delete(hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "删除Webhook失败: " + err.Error()})
+		return
+	}
+	utils.ReloadWebhooks()
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "Webhook已删除"})
+}
+
+// TestWebhook 发送 ping 测试事件
+// POST /api/admin/webhooks/:id/test
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	delivery, err := utils.PingWebhook(h.db, hook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "发送测试事件失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "测试事件已加入发送队列", "data": delivery})
+}
+
+// GetWebhookDeliveries 获取投递记录
+// GET /api/admin/webhooks/:id/deliveries?page=1&page_size=20&status=failed&event=video.created
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取投递记录失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"webhook_id": hook.ID,
+			"total":      total,
+			"page":       page,
+			"page_size":  pageSize,
+			"list":       deliveries,
+		},
+	})
+}
+
+// RedeliverWebhook 重新投递（重置重试次数）
+// POST /api/admin/webhook-deliveries/:id/redeliver
+func (h *WebhookHandler) RedeliverWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的投递记录ID"})
+		return
+	}
+	if err := utils.RedeliverWebhook(h.db, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "已重新加入发送队列"})
+}
+
+// applyWebhookRequest 校验并写入请求中的字段
+func applyWebhookRequest(hook *models.Webhook, req *webhookRequest) error {
+	if req.URL != nil {
+		u, err := url.Parse(strings.TrimSpace(*req.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url 必须是 http 或 https 地址")
+		}
+		hook.URL = u.String()
+	}
+	if req.Events != nil {
+		var events []string
+		for _, event := range req.Events {
+			event = strings.TrimSpace(event)
+			if event == "*" {
+				events = nil
+				break
+			}
+			if !utils.IsWebhookEvent(event) {
+				return fmt.Errorf("未知的事件: %s", event)
+			}
+			events = append(events, event)
+		}
+		hook.Events = strings.Join(events, ",")
+	}
+	if req.Name != nil {
+		hook.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Secret != nil {
+		hook.Secret = *req.Secret
+	}
+	if req.IsActive != nil {
+		hook.IsActive = *req.IsActive
+	}
+	return nil
+}
+
+func (h *WebhookHandler) loadWebhook(c *gin.Context) (*models.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的Webhook ID"})
+		return nil, false
+	}
+
+	var hook models.Webhook
+	if err := h.db.First(&hook, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "Webhook不存在"})
+		return nil, false
+	}
+	return &hook, true
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -8,12 +9,14 @@ import (
 
 	"vodcms/config"
 	"vodcms/server"
+	"vodcms/utils"
 )
 
 func main() {
 	// 解析命令行参数
-	mode := flag.String("mode", "server", "运行模式: server (服务器模式) 或 cli (命令行模式)")
+	mode := flag.String("mode", "server", "运行模式: server (服务器模式)、cli (命令行模式)、library (导出媒体库) 或 train-classifier (训练分类器)")
 	port := flag.String("port", "8080", "服务器端口")
+	out := flag.String("out", "", "媒体库导出目录（默认读取 LIBRARY_DIR），或分类器模型文件（默认读取 CLASSIFIER_MODEL）")
 	flag.Parse()
 
 	// 加载配置
@@ -25,6 +28,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 初始化全文检索（需以 -tags sqlite_fts5 编译，否则回退为 LIKE 检索）
+	if err := utils.InitVideoSearch(config.GetDB()); err != nil {
+		fmt.Printf("⚠️ 全文检索不可用，将使用LIKE检索: %v\n", err)
+		fmt.Println("⚠️ 请使用 make build 或 go build -tags sqlite_fts5 编译以启用 FTS5 全文检索")
+	}
+
+	// 为旧数据补全片名拼音
+	if n, err := utils.BackfillVideoPinyin(config.GetDB()); err != nil {
+		fmt.Printf("⚠️ 补全拼音失败: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("✅ 已为 %d 条视频生成拼音\n", n)
+	}
+
+	// 加载分类器模型（未训练时跳过）
+	if err := utils.UseCategoryClassifier(config.AppConfig.ClassifierModel); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("⚠️ 加载分类器失败: %v\n", err)
+	}
+
 	fmt.Println("=== 苹果CMS多源采集系统 ===")
 
 	switch *mode {
@@ -38,9 +59,38 @@ func main() {
 	case "cli":
 		// 命令行模式
 		server.RunCLI()
+	case "library":
+		// 导出 Emby/Jellyfin/Kodi 媒体库
+		dir := *out
+		if dir == "" {
+			dir = config.AppConfig.LibraryDir
+		}
+		fmt.Printf("📁 导出媒体库到: %s\n", dir)
+		stats, err := utils.ExportLibrary(config.GetDB(), dir, nil)
+		if err != nil {
+			log.Fatalf("❌ 导出媒体库失败: %v\n", err)
+		}
+		fmt.Printf("✅ 导出完成: 共 %d 个视频，写入 %d，未变化 %d，无播放地址 %d，删除 %d\n",
+			stats.Total, stats.Written, stats.Unchanged, stats.Skipped, stats.Removed)
+	case "train-classifier":
+		// 用已分类的视频训练分类器
+		path := *out
+		if path == "" {
+			path = config.AppConfig.ClassifierModel
+		}
+		model, err := utils.TrainCategoryClassifier(config.GetDB())
+		if err != nil {
+			log.Fatalf("❌ 训练分类器失败: %v\n", err)
+		}
+		if err := model.Save(path); err != nil {
+			log.Fatalf("❌ 保存分类器失败: %v\n", err)
+		}
+		fmt.Printf("✅ 训练完成: %d 个样本，%d 个分类，%d 个特征，验证集 %d 个，准确率 %.1f%%\n",
+			model.Samples, len(model.Labels), model.Vocabulary, model.Holdout, model.Accuracy*100)
+		fmt.Printf("📄 模型已保存到: %s（重启服务后生效）\n", path)
 	default:
 		fmt.Printf("❌ 未知的运行模式: %s\n", *mode)
-		fmt.Println("可用模式: server, cli")
+		fmt.Println("可用模式: server, cli, library, train-classifier")
 		os.Exit(1)
 	}
 }
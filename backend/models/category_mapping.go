@@ -29,6 +29,7 @@ type MappingRule struct {
 	StandardSubID *int      `json:"standard_sub_id"`
 	Priority      int       `gorm:"default:100" json:"priority"`               // 优先级，数字越小优先级越高
 	MatchType     string    `gorm:"size:20;default:'exact'" json:"match_type"` // exact, fuzzy, pattern
+	Pattern       string    `gorm:"size:200" json:"pattern"`                   // fuzzy 为关键词（| 分隔），pattern 为正则，为空时使用 source_name
 	IsActive      bool      `gorm:"default:true" json:"is_active"`
 	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -37,8 +38,9 @@ type MappingRule struct {
 // FuzzyMatchRule 模糊匹配规则
 type FuzzyMatchRule struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
-	Pattern       string    `gorm:"size:100;not null" json:"pattern"` // 匹配模式（支持正则或关键词）
-	Keywords      string    `gorm:"type:text" json:"keywords"`        // 关键词列表（JSON数组）
+	Pattern       string    `gorm:"size:100;not null" json:"pattern"`                 // 匹配模式（正则，不区分大小写）
+	Keywords      string    `gorm:"type:text" json:"keywords"`                        // 关键词列表（JSON数组，包含任一关键词即匹配）
+	MatchFields   string    `gorm:"size:100;default:'type_name'" json:"match_fields"` // 匹配的字段（逗号分隔）：type_name, vod_class, vod_area, title
 	StandardID    int       `gorm:"not null" json:"standard_id"`
 	StandardSubID *int      `json:"standard_sub_id"`
 	Priority      int       `gorm:"default:200" json:"priority"`
@@ -47,6 +49,20 @@ type FuzzyMatchRule struct {
 	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
+// SubCategoryRule 子分类推断规则（视频只映射到一级分类时，按标签、地区、标题或简介推断子分类）
+type SubCategoryRule struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	StandardID    int       `gorm:"index;not null" json:"standard_id"` // 适用的一级分类
+	StandardSubID int       `gorm:"not null" json:"standard_sub_id"`   // 推断出的子分类
+	Field         string    `gorm:"size:20;not null" json:"field"`     // 匹配的字段：vod_class, vod_area, title, content
+	Pattern       string    `gorm:"size:200;not null" json:"pattern"`  // 正则（不区分大小写）
+	Confidence    float64   `gorm:"default:0.8" json:"confidence"`     // 命中时的置信度（0-1）
+	Priority      int       `gorm:"default:100" json:"priority"`       // 优先级，数字越小优先级越高
+	IsActive      bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
 // TableName 指定表名
 func (UnmappedCategory) TableName() string {
 	return "unmapped_categories"
@@ -59,3 +75,7 @@ func (MappingRule) TableName() string {
 func (FuzzyMatchRule) TableName() string {
 	return "fuzzy_match_rules"
 }
+
+func (SubCategoryRule) TableName() string {
+	return "subcategory_rules"
+}
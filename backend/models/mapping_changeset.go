@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// MappingChangeset 映射规则变更集（一次操作对映射规则、模糊规则和子分类规则的全部修改）
+type MappingChangeset struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	Author       string `gorm:"size:100;index" json:"author"`      // 操作人（X-Admin-User 请求头）
+	Action       string `gorm:"size:50;index" json:"action"`       // 操作，如 add_rule, batch_update, auto_map, rollback
+	Summary      string `gorm:"size:500" json:"summary"`           // 操作说明
+	Changes      string `gorm:"type:text;not null" json:"changes"` // 规则差异 JSON: [{"table","rule_id","op","before","after"}]
+	ChangeCount  int    `json:"change_count"`                      // 变化的规则数
+	RollbackOf   *uint  `gorm:"index" json:"rollback_of"`          // 回滚的变更集ID（回滚操作产生的变更集）
+	RolledBackBy *uint  `json:"rolled_back_by"`                    // 被哪个变更集回滚
+}
+
+// TableName 指定表名
+func (MappingChangeset) TableName() string {
+	return "mapping_changesets"
+}
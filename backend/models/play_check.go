@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// PlayCheck 播放地址检测结果（每条视频记录的每个播放组一条，重复检测时更新）
+type PlayCheck struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	VideoID    uint   `gorm:"uniqueIndex:idx_play_check_group;not null" json:"video_id"` // videos.id
+	GroupIndex int    `gorm:"uniqueIndex:idx_play_check_group" json:"group_index"`       // 播放组序号（从0开始）
+	VodID      int    `gorm:"index" json:"vod_id"`
+	SourceKey  string `gorm:"size:50;index" json:"source_key"`
+	PlayFrom   string `gorm:"size:100" json:"play_from"`
+
+	EpisodeIndex int    `json:"episode_index"`                // 抽样检测的剧集序号
+	EpisodeName  string `gorm:"size:100" json:"episode_name"` // 抽样检测的剧集名称
+	EpisodeURL   string `gorm:"size:1000" json:"episode_url"`
+
+	Status    string    `gorm:"size:20;index" json:"status"` // ok, dead, timeout
+	Error     string    `gorm:"size:500" json:"error"`
+	LatencyMs int64     `json:"latency_ms"` // 首个请求耗时
+	Variants  int       `json:"variants"`   // m3u8 主播放列表中的子码率数量
+	Segments  int       `json:"segments"`   // m3u8 媒体播放列表中的分片数量
+	Width     int       `json:"width"`      // 最高子码率的分辨率（主播放列表未标注时为0）
+	Height    int       `json:"height"`
+	Bandwidth int64     `json:"bandwidth"`              // 最高子码率的码率（bps）
+	Quality   string    `gorm:"size:20" json:"quality"` // 画质标签，如 1080P、720P
+	CheckedAt time.Time `gorm:"index" json:"checked_at"`
+}
+
+// TableName 指定表名
+func (PlayCheck) TableName() string {
+	return "play_checks"
+}
@@ -12,6 +12,9 @@ type Source struct {
 	BaseURL string `gorm:"size:500;not null" json:"base_url"`
 	Key     string `gorm:"size:50;uniqueIndex;not null" json:"key"`
 	Enabled bool   `gorm:"default:true" json:"enabled"`
+	Quality string `gorm:"size:20" json:"quality"` // 默认画质（未从播放列表识别到分辨率时使用，如 高清、标清）
+	// Priority 播放源排序优先级（0-100，越大越靠前）
+	Priority int `gorm:"default:0" json:"priority"`
 }
 
 // TableName 指定表名
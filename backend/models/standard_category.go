@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// StandardCategory 标准分类（一级分类，ID 由管理员指定，与视频的 standard_category_id 对应）
+type StandardCategory struct {
+	ID        int       `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name      string `gorm:"size:50;not null" json:"name"`
+	SortOrder int    `gorm:"default:0;index" json:"sort_order"` // 排序，数字越小越靠前
+	IsVisible bool   `gorm:"default:true" json:"is_visible"`    // 是否在前台分类列表中显示
+	Icon      string `gorm:"size:500" json:"icon"`              // 图标（图片地址或图标名称）
+}
+
+// StandardSubCategory 标准子分类
+type StandardSubCategory struct {
+	ID         int       `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	CategoryID int       `gorm:"index;not null" json:"category_id"` // 所属一级分类
+
+	Name      string `gorm:"size:50;not null" json:"name"`
+	SortOrder int    `gorm:"default:0;index" json:"sort_order"`
+	IsVisible bool   `gorm:"default:true" json:"is_visible"`
+	Icon      string `gorm:"size:500" json:"icon"`
+}
+
+// TableName 指定表名
+func (StandardCategory) TableName() string {
+	return "standard_categories"
+}
+
+func (StandardSubCategory) TableName() string {
+	return "standard_sub_categories"
+}
@@ -18,6 +18,10 @@ type Video struct {
 	VodPic     string `gorm:"size:1000" json:"vod_pic"`
 	VodRemarks string `gorm:"size:200" json:"vod_remarks"`
 
+	// 拼音（导入时根据片名生成，用于检索）
+	VodPinyin         string `gorm:"size:1000;index" json:"vod_pinyin"`         // 全拼，如 qingyunian
+	VodPinyinInitials string `gorm:"size:200;index" json:"vod_pinyin_initials"` // 首字母，如 qyn
+
 	// 分类信息（原始）
 	TypeID   int    `gorm:"index" json:"type_id"`            // 源站分类ID
 	TypeID1  int    `gorm:"index" json:"type_id_1"`          // 源站父分类ID
@@ -29,6 +33,8 @@ type Video struct {
 	StandardCategoryName    string `gorm:"size:50;index" json:"standard_category_name"` // 标准一级分类名称
 	StandardSubCategoryID   *int   `gorm:"index" json:"standard_sub_category_id"`       // 标准二级分类ID
 	StandardSubCategoryName string `gorm:"size:50" json:"standard_sub_category_name"`   // 标准二级分类名称
+	// 标准二级分类的置信度（0-1）：映射规则指定的取映射评分（精确规则为1），推断的取推断规则或分类器的评分，没有二级分类时为0
+	StandardSubCategoryConfidence float64 `gorm:"default:0" json:"standard_sub_category_confidence"`
 
 	// 旧字段（兼容）
 	VideoTypeID uint       `gorm:"index" json:"video_type_id"` // 关联到video_types表
@@ -76,11 +82,12 @@ type Video struct {
 	VodHitsMonth int `json:"vod_hits_month"`
 
 	// 其他信息
-	VodPubdate   string `gorm:"size:200" json:"vod_pubdate"`
-	VodLevel     int    `json:"vod_level"`
-	VodCopyright int    `json:"vod_copyright"`
-	VodLock      int    `json:"vod_lock"`
-	GroupID      int    `gorm:"index" json:"group_id"`
+	VodPubdate    string `gorm:"size:200" json:"vod_pubdate"`
+	VodLevel      int    `json:"vod_level"`
+	VodCopyright  int    `json:"vod_copyright"`
+	VodLock       int    `json:"vod_lock"`                         // 1 表示存在被锁定的字段
+	VodLockFields string `gorm:"size:1000" json:"vod_lock_fields"` // 锁定字段（逗号分隔的json字段名），采集时不会被覆盖
+	GroupID       int    `gorm:"index" json:"group_id"`
 
 	// 来源信息
 	SourceKey   string    `gorm:"size:50;index;not null" json:"source_key"`
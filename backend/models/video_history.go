@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// VideoHistory 视频字段变更历史（每次更新只记录发生变化的字段）
+type VideoHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	VideoID         uint   `gorm:"index;not null" json:"video_id"`    // 对应videos表ID
+	VodID           int    `gorm:"index" json:"vod_id"`               // 源站视频ID
+	SourceKey       string `gorm:"size:50;index" json:"source_key"`   // 变更来源资源站
+	CollectionLogID uint   `gorm:"index" json:"collection_log_id"`    // 采集任务ID（0表示非采集产生的变更）
+	Action          string `gorm:"size:20;index" json:"action"`       // import, restore, admin
+	Changes         string `gorm:"type:text;not null" json:"changes"` // 字段级差异 JSON: {"字段": {"old": x, "new": y}}
+	ChangedFields   string `gorm:"size:1000" json:"changed_fields"`   // 变更字段列表（逗号分隔，便于检索）
+}
+
+// TableName 指定表名
+func (VideoHistory) TableName() string {
+	return "video_histories"
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Webhook 事件通知订阅
+type Webhook struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name     string `gorm:"size:100" json:"name"`
+	URL      string `gorm:"size:1000;not null" json:"url"`
+	Secret   string `gorm:"size:200" json:"-"`       // 签名密钥（不在接口中返回）
+	Events   string `gorm:"size:1000" json:"events"` // 订阅的事件（逗号分隔，为空或 * 表示全部）
+	IsActive bool   `gorm:"default:true;index" json:"is_active"`
+}
+
+// WebhookDelivery Webhook 投递记录（同一条记录在重试时更新）
+type WebhookDelivery struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	WebhookID    uint       `gorm:"index;not null" json:"webhook_id"`
+	Event        string     `gorm:"size:50;index" json:"event"`
+	Payload      string     `gorm:"type:text;not null" json:"payload"` // 请求体（重试时原样发送）
+	Status       string     `gorm:"size:20;index" json:"status"`       // pending, sending, success, failed
+	Attempts     int        `gorm:"default:0" json:"attempts"`         // 已尝试次数
+	NextRetryAt  *time.Time `gorm:"index" json:"next_retry_at"`        // 下次发送时间
+	ResponseCode int        `json:"response_code"`                     // 最近一次响应状态码
+	ResponseBody string     `gorm:"size:1000" json:"response_body"`    // 最近一次响应内容（截断）
+	Error        string     `gorm:"size:500" json:"error"`             // 最近一次错误
+	DurationMs   int64      `json:"duration_ms"`                       // 最近一次耗时
+	DeliveredAt  *time.Time `json:"delivered_at"`                      // 投递成功时间
+}
+
+// TableName 指定表名
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -15,6 +15,10 @@ func SetupRoutes(r *gin.Engine) {
 	// 创建处理器实例
 	mappingAdminHandler := handles.NewMappingAdminHandler(db)
 	sourceDiscoveryHandler := handles.NewSourceDiscoveryHandler(db)
+	videoAdminHandler := handles.NewVideoAdminHandler(db)
+	webhookHandler := handles.NewWebhookHandler(db)
+	playCheckHandler := handles.NewPlayCheckHandler(db)
+	standardCategoryHandler := handles.NewStandardCategoryHandler(db)
 
 	// ============ 公开API（无需认证）============
 	public := r.Group("/api")
@@ -27,16 +31,43 @@ func SetupRoutes(r *gin.Engine) {
 		public.GET("/videos/detail", handles.GetVideoByID)
 		public.GET("/videos/play", handles.GetVideoPlayURL) // 获取播放地址
 		public.GET("/videos/stats", handles.GetVideoStats)
+		public.GET("/videos/letters", handles.GetVideoLetters) // 首字母浏览
 
 		// 分类查询（只读）
 		public.GET("/video-types", handles.GetVideoTypes)
 		public.GET("/video-types/stats", handles.GetVideoTypeStats)
 		public.GET("/categories", handles.GetStandardCategories)
+		public.GET("/categories/tree", handles.GetStandardCategoryTree) // 按排序的分类列表（含图标）
+
+		// 播放列表导出（M3U）
+		public.GET("/playlist", handles.ExportPlaylist)
+		public.GET("/playlist/videos/:vod_id", handles.ExportVideoPlaylist)
+		public.GET("/playlist/categories/:category_id", handles.ExportCategoryPlaylist)
+
+		// 订阅源（RSS / Atom）
+		public.GET("/feeds/new", handles.GetNewVideosFeed)
+		public.GET("/feeds/updated", handles.GetUpdatedSeriesFeed)
+		public.GET("/feeds/categories/:category_id", handles.GetCategoryFeed)
+
+		// 封面图片代理（本地缓存 + 缩放）
+		public.GET("/images/videos/:vod_id", handles.GetVideoImage)
+
+		// TVBox 接口
+		public.GET("/tvbox/vod", handles.TVBoxVod)
+		public.GET("/tvbox/config", handles.TVBoxConfig)
 
 		// 数据源查询（只读）
 		public.GET("/sources", handles.GetSources)
 	}
 
+	// ============ 苹果CMS资源站接口（供其他站点采集）============
+	r.GET("/api.php/provide/vod", handles.ProvideVod)
+	r.GET("/api.php/provide/vod/at/:at", handles.ProvideVod) // 兼容 /api.php/provide/vod/at/xml 写法
+
+	// ============ 站点地图（供搜索引擎抓取）============
+	r.GET("/sitemap.xml", handles.GetSitemapIndex)
+	r.GET("/sitemaps/:file", handles.GetSitemap)
+
 	// ============ 管理员API（需要认证）============
 	admin := r.Group("/api/admin")
 	admin.Use(middleware.AdminAuth())
@@ -59,11 +90,24 @@ func SetupRoutes(r *gin.Engine) {
 		admin.GET("/video-types/unified", handles.GetUnifiedTypes)
 		admin.GET("/category-mappings", handles.GetCategoryMappings)
 
+		// 【标准分类管理】
+		admin.GET("/standard-categories", standardCategoryHandler.ListStandardCategories)
+		admin.POST("/standard-categories", standardCategoryHandler.CreateStandardCategory)
+		admin.POST("/standard-categories/reorder", standardCategoryHandler.ReorderStandardCategories)
+		admin.GET("/standard-categories/export", standardCategoryHandler.ExportStandardCategories)
+		admin.POST("/standard-categories/import", standardCategoryHandler.ImportStandardCategories)
+		admin.PUT("/standard-categories/:id", standardCategoryHandler.UpdateStandardCategory)
+		admin.DELETE("/standard-categories/:id", standardCategoryHandler.DeleteStandardCategory)
+		admin.POST("/standard-categories/:id/subcategories", standardCategoryHandler.CreateStandardSubCategory)
+		admin.PUT("/standard-subcategories/:id", standardCategoryHandler.UpdateStandardSubCategory)
+		admin.DELETE("/standard-subcategories/:id", standardCategoryHandler.DeleteStandardSubCategory)
+
 		// 【映射规则管理】
 		admin.GET("/unmapped-categories", mappingAdminHandler.GetUnmappedCategories)
 		admin.GET("/unmapped-categories/review", mappingAdminHandler.ReviewUnmappedCategories)
 		admin.POST("/unmapped-categories/batch-apply", mappingAdminHandler.BatchApplyUnmappedCategories)
 		admin.POST("/category-mapping/apply", mappingAdminHandler.ApplyCategoryMapping)
+		admin.GET("/category-mapping/explain", mappingAdminHandler.ExplainCategoryMapping)
 
 		admin.GET("/mapping-rules", mappingAdminHandler.GetMappingRules)
 		admin.GET("/mapping-rules/preview", mappingAdminHandler.PreviewMappingRules)
@@ -74,12 +118,54 @@ func SetupRoutes(r *gin.Engine) {
 
 		admin.GET("/fuzzy-rules", mappingAdminHandler.GetFuzzyMatchRules)
 		admin.POST("/fuzzy-rules", mappingAdminHandler.AddFuzzyMatchRule)
+		admin.GET("/subcategory-rules", mappingAdminHandler.GetSubCategoryRules)
+		admin.POST("/subcategory-rules", mappingAdminHandler.AddSubCategoryRule)
+		admin.PUT("/subcategory-rules/:id", mappingAdminHandler.UpdateSubCategoryRule)
+		admin.DELETE("/subcategory-rules/:id", mappingAdminHandler.DeleteSubCategoryRule)
 		admin.GET("/mapping-stats", mappingAdminHandler.GetMappingStats)
+		admin.GET("/mapping-changesets", mappingAdminHandler.ListMappingChangesets)
+		admin.GET("/mapping-changesets/diff", mappingAdminHandler.DiffMappingChangesets)
+		admin.GET("/mapping-changesets/:id", mappingAdminHandler.GetMappingChangeset)
+		admin.POST("/mapping-changesets/:id/rollback", mappingAdminHandler.RollbackMappingChangeset)
+		admin.POST("/recategorize", mappingAdminHandler.Recategorize)
+		admin.GET("/recategorize", mappingAdminHandler.GetRecategorizeStatus)
+
+		// 【视频管理】
+		admin.GET("/videos", videoAdminHandler.ListVideos)
+		admin.POST("/videos", videoAdminHandler.CreateVideo)
+		admin.GET("/videos/:id", videoAdminHandler.GetVideo)
+		admin.PUT("/videos/:id", videoAdminHandler.UpdateVideo)
+		admin.DELETE("/videos/:id", videoAdminHandler.DeleteVideo)
+		admin.GET("/videos/:id/history", videoAdminHandler.GetVideoHistory)
+		admin.POST("/videos/history/restore", videoAdminHandler.RestoreVideoHistory)
+		admin.POST("/videos/:id/lock", videoAdminHandler.LockVideoFields)
+		admin.POST("/videos/:id/unlock", videoAdminHandler.UnlockVideoFields)
 
 		// 【采集管理】
 		admin.POST("/collect", handles.CollectVideos)
 		admin.GET("/collection-logs", handles.GetCollectionLogs)
 		admin.POST("/import", handles.ImportJSON)
+
+		// 【播放地址检测】
+		admin.POST("/play-checks/run", playCheckHandler.StartPlayCheck)
+		admin.GET("/play-checks/run", playCheckHandler.GetPlayCheckStatus)
+		admin.GET("/play-checks", playCheckHandler.ListPlayChecks)
+		admin.GET("/play-checks/sources", playCheckHandler.GetSourceLinkHealth)
+		admin.POST("/play-checks/videos/:vod_id", playCheckHandler.CheckVideo)
+
+		// 【Webhook】
+		admin.GET("/webhooks/events", webhookHandler.GetWebhookEvents)
+		admin.GET("/webhooks", webhookHandler.ListWebhooks)
+		admin.POST("/webhooks", webhookHandler.CreateWebhook)
+		admin.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+		admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+		admin.POST("/webhooks/:id/test", webhookHandler.TestWebhook)
+		admin.GET("/webhooks/:id/deliveries", webhookHandler.GetWebhookDeliveries)
+		admin.POST("/webhook-deliveries/:id/redeliver", webhookHandler.RedeliverWebhook)
+
+		// 【媒体库导出】
+		admin.POST("/library/export", handles.StartLibraryExport)
+		admin.GET("/library/export", handles.GetLibraryExportStatus)
 	}
 }
 
@@ -6,9 +6,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"vodcms/config"
 	"vodcms/handles"
 	"vodcms/routes"
 	"vodcms/services"
+	"vodcms/utils"
 )
 
 type Server struct {
@@ -52,6 +54,12 @@ func (s *Server) Start() error {
 		log.Printf("⚠️ 同步数据源失败: %v\n", err)
 	}
 
+	// 启动 Webhook 投递
+	utils.StartWebhookWorker(config.GetDB())
+
+	// 启动播放地址定时检测
+	utils.StartPlayChecker(config.GetDB())
+
 	// 设置路由
 	routes.SetupRoutes(s.router)
 
@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 	"vodcms/models"
+	"vodcms/utils"
 
 	"gorm.io/gorm"
 )
@@ -56,6 +57,10 @@ func NewCategoryMappingService(configFile string, db *gorm.DB) *CategoryMappingS
 	}
 	service.LoadConfig()
 	service.InitializeMappingRules()
+	if db != nil {
+		utils.EnsureSourceMappingRules(db)
+		utils.EnsureSubCategoryRules(db, utils.GetTaxonomy())
+	}
 	return service
 }
 
@@ -78,79 +83,14 @@ func (s *CategoryMappingService) LoadConfig() error {
 	return nil
 }
 
-// MapCategory 映射分类
-func (s *CategoryMappingService) MapCategory(sourceKey string, sourceTypeID int, sourceTypeName string) (standardID int, standardSubID *int, standardName string, standardSubName string) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	// 默认值
-	standardID = 99
-	standardName = "其他"
-	standardSubID = nil
-	standardSubName = ""
-
-	if s.config == nil {
-		return
-	}
-
-	// 查找资源站映射
-	sourceMapping, exists := s.config.SourceMappings[sourceKey]
-	if !exists {
-		return
-	}
-
-	// 查找匹配的映射
-	for _, mapping := range sourceMapping.Mappings {
-		if mapping.SourceTypeID == sourceTypeID {
-			standardID = mapping.StandardID
-			standardSubID = mapping.StandardSubID
-
-			// 获取标准分类名称
-			if stdCat, ok := s.config.StandardCategories[fmt.Sprintf("%d", standardID)]; ok {
-				standardName = stdCat.Name
-
-				// 获取子分类名称
-				if standardSubID != nil {
-					if subName, ok := stdCat.Subcategories[fmt.Sprintf("%d", *standardSubID)]; ok {
-						standardSubName = subName
-					}
-				}
-			}
-			return
-		}
-	}
-
-	// 如果没有找到映射，尝试通过名称匹配
-	for _, mapping := range sourceMapping.Mappings {
-		if mapping.SourceName == sourceTypeName {
-			standardID = mapping.StandardID
-			standardSubID = mapping.StandardSubID
-
-			if stdCat, ok := s.config.StandardCategories[fmt.Sprintf("%d", standardID)]; ok {
-				standardName = stdCat.Name
-				if standardSubID != nil {
-					if subName, ok := stdCat.Subcategories[fmt.Sprintf("%d", *standardSubID)]; ok {
-						standardSubName = subName
-					}
-				}
-			}
-			return
-		}
-	}
-
-	return
+// MapCategory 映射分类（使用统一的映射引擎，见 utils.CategoryMapper）
+func (s *CategoryMappingService) MapCategory(sourceKey string, sourceTypeID int, sourceTypeName string) utils.CategoryDecision {
+	return utils.MapCategory(s.db, utils.CategoryInput{SourceKey: sourceKey, SourceTypeID: sourceTypeID, TypeName: sourceTypeName})
 }
 
-// GetStandardCategories 获取所有标准分类
-func (s *CategoryMappingService) GetStandardCategories() map[string]StandardCategory {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	if s.config == nil {
-		return nil
-	}
-
-	return s.config.StandardCategories
+// GetStandardCategories 获取所有标准分类（来自标准分类缓存）
+func (s *CategoryMappingService) GetStandardCategories() []utils.TaxonomyCategory {
+	return utils.GetTaxonomy().Categories
 }
 
 // GetSourceMappings 获取指定资源站的映射
@@ -178,8 +118,9 @@ func (s *CategoryMappingService) GetCategoryStats() map[string]interface{} {
 		return nil
 	}
 
+	categories := utils.GetTaxonomy().Categories
 	stats := map[string]interface{}{
-		"standard_category_count": len(s.config.StandardCategories),
+		"standard_category_count": len(categories),
 		"source_count":            len(s.config.SourceMappings),
 	}
 
@@ -192,7 +133,7 @@ func (s *CategoryMappingService) GetCategoryStats() map[string]interface{} {
 
 	// 统计子分类数量
 	subcategoryCount := 0
-	for _, cat := range s.config.StandardCategories {
+	for _, cat := range categories {
 		subcategoryCount += len(cat.Subcategories)
 	}
 	stats["subcategory_count"] = subcategoryCount
@@ -200,18 +141,11 @@ func (s *CategoryMappingService) GetCategoryStats() map[string]interface{} {
 	return stats
 }
 
-// InitializeMappingRules 初始化映射规则（从JSON配置导入到数据库）
+// InitializeMappingRules 初始化默认模糊规则（首次启动时）
+// category_mapping.json 的 source_mappings 由 utils.EnsureSourceMappingRules 导入
 func (s *CategoryMappingService) InitializeMappingRules() error {
 	if s.db == nil {
-		return nil // 如果没有数据库，仅使用JSON配置
-	}
-
-	s.mu.RLock()
-	config := s.config
-	s.mu.RUnlock()
-
-	if config == nil {
-		return fmt.Errorf("配置文件未加载")
+		return nil
 	}
 
 	// 检查是否已经初始化
@@ -221,25 +155,6 @@ func (s *CategoryMappingService) InitializeMappingRules() error {
 		return nil // 已有规则，不重复初始化
 	}
 
-	// 从JSON配置导入映射规则到数据库
-	for sourceKey, sourceMapping := range config.SourceMappings {
-		for _, mapping := range sourceMapping.Mappings {
-			rule := models.MappingRule{
-				SourceKey:     sourceKey,
-				SourceTypeID:  mapping.SourceTypeID,
-				SourceName:    mapping.SourceName,
-				StandardID:    mapping.StandardID,
-				StandardSubID: mapping.StandardSubID,
-				Priority:      100,
-				MatchType:     "exact",
-				IsActive:      true,
-			}
-			if err := s.db.Create(&rule).Error; err != nil {
-				return fmt.Errorf("创建映射规则失败: %w", err)
-			}
-		}
-	}
-
 	// 创建一些默认的模糊匹配规则
 	fuzzyRules := []models.FuzzyMatchRule{
 		{Pattern: "动作|武侠|功夫", StandardID: 1, StandardSubID: intPtr(101), Priority: 200, IsActive: true},
@@ -263,126 +178,6 @@ func (s *CategoryMappingService) InitializeMappingRules() error {
 	return nil
 }
 
-// MapCategoryEnhanced 增强版映射（支持数据库规则 + 自动学习）
-func (s *CategoryMappingService) MapCategoryEnhanced(sourceKey string, sourceTypeID int, sourceTypeName string) (standardID int, standardSubID *int, standardName string, standardSubName string) {
-	// 1. 优先从数据库查找精确匹配规则
-	if s.db != nil {
-		var rule models.MappingRule
-		err := s.db.Where("source_key = ? AND source_type_id = ? AND is_active = ?",
-			sourceKey, sourceTypeID, true).
-			Order("priority ASC").
-			First(&rule).Error
-
-		if err == nil {
-			standardID = rule.StandardID
-			standardSubID = rule.StandardSubID
-			standardName, standardSubName = s.getStandardCategoryNames(standardID, standardSubID)
-			return
-		}
-	}
-
-	// 2. 尝试从JSON配置查找（向后兼容）
-	standardID, standardSubID, standardName, standardSubName = s.MapCategory(sourceKey, sourceTypeID, sourceTypeName)
-
-	// 如果找到映射就返回
-	if standardID != 99 {
-		return
-	}
-
-	// 3. 尝试模糊匹配
-	if s.db != nil && sourceTypeName != "" {
-		var fuzzyRule models.FuzzyMatchRule
-		err := s.db.Where("is_active = ?", true).
-			Order("priority ASC").
-			Find(&fuzzyRule).Error
-
-		if err == nil {
-			// 使用 LIKE 或正则匹配（这里简化为包含匹配）
-			keywords := strings.Split(fuzzyRule.Pattern, "|")
-			for _, keyword := range keywords {
-				if strings.Contains(sourceTypeName, keyword) {
-					standardID = fuzzyRule.StandardID
-					standardSubID = fuzzyRule.StandardSubID
-					standardName, standardSubName = s.getStandardCategoryNames(standardID, standardSubID)
-
-					// 记录这次成功的模糊匹配，建议添加为精确规则
-					s.recordUnmappedCategory(sourceKey, sourceTypeID, sourceTypeName, &standardID, standardSubID)
-					return
-				}
-			}
-		}
-	}
-
-	// 4. 未找到映射，记录为未映射分类
-	s.recordUnmappedCategory(sourceKey, sourceTypeID, sourceTypeName, nil, nil)
-
-	// 返回默认值
-	standardID = 99
-	standardName = "其他"
-	return
-}
-
-// recordUnmappedCategory 记录未映射的分类
-func (s *CategoryMappingService) recordUnmappedCategory(sourceKey string, sourceTypeID int, sourceTypeName string, suggestedID *int, suggestedSubID *int) {
-	if s.db == nil {
-		return
-	}
-
-	var unmapped models.UnmappedCategory
-	result := s.db.Where("source_key = ? AND source_type_id = ?", sourceKey, sourceTypeID).First(&unmapped)
-
-	if result.Error == gorm.ErrRecordNotFound {
-		// 创建新记录
-		unmapped = models.UnmappedCategory{
-			SourceKey:      sourceKey,
-			SourceTypeID:   sourceTypeID,
-			SourceName:     sourceTypeName,
-			VideoCount:     1,
-			Status:         "pending",
-			SuggestedID:    suggestedID,
-			SuggestedSubID: suggestedSubID,
-		}
-		s.db.Create(&unmapped)
-	} else {
-		// 更新现有记录
-		updates := map[string]interface{}{
-			"last_seen_at": time.Now(),
-			"video_count":  gorm.Expr("video_count + 1"),
-		}
-		if suggestedID != nil {
-			updates["suggested_id"] = *suggestedID
-			updates["suggested_sub_id"] = suggestedSubID
-		}
-		s.db.Model(&unmapped).Updates(updates)
-	}
-}
-
-// getStandardCategoryNames 获取标准分类名称
-func (s *CategoryMappingService) getStandardCategoryNames(standardID int, standardSubID *int) (string, string) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	if s.config == nil {
-		return "", ""
-	}
-
-	stdCat, ok := s.config.StandardCategories[fmt.Sprintf("%d", standardID)]
-	if !ok {
-		return "", ""
-	}
-
-	standardName := stdCat.Name
-	standardSubName := ""
-
-	if standardSubID != nil {
-		if subName, ok := stdCat.Subcategories[fmt.Sprintf("%d", *standardSubID)]; ok {
-			standardSubName = subName
-		}
-	}
-
-	return standardName, standardSubName
-}
-
 // GetUnmappedCategories 获取未映射的分类列表
 func (s *CategoryMappingService) GetUnmappedCategories(sourceKey string, status string) ([]models.UnmappedCategory, error) {
 	if s.db == nil {
@@ -469,12 +264,19 @@ func (s *CategoryMappingService) ExportMappingConfig() (*CategoryMappingConfig,
 		return nil, err
 	}
 
-	// 构建配置
+	// 构建配置（标准分类取自数据库）
 	config := &CategoryMappingConfig{
 		UpdatedAt:          time.Now().Format("2006-01-02 15:04:05"),
-		StandardCategories: s.config.StandardCategories,
+		StandardCategories: make(map[string]StandardCategory),
 		SourceMappings:     make(map[string]SourceCategoryMapping),
 	}
+	for _, cat := range utils.GetTaxonomy().Categories {
+		subcategories := make(map[string]string, len(cat.Subcategories))
+		for _, sub := range cat.Subcategories {
+			subcategories[strconv.Itoa(sub.ID)] = sub.Name
+		}
+		config.StandardCategories[strconv.Itoa(cat.ID)] = StandardCategory{ID: cat.ID, Name: cat.Name, Subcategories: subcategories}
+	}
 
 	// 按源分组
 	sourceMappings := make(map[string][]CategoryMapping)
@@ -91,10 +91,12 @@ func (vs *VideoService) CollectAndSave(mode handles.CollectMode, sourceKeys []st
 
 		db.Save(&log)
 
-		// 读取采集的JSON文件并保存到数据库
-		if err := utils.ImportVideoFromJSON(source.Key); err != nil {
+		// 读取采集的JSON文件并保存到数据库，导入结束后再触发采集事件
+		result, err := utils.ImportVideoFromJSON(source.Key, log.ID)
+		if err != nil {
 			fmt.Printf("⚠️ 导入数据库失败: %v\n", err)
 		}
+		utils.NotifyCollectionLog(db, &log, result, err)
 	}
 
 	return nil
@@ -108,7 +110,8 @@ func (vs *VideoService) SyncSourcesToDB() error {
 		return err
 	}
 
-	sources := vs.sourceManager.GetDefaultSources()
+	// 以配置文件为准（配置文件不存在时 LoadSources 会写入默认数据源）
+	sources := vs.sourceManager.GetAllSources()
 
 	for _, s := range sources {
 		var dbSource models.Source
@@ -121,6 +124,7 @@ func (vs *VideoService) SyncSourcesToDB() error {
 				BaseURL: s.BaseURL,
 				Key:     s.Key,
 				Enabled: s.Enabled,
+				Quality: s.Quality,
 			}
 			db.Create(&dbSource)
 			fmt.Printf("✅ 已添加数据源: %s\n", s.Name)
@@ -129,6 +133,9 @@ func (vs *VideoService) SyncSourcesToDB() error {
 			dbSource.Name = s.Name
 			dbSource.BaseURL = s.BaseURL
 			dbSource.Enabled = s.Enabled
+			if dbSource.Quality == "" {
+				dbSource.Quality = s.Quality // 不覆盖管理员修改过的默认画质
+			}
 			db.Save(&dbSource)
 		}
 	}
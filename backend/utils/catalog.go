@@ -0,0 +1,16 @@
+package utils
+
+import "sync/atomic"
+
+// catalogVersion 视频目录版本号，导入或增删视频后递增，供站点地图等缓存判断是否失效
+var catalogVersion atomic.Int64
+
+// CatalogVersion 获取当前视频目录版本号
+func CatalogVersion() int64 {
+	return catalogVersion.Load()
+}
+
+// TouchCatalog 标记视频目录已变化
+func TouchCatalog() {
+	catalogVersion.Add(1)
+}
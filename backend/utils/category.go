@@ -0,0 +1,392 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"vodcms/config"
+	"vodcms/models"
+)
+
+// 标准分类体系
+// 1. 标准分类保存在 standard_categories / standard_sub_categories 表中，首次使用时加载到内存
+// 2. 表为空时从 category_mapping.json 导入一次，之后该文件只用于导入导出
+// 3. 管理接口修改分类后调用 ReloadTaxonomy 刷新缓存
+// 4. 没有映射到任何分类的视频归入内置的"其他"（ID 99），该分类不保存在表中
+
+const (
+	// MovieCategoryID 标准分类"电影"的ID
+	MovieCategoryID = 1
+	// OtherCategoryID 未映射视频使用的默认分类
+	OtherCategoryID   = 99
+	OtherCategoryName = "其他"
+
+	CategoryMappingFile = "category_mapping.json"
+)
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// StripHTML 去掉简介中的HTML标签并还原转义字符
+func StripHTML(s string) string {
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// TaxonomyCategory 一级分类及其子分类
+type TaxonomyCategory struct {
+	models.StandardCategory
+	Subcategories []models.StandardSubCategory `json:"subcategories"`
+}
+
+// Taxonomy 标准分类快照（只读，修改后整体替换）
+type Taxonomy struct {
+	Categories []TaxonomyCategory // 按 sort_order、ID 排序
+	byID       map[int]int
+	subByID    map[int]models.StandardSubCategory
+}
+
+var taxonomyCache struct {
+	sync.RWMutex
+	current *Taxonomy
+}
+
+// GetTaxonomy 获取标准分类（首次调用时从数据库加载）
+func GetTaxonomy() *Taxonomy {
+	taxonomyCache.RLock()
+	current := taxonomyCache.current
+	taxonomyCache.RUnlock()
+	if current != nil {
+		return current
+	}
+	if err := ReloadTaxonomy(config.GetDB()); err != nil {
+		fmt.Printf("⚠️ 加载标准分类失败: %v\n", err)
+		return &Taxonomy{byID: map[int]int{}, subByID: map[int]models.StandardSubCategory{}}
+	}
+	taxonomyCache.RLock()
+	defer taxonomyCache.RUnlock()
+	return taxonomyCache.current
+}
+
+// ReloadTaxonomy 从数据库重新加载标准分类（表为空时先从 category_mapping.json 导入）
+func ReloadTaxonomy(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.StandardCategory{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		if data, err := os.ReadFile(CategoryMappingFile); err == nil {
+			cats, subs, err := ImportTaxonomy(db, data)
+			if err != nil {
+				return fmt.Errorf("导入标准分类失败: %w", err)
+			}
+			fmt.Printf("📂 已从 %s 导入标准分类: %d 个分类，%d 个子分类\n", CategoryMappingFile, cats, subs)
+		}
+	}
+
+	var categories []models.StandardCategory
+	if err := db.Order("sort_order ASC, id ASC").Find(&categories).Error; err != nil {
+		return err
+	}
+	var subs []models.StandardSubCategory
+	if err := db.Order("sort_order ASC, id ASC").Find(&subs).Error; err != nil {
+		return err
+	}
+
+	t := &Taxonomy{
+		Categories: make([]TaxonomyCategory, 0, len(categories)),
+		byID:       make(map[int]int, len(categories)),
+		subByID:    make(map[int]models.StandardSubCategory, len(subs)),
+	}
+	for _, cat := range categories {
+		t.byID[cat.ID] = len(t.Categories)
+		t.Categories = append(t.Categories, TaxonomyCategory{StandardCategory: cat, Subcategories: []models.StandardSubCategory{}})
+	}
+	for _, sub := range subs {
+		i, ok := t.byID[sub.CategoryID]
+		if !ok {
+			continue // 所属分类已删除
+		}
+		t.Categories[i].Subcategories = append(t.Categories[i].Subcategories, sub)
+		t.subByID[sub.ID] = sub
+	}
+
+	taxonomyCache.Lock()
+	taxonomyCache.current = t
+	taxonomyCache.Unlock()
+	return nil
+}
+
+// Category 按ID查找一级分类
+func (t *Taxonomy) Category(id int) (*TaxonomyCategory, bool) {
+	i, ok := t.byID[id]
+	if !ok {
+		return nil, false
+	}
+	return &t.Categories[i], true
+}
+
+// SubCategory 按ID查找子分类
+func (t *Taxonomy) SubCategory(id int) (models.StandardSubCategory, bool) {
+	sub, ok := t.subByID[id]
+	return sub, ok
+}
+
+// Names 获取分类及子分类名称（找不到或子分类不属于该分类时返回空字符串）
+func (t *Taxonomy) Names(standardID int, standardSubID *int) (string, string) {
+	if standardID == OtherCategoryID {
+		return OtherCategoryName, ""
+	}
+	cat, ok := t.Category(standardID)
+	if !ok {
+		return "", ""
+	}
+	if standardSubID != nil {
+		if sub, ok := t.subByID[*standardSubID]; ok && sub.CategoryID == standardID {
+			return cat.Name, sub.Name
+		}
+	}
+	return cat.Name, ""
+}
+
+// StandardCategoryNames 获取标准分类及子分类名称（找不到时返回空字符串）
+func StandardCategoryNames(standardID int, standardSubID *int) (string, string) {
+	return GetTaxonomy().Names(standardID, standardSubID)
+}
+
+// StandardCategoryNode 标准分类节点（一级分类 ParentID 为0）
+type StandardCategoryNode struct {
+	ID       int    `json:"id"`
+	ParentID int    `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+// LoadStandardCategories 获取前台显示的标准分类，按排序展开，每个一级分类后紧跟它的子分类
+func LoadStandardCategories() ([]StandardCategoryNode, error) {
+	var nodes []StandardCategoryNode
+	for _, cat := range GetTaxonomy().Categories {
+		if !cat.IsVisible {
+			continue
+		}
+		nodes = append(nodes, StandardCategoryNode{ID: cat.ID, Name: cat.Name})
+		for _, sub := range cat.Subcategories {
+			if sub.IsVisible {
+				nodes = append(nodes, StandardCategoryNode{ID: sub.ID, ParentID: cat.ID, Name: sub.Name})
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// taxonomyFileCategory category_mapping.json 中的标准分类
+// subcategories 保持 "子分类ID: 名称" 的旧格式，排序、显示和图标放在可选的 subcategory_options 中
+type taxonomyFileCategory struct {
+	ID                 int                            `json:"id"`
+	Name               string                         `json:"name"`
+	SortOrder          *int                           `json:"sort_order,omitempty"`
+	IsVisible          *bool                          `json:"is_visible,omitempty"`
+	Icon               string                         `json:"icon,omitempty"`
+	Subcategories      map[string]string              `json:"subcategories"`
+	SubcategoryOptions map[string]taxonomyFileOptions `json:"subcategory_options,omitempty"`
+}
+
+type taxonomyFileOptions struct {
+	SortOrder *int   `json:"sort_order,omitempty"`
+	IsVisible *bool  `json:"is_visible,omitempty"`
+	Icon      string `json:"icon,omitempty"`
+}
+
+// ImportTaxonomy 从 category_mapping.json 格式导入标准分类（按ID新增或覆盖，不删除已有分类）
+// 子分类ID与一级分类ID重复时整体回滚
+func ImportTaxonomy(db *gorm.DB, data []byte) (categories int, subcategories int, err error) {
+	var file struct {
+		StandardCategories map[string]taxonomyFileCategory `json:"standard_categories"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, 0, fmt.Errorf("解析分类文件失败: %w", err)
+	}
+	if len(file.StandardCategories) == 0 {
+		return 0, 0, fmt.Errorf("分类文件中没有 standard_categories")
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for key, item := range file.StandardCategories {
+			if item.ID == 0 {
+				item.ID, _ = strconv.Atoi(key)
+			}
+			if item.ID <= 0 || item.ID == OtherCategoryID || strings.TrimSpace(item.Name) == "" {
+				return fmt.Errorf("无效的分类: %s", key)
+			}
+			cat := models.StandardCategory{
+				ID:        item.ID,
+				Name:      strings.TrimSpace(item.Name),
+				SortOrder: item.ID,
+				IsVisible: true,
+				Icon:      item.Icon,
+			}
+			if item.SortOrder != nil {
+				cat.SortOrder = *item.SortOrder
+			}
+			if item.IsVisible != nil {
+				cat.IsVisible = *item.IsVisible
+			}
+			if err := UpsertStandardCategory(tx, &cat); err != nil {
+				return err
+			}
+			categories++
+
+			for subKey, name := range item.Subcategories {
+				subID, err := strconv.Atoi(subKey)
+				if err != nil || subID <= 0 || strings.TrimSpace(name) == "" {
+					return fmt.Errorf("无效的子分类: %s", subKey)
+				}
+				sub := models.StandardSubCategory{
+					ID:         subID,
+					CategoryID: item.ID,
+					Name:       strings.TrimSpace(name),
+					SortOrder:  subID,
+					IsVisible:  true,
+				}
+				if opts, ok := item.SubcategoryOptions[subKey]; ok {
+					if opts.SortOrder != nil {
+						sub.SortOrder = *opts.SortOrder
+					}
+					if opts.IsVisible != nil {
+						sub.IsVisible = *opts.IsVisible
+					}
+					sub.Icon = opts.Icon
+				}
+				if err := UpsertStandardSubCategory(tx, &sub); err != nil {
+					return err
+				}
+				subcategories++
+			}
+		}
+
+		// 一级分类和子分类的ID不能重叠
+		var overlap []int
+		if err := tx.Model(&models.StandardSubCategory{}).
+			Where("id = ? OR id IN (?)", OtherCategoryID, tx.Model(&models.StandardCategory{}).Select("id")).
+			Pluck("id", &overlap).Error; err != nil {
+			return err
+		}
+		if len(overlap) > 0 {
+			return fmt.Errorf("子分类ID与一级分类ID重复: %v", overlap)
+		}
+		return nil
+	})
+	return categories, subcategories, err
+}
+
+// ExportTaxonomy 导出为 category_mapping.json 中 standard_categories 的格式
+func ExportTaxonomy() map[string]taxonomyFileCategory {
+	result := make(map[string]taxonomyFileCategory)
+	for _, cat := range GetTaxonomy().Categories {
+		sortOrder, visible := cat.SortOrder, cat.IsVisible
+		item := taxonomyFileCategory{
+			ID:                 cat.ID,
+			Name:               cat.Name,
+			SortOrder:          &sortOrder,
+			IsVisible:          &visible,
+			Icon:               cat.Icon,
+			Subcategories:      make(map[string]string, len(cat.Subcategories)),
+			SubcategoryOptions: make(map[string]taxonomyFileOptions, len(cat.Subcategories)),
+		}
+		for _, sub := range cat.Subcategories {
+			key := strconv.Itoa(sub.ID)
+			subSort, subVisible := sub.SortOrder, sub.IsVisible
+			item.Subcategories[key] = sub.Name
+			item.SubcategoryOptions[key] = taxonomyFileOptions{SortOrder: &subSort, IsVisible: &subVisible, Icon: sub.Icon}
+		}
+		result[strconv.Itoa(cat.ID)] = item
+	}
+	return result
+}
+
+// SaveTaxonomyToFile 把标准分类写回 category_mapping.json（保留文件中的其他内容，如 source_mappings）
+func SaveTaxonomyToFile() error {
+	file := make(map[string]json.RawMessage)
+	if data, err := os.ReadFile(CategoryMappingFile); err == nil {
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("解析分类文件失败: %w", err)
+		}
+	}
+
+	categories, err := json.Marshal(ExportTaxonomy())
+	if err != nil {
+		return err
+	}
+	updatedAt, _ := json.Marshal(time.Now().Format("2006-01-02 15:04:05"))
+	file["standard_categories"] = categories
+	file["updated_at"] = updatedAt
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(CategoryMappingFile, data, 0644)
+}
+
+// UpsertStandardCategory 按ID新增或覆盖一级分类
+// 新增时 is_visible 的零值会被 default:true 覆盖，需在创建后单独更新
+func UpsertStandardCategory(db *gorm.DB, cat *models.StandardCategory) error {
+	var existing models.StandardCategory
+	if db.Limit(1).Find(&existing, cat.ID).RowsAffected > 0 {
+		cat.CreatedAt = existing.CreatedAt
+		return db.Save(cat).Error
+	}
+	visible := cat.IsVisible
+	if err := db.Create(cat).Error; err != nil {
+		return err
+	}
+	if !visible {
+		cat.IsVisible = false
+		return db.Model(cat).Update("is_visible", false).Error
+	}
+	return nil
+}
+
+// UpsertStandardSubCategory 按ID新增或覆盖子分类
+func UpsertStandardSubCategory(db *gorm.DB, sub *models.StandardSubCategory) error {
+	var existing models.StandardSubCategory
+	if db.Limit(1).Find(&existing, sub.ID).RowsAffected > 0 {
+		sub.CreatedAt = existing.CreatedAt
+		return db.Save(sub).Error
+	}
+	visible := sub.IsVisible
+	if err := db.Create(sub).Error; err != nil {
+		return err
+	}
+	if !visible {
+		sub.IsVisible = false
+		return db.Model(sub).Update("is_visible", false).Error
+	}
+	return nil
+}
+
+// SyncVideoCategoryNames 按当前标准分类更新视频中冗余存储的分类名称，返回更新的视频数
+func SyncVideoCategoryNames(db *gorm.DB) int64 {
+	var updated int64
+	for _, cat := range GetTaxonomy().Categories {
+		updated += db.Model(&models.Video{}).
+			Where("standard_category_id = ? AND standard_category_name <> ?", cat.ID, cat.Name).
+			Update("standard_category_name", cat.Name).RowsAffected
+		for _, sub := range cat.Subcategories {
+			updated += db.Model(&models.Video{}).
+				Where("standard_sub_category_id = ? AND standard_sub_category_name <> ?", sub.ID, sub.Name).
+				Update("standard_sub_category_name", sub.Name).RowsAffected
+		}
+	}
+	if updated > 0 {
+		TouchCatalog()
+	}
+	return updated
+}
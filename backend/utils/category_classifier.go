@@ -0,0 +1,438 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// 分类器
+// 朴素贝叶斯（多项式模型），特征为分类名称、视频标签、地区和片名的字符 n-gram
+// 1. 训练：./vodcms --mode=train-classifier，从已分类（非"其他"）的视频中学习，模型保存为 JSON 文件（CLASSIFIER_MODEL）
+// 2. 校准：每 10 个视频留 1 个做验证，按预测概率分档统计实际准确率，预测时用该准确率作为评分
+// 3. 使用：启动时加载模型，作为映射引擎的 classifier 阶段，同时为 keyword 阶段的结果评分
+
+const (
+	classifierModelVersion    = 1
+	classifierHoldoutMod      = 10 // ID 能被整除的视频作为验证集
+	classifierCalibrationBins = 10
+	classifierMinBinCount     = 5 // 样本不足的档位直接使用原始概率
+	classifierMinFeatureCount = 2 // 出现次数更少的特征不保存到模型
+	classifierMinSamples      = 50
+	classifierBatchSize       = 1000
+)
+
+// ClassifierMinScore 分类器评分低于该值时不给出结果
+const ClassifierMinScore = 0.5
+
+// ClassifierPrediction 分类器预测结果
+type ClassifierPrediction struct {
+	StandardID    int
+	StandardSubID *int
+	Score         float64 // 校准后的评分（0-1）
+	Explanation   string
+}
+
+// ClassifierLabel 一个标准分类（及子分类）的训练统计
+type ClassifierLabel struct {
+	StandardID    int            `json:"standard_id"`
+	StandardSubID *int           `json:"standard_sub_id,omitempty"`
+	Samples       int            `json:"samples"`
+	Tokens        int            `json:"tokens"` // 特征出现总次数
+	Features      map[string]int `json:"features"`
+}
+
+// ClassifierCalibrationBin 一档预测概率在验证集上的表现
+type ClassifierCalibrationBin struct {
+	Count   int `json:"count"`
+	Correct int `json:"correct"`
+}
+
+// NaiveBayesClassifier 朴素贝叶斯分类器（模型文件的内容）
+type NaiveBayesClassifier struct {
+	Version     int                        `json:"version"`
+	TrainedAt   time.Time                  `json:"trained_at"`
+	Samples     int                        `json:"samples"`
+	Vocabulary  int                        `json:"vocabulary"`
+	Holdout     int                        `json:"holdout"`  // 验证集样本数
+	Accuracy    float64                    `json:"accuracy"` // 验证集准确率
+	Labels      []*ClassifierLabel         `json:"labels"`
+	Calibration []ClassifierCalibrationBin `json:"calibration"`
+}
+
+// classifierFieldNames 特征前缀对应的字段（用于说明）
+var classifierFieldNames = map[string]string{
+	"t": "分类名称",
+	"c": "标签",
+	"a": "地区",
+	"n": "片名",
+}
+
+// TrainCategoryClassifier 用已分类的视频训练分类器
+func TrainCategoryClassifier(db *gorm.DB) (*NaiveBayesClassifier, error) {
+	type sample struct {
+		features []string
+		label    int
+	}
+
+	model := &NaiveBayesClassifier{Version: classifierModelVersion, TrainedAt: time.Now()}
+	labelIndex := make(map[string]int)
+	var holdout []sample
+
+	var batch []models.Video
+	err := db.Model(&models.Video{}).
+		Select("id, type_name, vod_class, vod_area, vod_name, standard_category_id, standard_sub_category_id").
+		Where("standard_category_id > 0 AND standard_category_id <> ?", OtherCategoryID).
+		FindInBatches(&batch, classifierBatchSize, func(_ *gorm.DB, _ int) error {
+			for _, video := range batch {
+				features := classifierFeatures(VideoCategoryInput(&video))
+				if len(features) == 0 {
+					continue
+				}
+				label := model.label(labelIndex, video.StandardCategoryID, video.StandardSubCategoryID)
+				if video.ID%classifierHoldoutMod == 0 {
+					holdout = append(holdout, sample{features, label})
+					continue
+				}
+				model.add(label, features)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("读取训练数据失败: %w", err)
+	}
+	if model.Samples < classifierMinSamples || len(model.Labels) < 2 {
+		return nil, fmt.Errorf("训练数据不足：需要至少 %d 个已分类视频和 2 个分类，当前 %d 个视频、%d 个分类",
+			classifierMinSamples, model.Samples+len(holdout), len(model.Labels))
+	}
+
+	// 用验证集统计每档概率的实际准确率
+	model.Vocabulary = model.vocabulary()
+	model.Calibration = make([]ClassifierCalibrationBin, classifierCalibrationBins)
+	correct := 0
+	for _, s := range holdout {
+		posteriors := model.posteriors(s.features)
+		best := argmax(posteriors)
+		bin := &model.Calibration[calibrationBin(posteriors[best])]
+		bin.Count++
+		if best == s.label {
+			bin.Correct++
+			correct++
+		}
+	}
+	model.Holdout = len(holdout)
+	if len(holdout) > 0 {
+		model.Accuracy = float64(correct) / float64(len(holdout))
+	}
+
+	// 验证完成后验证集也加入训练
+	for _, s := range holdout {
+		model.add(s.label, s.features)
+	}
+	model.prune()
+	return model, nil
+}
+
+// LoadCategoryClassifier 读取模型文件
+func LoadCategoryClassifier(path string) (*NaiveBayesClassifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var model NaiveBayesClassifier
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("解析分类器模型失败: %w", err)
+	}
+	if model.Version != classifierModelVersion {
+		return nil, fmt.Errorf("分类器模型版本 %d 不受支持，请重新训练", model.Version)
+	}
+	if len(model.Labels) < 2 {
+		return nil, fmt.Errorf("分类器模型没有足够的分类")
+	}
+	return &model, nil
+}
+
+// UseCategoryClassifier 读取模型文件并设置为映射引擎的分类器
+func UseCategoryClassifier(path string) error {
+	model, err := LoadCategoryClassifier(path)
+	if err != nil {
+		return err
+	}
+	SetCategoryClassifier(model)
+	fmt.Printf("🧠 已加载分类器: %d 个分类，%d 个样本，验证准确率 %.1f%%\n", len(model.Labels), model.Samples, model.Accuracy*100)
+	return nil
+}
+
+// Save 保存模型文件（先写临时文件再替换）
+func (m *NaiveBayesClassifier) Save(path string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Predict 预测最可能的标准分类，评分低于 ClassifierMinScore 时 ok 为 false
+func (m *NaiveBayesClassifier) Predict(in CategoryInput) (ClassifierPrediction, bool) {
+	features := classifierFeatures(in)
+	if len(features) == 0 {
+		return ClassifierPrediction{}, false
+	}
+	posteriors := m.posteriors(features)
+	best := argmax(posteriors)
+	score := m.calibrate(posteriors[best])
+	if score < ClassifierMinScore {
+		return ClassifierPrediction{}, false
+	}
+
+	label := m.Labels[best]
+	explanation := fmt.Sprintf("分类器评分 %.2f", score)
+	if evidence := m.evidence(features, best, posteriors); evidence != "" {
+		explanation += "，依据" + evidence
+	}
+	return ClassifierPrediction{
+		StandardID:    label.StandardID,
+		StandardSubID: label.StandardSubID,
+		Score:         score,
+		Explanation:   explanation,
+	}, true
+}
+
+// Score 给指定的标准分类评分（standardSubID 为 nil 时合计该分类下所有子分类）
+// 模型中没有该分类的训练数据时 ok 为 false
+func (m *NaiveBayesClassifier) Score(in CategoryInput, standardID int, standardSubID *int) (float64, bool) {
+	features := classifierFeatures(in)
+	if len(features) == 0 {
+		return 0, false
+	}
+	posteriors := m.posteriors(features)
+	total, known := 0.0, false
+	for i, label := range m.Labels {
+		if label.StandardID != standardID {
+			continue
+		}
+		if standardSubID == nil || sameSubCategory(label.StandardSubID, standardSubID) {
+			total += posteriors[i]
+			known = true
+		}
+	}
+	if !known {
+		return 0, false
+	}
+	return m.calibrate(total), true
+}
+
+// label 获取（或新建）分类在模型中的下标
+func (m *NaiveBayesClassifier) label(index map[string]int, standardID int, standardSubID *int) int {
+	key := fmt.Sprintf("%d:%s", standardID, subIDKey(standardSubID))
+	if i, ok := index[key]; ok {
+		return i
+	}
+	m.Labels = append(m.Labels, &ClassifierLabel{StandardID: standardID, StandardSubID: standardSubID, Features: make(map[string]int)})
+	index[key] = len(m.Labels) - 1
+	return index[key]
+}
+
+func (m *NaiveBayesClassifier) add(label int, features []string) {
+	l := m.Labels[label]
+	l.Samples++
+	m.Samples++
+	for _, feature := range features {
+		l.Features[feature]++
+		l.Tokens++
+	}
+}
+
+// prune 删除出现次数过少的特征，减小模型文件
+func (m *NaiveBayesClassifier) prune() {
+	totals := make(map[string]int)
+	for _, label := range m.Labels {
+		for feature, count := range label.Features {
+			totals[feature] += count
+		}
+	}
+	for _, label := range m.Labels {
+		for feature, count := range label.Features {
+			if totals[feature] < classifierMinFeatureCount {
+				delete(label.Features, feature)
+				label.Tokens -= count
+			}
+		}
+	}
+	m.Vocabulary = m.vocabulary()
+}
+
+func (m *NaiveBayesClassifier) vocabulary() int {
+	seen := make(map[string]struct{})
+	for _, label := range m.Labels {
+		for feature := range label.Features {
+			seen[feature] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
+// logLikelihood 特征在分类下的对数概率（拉普拉斯平滑）
+func (m *NaiveBayesClassifier) logLikelihood(label *ClassifierLabel, feature string) float64 {
+	return math.Log(float64(label.Features[feature]+1) / float64(label.Tokens+m.Vocabulary+1))
+}
+
+// posteriors 各分类的后验概率
+func (m *NaiveBayesClassifier) posteriors(features []string) []float64 {
+	scores := make([]float64, len(m.Labels))
+	for i, label := range m.Labels {
+		score := math.Log(float64(label.Samples+1) / float64(m.Samples+len(m.Labels)))
+		for _, feature := range features {
+			score += m.logLikelihood(label, feature)
+		}
+		scores[i] = score
+	}
+
+	max := scores[argmax(scores)]
+	sum := 0.0
+	for i := range scores {
+		scores[i] = math.Exp(scores[i] - max)
+		sum += scores[i]
+	}
+	for i := range scores {
+		scores[i] /= sum
+	}
+	return scores
+}
+
+// calibrate 把原始概率换算为验证集上的实际准确率
+func (m *NaiveBayesClassifier) calibrate(p float64) float64 {
+	if len(m.Calibration) != classifierCalibrationBins {
+		return p
+	}
+	bin := m.Calibration[calibrationBin(p)]
+	if bin.Count < classifierMinBinCount {
+		return p
+	}
+	return float64(bin.Correct+1) / float64(bin.Count+2)
+}
+
+// evidence 对预测结果贡献最大的几个特征（与第二可能的分类相比）
+func (m *NaiveBayesClassifier) evidence(features []string, best int, posteriors []float64) string {
+	second := -1
+	for i := range posteriors {
+		if i != best && (second < 0 || posteriors[i] > posteriors[second]) {
+			second = i
+		}
+	}
+	if second < 0 {
+		return ""
+	}
+
+	type contribution struct {
+		feature string
+		weight  float64
+	}
+	seen := make(map[string]bool)
+	var contributions []contribution
+	for _, feature := range features {
+		if seen[feature] {
+			continue
+		}
+		seen[feature] = true
+		weight := m.logLikelihood(m.Labels[best], feature) - m.logLikelihood(m.Labels[second], feature)
+		if weight > 0 {
+			contributions = append(contributions, contribution{feature, weight})
+		}
+	}
+	sort.Slice(contributions, func(i, j int) bool {
+		if contributions[i].weight != contributions[j].weight {
+			return contributions[i].weight > contributions[j].weight
+		}
+		if li, lj := len(contributions[i].feature), len(contributions[j].feature); li != lj {
+			return li > lj // 权重相同时优先显示较长的词
+		}
+		return contributions[i].feature < contributions[j].feature
+	})
+
+	// 同一字段中已包含在已选词里的 n-gram 不再重复显示
+	chosen := make(map[string][]string)
+	var parts []string
+	for _, c := range contributions {
+		if len(parts) == 3 {
+			break
+		}
+		prefix, value := c.feature[:1], c.feature[2:]
+		duplicate := false
+		for _, existing := range chosen[prefix] {
+			if strings.Contains(existing, value) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		chosen[prefix] = append(chosen[prefix], value)
+		parts = append(parts, fmt.Sprintf("%s\"%s\"", classifierFieldNames[prefix], value))
+	}
+	return strings.Join(parts, "、")
+}
+
+// classifierFeatures 提取特征：各字段的字符 1-gram 和 2-gram（片名只取 2-gram），标签和地区另加整词
+// 特征格式为 "前缀:值"（n-gram）或 "前缀=值"（整词）
+func classifierFeatures(in CategoryInput) []string {
+	var features []string
+	addGrams := func(prefix, value string, minN int) {
+		runes := []rune(strings.Join(strings.Fields(normalizeCategoryName(value)), ""))
+		if len(runes) < minN {
+			minN = len(runes)
+		}
+		for n := minN; n <= 2; n++ {
+			for i := 0; n > 0 && i+n <= len(runes); i++ {
+				features = append(features, prefix+":"+string(runes[i:i+n]))
+			}
+		}
+	}
+	addWords := func(prefix, value string) {
+		for _, word := range splitLibraryNames(value) {
+			word = normalizeCategoryName(word)
+			features = append(features, prefix+"="+word)
+			addGrams(prefix, word, 1)
+		}
+	}
+
+	addGrams("t", in.TypeName, 1)
+	addWords("c", in.VodClass)
+	addWords("a", in.VodArea)
+	addGrams("n", in.Title, 2)
+	return features
+}
+
+func calibrationBin(p float64) int {
+	bin := int(p * classifierCalibrationBins)
+	if bin >= classifierCalibrationBins {
+		bin = classifierCalibrationBins - 1
+	}
+	if bin < 0 {
+		bin = 0
+	}
+	return bin
+}
+
+func argmax(values []float64) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
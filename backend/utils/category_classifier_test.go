@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"vodcms/models"
+)
+
+// seedClassifierVideos 写入训练数据：动作片和国产剧各 n 个，另有不参与训练的"其他"
+func seedClassifierVideos(t *testing.T, n int) *NaiveBayesClassifier {
+	t.Helper()
+	db := newTestDB(t)
+	action, drama := 101, 201
+	var videos []models.Video
+	for i := 0; i < n; i++ {
+		videos = append(videos,
+			models.Video{VodID: 2*i + 1, VodName: fmt.Sprintf("动作电影%d", i), SourceKey: "test", TypeName: "动作片", VodClass: "动作,犯罪", VodArea: "香港",
+				StandardCategoryID: 1, StandardSubCategoryID: &action},
+			models.Video{VodID: 2*i + 2, VodName: fmt.Sprintf("都市剧集%d", i), SourceKey: "test", TypeName: "国产剧", VodClass: "都市,爱情", VodArea: "大陆",
+				StandardCategoryID: 2, StandardSubCategoryID: &drama},
+		)
+	}
+	videos = append(videos, models.Video{VodID: 9999, VodName: "未分类", SourceKey: "test", TypeName: "火星", StandardCategoryID: OtherCategoryID})
+	if err := db.CreateInBatches(&videos, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+	model, err := TrainCategoryClassifier(db)
+	if n*2 < classifierMinSamples {
+		if err == nil {
+			t.Fatalf("%d 个视频时应提示训练数据不足", n*2)
+		}
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("训练失败: %v", err)
+	}
+	return model
+}
+
+func TestTrainCategoryClassifier(t *testing.T) {
+	t.Run("训练数据不足", func(t *testing.T) {
+		if model := seedClassifierVideos(t, 10); model != nil {
+			t.Fatal("训练数据不足时不应返回模型")
+		}
+	})
+
+	model := seedClassifierVideos(t, 50)
+	// ID 能被 10 整除的视频作为验证集，验证后也加入训练；"其他"不参与训练
+	if model.Samples != 100 || model.Holdout != 10 || len(model.Labels) != 2 {
+		t.Fatalf("samples=%d holdout=%d labels=%d", model.Samples, model.Holdout, len(model.Labels))
+	}
+	if model.Accuracy != 1 {
+		t.Errorf("验证准确率 %.2f，期望 1", model.Accuracy)
+	}
+	binned := 0
+	for _, bin := range model.Calibration {
+		binned += bin.Count
+	}
+	if binned != model.Holdout {
+		t.Errorf("校准档位共 %d 个样本，期望 %d", binned, model.Holdout)
+	}
+	// 只出现一次的特征被删除（"影0"只出现在"动作电影0"中，"影1"出现在"动作电影1""动作电影10"等中）
+	features := model.Labels[0].Features
+	if _, ok := features["n:影0"]; ok {
+		t.Error("出现次数过少的特征没有删除")
+	}
+	if features["n:影1"] != 11 {
+		t.Errorf("特征 n:影1 出现 %d 次，期望 11 次", features["n:影1"])
+	}
+
+	tests := []struct {
+		name      string
+		in        CategoryInput
+		wantOK    bool
+		wantID    int
+		wantSubID int
+	}{
+		{"分类名称", CategoryInput{TypeName: "动作片"}, true, 1, 101},
+		{"标签和地区", CategoryInput{VodClass: "都市", VodArea: "大陆"}, true, 2, 201},
+		{"没有特征", CategoryInput{}, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prediction, ok := model.Predict(tt.in)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v，期望 %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if prediction.StandardID != tt.wantID || prediction.StandardSubID == nil || *prediction.StandardSubID != tt.wantSubID {
+				t.Errorf("预测 %d/%v，期望 %d/%d", prediction.StandardID, prediction.StandardSubID, tt.wantID, tt.wantSubID)
+			}
+			if prediction.Score < ClassifierMinScore || prediction.Explanation == "" {
+				t.Errorf("评分 %.2f 说明 %q", prediction.Score, prediction.Explanation)
+			}
+			// 为其他分类评分时很低，模型中没有的分类无法评分
+			if score, ok := model.Score(tt.in, 3-tt.wantID, nil); !ok || score >= ClassifierMinScore {
+				t.Errorf("其他分类评分 %.2f/%v", score, ok)
+			}
+			if _, ok := model.Score(tt.in, 5, nil); ok {
+				t.Error("没有训练数据的分类不应有评分")
+			}
+		})
+	}
+
+	// 保存后重新读取，结果一致
+	path := filepath.Join(t.TempDir(), "classifier.json")
+	if err := model.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCategoryClassifier(path)
+	if err != nil {
+		t.Fatalf("读取模型失败: %v", err)
+	}
+	in := CategoryInput{TypeName: "动作片"}
+	want, _ := model.Predict(in)
+	got, _ := loaded.Predict(in)
+	if got.StandardID != want.StandardID || math.Abs(got.Score-want.Score) > 1e-9 {
+		t.Errorf("读取后的预测 %+v，期望 %+v", got, want)
+	}
+
+	loaded.Version = classifierModelVersion + 1
+	if err := loaded.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCategoryClassifier(path); err == nil {
+		t.Error("版本不同的模型应读取失败")
+	}
+}
+
+func TestClassifierCalibrate(t *testing.T) {
+	calibrated := make([]ClassifierCalibrationBin, classifierCalibrationBins)
+	calibrated[9] = ClassifierCalibrationBin{Count: 10, Correct: 8}
+	calibrated[5] = ClassifierCalibrationBin{Count: 20, Correct: 0}
+	calibrated[7] = ClassifierCalibrationBin{Count: classifierMinBinCount - 1, Correct: 0}
+
+	tests := []struct {
+		name        string
+		calibration []ClassifierCalibrationBin
+		p           float64
+		want        float64
+	}{
+		{"没有校准数据时使用原始概率", nil, 0.93, 0.93},
+		{"按档位准确率平滑", calibrated, 0.95, 9.0 / 12},
+		{"概率为1归入最高档", calibrated, 1, 9.0 / 12},
+		{"过度自信的档位被压低", calibrated, 0.55, 1.0 / 22},
+		{"样本不足的档位使用原始概率", calibrated, 0.75, 0.75},
+	}
+	for _, tt := range tests {
+		model := &NaiveBayesClassifier{Calibration: tt.calibration}
+		if got := model.calibrate(tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: calibrate(%.2f) = %.4f，期望 %.4f", tt.name, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestCalibrationBin(t *testing.T) {
+	tests := []struct {
+		p    float64
+		want int
+	}{
+		{-0.1, 0},
+		{0, 0},
+		{0.05, 0},
+		{0.15, 1},
+		{0.999, 9},
+		{1, 9},
+	}
+	for _, tt := range tests {
+		if got := calibrationBin(tt.p); got != tt.want {
+			t.Errorf("calibrationBin(%.3f) = %d，期望 %d", tt.p, got, tt.want)
+		}
+	}
+}
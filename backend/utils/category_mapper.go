@@ -0,0 +1,557 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// 分类映射引擎
+// 导入、重新分类、资源站发现和映射预览共用同一套映射流程，按以下顺序匹配，命中即停止：
+// 1. exact      数据库中启用的精确规则（source_key + source_type_id）；category_mapping.json 的 source_mappings 在启动时导入一次，见 EnsureSourceMappingRules
+// 2. name       同一资源站中分类名称相同的规则（资源站调整分类ID后仍能匹配），其次是标准分类名称和内置别名
+// 3. pattern    match_type 为 fuzzy/pattern 的映射规则，其次是模糊匹配规则（fuzzy_match_rules），见 category_pattern.go
+//               分类名称包含媒体类型关键词时，只使用指向该类型的规则
+// 4. keyword    内置关键词：先按媒体类型（电影/剧/综艺/动漫等）确定一级分类，地区和题材只用于选择子分类
+// 5. classifier 分类器（已设置时，见 category_classifier.go）
+// 6. default    归入"其他"
+// 之后按子分类规则推断子分类（见 subcategory.go）
+// 每次映射都返回命中的阶段、规则和说明，便于排查"为什么这个分类被映射到这里"
+// 评分（score）：exact/name 为 1，pattern 为 0.8，default 为 0；keyword 和 classifier 使用分类器的校准评分
+// keyword 阶段的结果分类器评分很低、而分类器对另一个分类把握较大时，改用分类器的结果
+
+// 映射阶段
+const (
+	MappingStageExact      = "exact"
+	MappingStageName       = "name"
+	MappingStagePattern    = "pattern"
+	MappingStageKeyword    = "keyword"
+	MappingStageClassifier = "classifier"
+	MappingStageDefault    = "default"
+)
+
+// CategoryInput 待映射的资源站分类（视频字段用于 pattern 阶段、分类器和子分类推断，按分类映射时可以为空）
+type CategoryInput struct {
+	SourceKey    string `json:"source_key"`
+	SourceTypeID int    `json:"source_type_id"`
+	TypeName     string `json:"type_name"`
+	VodClass     string `json:"vod_class,omitempty"`
+	VodArea      string `json:"vod_area,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Content      string `json:"content,omitempty"` // 简介，只用于子分类推断
+}
+
+// VideoCategoryInput 视频对应的映射输入
+func VideoCategoryInput(video *models.Video) CategoryInput {
+	return CategoryInput{
+		SourceKey:    video.SourceKey,
+		SourceTypeID: video.TypeID,
+		TypeName:     video.TypeName,
+		VodClass:     video.VodClass,
+		VodArea:      video.VodArea,
+		Title:        video.VodName,
+		Content:      videoSummary(video),
+	}
+}
+
+// videoSummary 视频简介（优先使用较短的 vod_blurb）
+func videoSummary(video *models.Video) string {
+	if strings.TrimSpace(video.VodBlurb) != "" {
+		return video.VodBlurb
+	}
+	return video.VodContent
+}
+
+// CategoryDecision 映射结果
+type CategoryDecision struct {
+	StandardID      int     `json:"standard_id"`
+	StandardSubID   *int    `json:"standard_sub_id"`
+	StandardName    string  `json:"standard_name"`
+	StandardSubName string  `json:"standard_sub_name"`
+	Stage           string  `json:"stage"`             // 命中的阶段
+	Confidence      string  `json:"confidence"`        // high/medium/low
+	Score           float64 `json:"score"`             // 0-1，见 ScoreConfidence
+	SubConfidence   float64 `json:"sub_confidence"`    // 子分类的置信度（0-1），见 subcategory.go
+	RuleID          uint    `json:"rule_id,omitempty"` // 命中的 mapping_rules 或 fuzzy_match_rules 记录
+	ByRule          bool    `json:"by_rule"`           // 由映射规则（mapping_rules，含 fuzzy/pattern 规则）确定，其余阶段的结果只是推测
+	Explanation     string  `json:"explanation"`
+}
+
+// CategoryClassifier 分类器
+type CategoryClassifier interface {
+	// Predict 预测标准分类，ok 为 false 表示无法判断
+	Predict(in CategoryInput) (prediction ClassifierPrediction, ok bool)
+	// Score 给指定的标准分类评分
+	Score(in CategoryInput, standardID int, standardSubID *int) (score float64, ok bool)
+}
+
+// 各阶段的固定评分和评分对应的置信度
+const (
+	scoreRule          = 1.0
+	scorePattern       = 0.8
+	scoreKeyword       = 0.6 // 没有分类器时
+	scoreHighThreshold = 0.85
+	scoreMedThreshold  = 0.6
+
+	// keyword 结果评分低于该值、且分类器自身评分达到 high 时改用分类器的结果
+	keywordOverrideScore = 0.2
+)
+
+// ScoreConfidence 评分对应的置信度：>= 0.85 为 high，>= 0.6 为 medium，其余为 low
+func ScoreConfidence(score float64) string {
+	switch {
+	case score >= scoreHighThreshold:
+		return "high"
+	case score >= scoreMedThreshold:
+		return "medium"
+	}
+	return "low"
+}
+
+var categoryClassifier CategoryClassifier
+
+// SetCategoryClassifier 设置映射引擎使用的分类器（为 nil 时跳过分类器阶段）
+func SetCategoryClassifier(classifier CategoryClassifier) {
+	categoryClassifier = classifier
+}
+
+// CategoryMapper 映射引擎（创建时读取一次规则，适合在一次导入或一次重新分类中重复使用）
+type CategoryMapper struct {
+	taxonomy   *Taxonomy
+	exact      map[string]map[int]models.MappingRule
+	byName     map[string]map[string]models.MappingRule
+	patterns   []*patternRule // 按优先级排列：先映射规则后模糊规则
+	subRules   map[int][]*subCategoryRule
+	classifier CategoryClassifier
+}
+
+// fileCategoryMapping category_mapping.json 中的一条映射
+type fileCategoryMapping struct {
+	SourceTypeID  int    `json:"source_type_id"`
+	SourceName    string `json:"source_name"`
+	StandardID    int    `json:"standard_id"`
+	StandardSubID *int   `json:"standard_sub_id"`
+}
+
+// NewCategoryMapper 加载当前的映射规则
+func NewCategoryMapper(db *gorm.DB) *CategoryMapper {
+	m := &CategoryMapper{
+		taxonomy:   GetTaxonomy(),
+		exact:      make(map[string]map[int]models.MappingRule),
+		byName:     make(map[string]map[string]models.MappingRule),
+		classifier: categoryClassifier,
+	}
+	m.subRules = loadSubCategoryRules(db)
+
+	var rules []models.MappingRule
+	db.Where("is_active = ?", true).Order("priority ASC, id ASC").Find(&rules)
+	for _, rule := range rules {
+		if r := compileMappingPatternRule(rule); r != nil {
+			m.patterns = append(m.patterns, r)
+		}
+		if m.exact[rule.SourceKey] == nil {
+			m.exact[rule.SourceKey] = make(map[int]models.MappingRule)
+			m.byName[rule.SourceKey] = make(map[string]models.MappingRule)
+		}
+		if _, ok := m.exact[rule.SourceKey][rule.SourceTypeID]; !ok {
+			m.exact[rule.SourceKey][rule.SourceTypeID] = rule
+		}
+		if name := normalizeCategoryName(rule.SourceName); name != "" {
+			if _, ok := m.byName[rule.SourceKey][name]; !ok {
+				m.byName[rule.SourceKey][name] = rule
+			}
+		}
+	}
+
+	var fuzzyRules []models.FuzzyMatchRule
+	db.Where("is_active = ?", true).Order("priority ASC, id ASC").Find(&fuzzyRules)
+	for _, rule := range fuzzyRules {
+		if r := compileFuzzyMatchRule(rule); r != nil {
+			m.patterns = append(m.patterns, r)
+		}
+	}
+	return m
+}
+
+// MapCategory 映射单个分类（批量映射时请复用 NewCategoryMapper）
+func MapCategory(db *gorm.DB, in CategoryInput) CategoryDecision {
+	return NewCategoryMapper(db).Map(in)
+}
+
+// Map 按映射流程计算标准分类，再推断子分类
+func (m *CategoryMapper) Map(in CategoryInput) CategoryDecision {
+	d := m.mapCategory(in)
+	m.refineSubCategory(in, &d)
+	return d
+}
+
+func (m *CategoryMapper) mapCategory(in CategoryInput) CategoryDecision {
+	name := normalizeCategoryName(in.TypeName)
+
+	// 1. 精确规则
+	if rule, ok := m.exact[in.SourceKey][in.SourceTypeID]; ok {
+		return m.ruleDecision(rule.StandardID, rule.StandardSubID, MappingStageExact, scoreRule, rule.ID,
+			fmt.Sprintf("精确规则 #%d：%s 分类 %d", rule.ID, in.SourceKey, in.SourceTypeID))
+	}
+
+	if name != "" {
+		// 2. 名称匹配
+		if rule, ok := m.byName[in.SourceKey][name]; ok {
+			return m.ruleDecision(rule.StandardID, rule.StandardSubID, MappingStageName, scoreRule, rule.ID,
+				fmt.Sprintf("规则 #%d（%s 分类 %d）的分类名称同为\"%s\"", rule.ID, in.SourceKey, rule.SourceTypeID, rule.SourceName))
+		}
+		if id, subID, ok := m.taxonomyByName(name); ok {
+			return m.decide(id, subID, MappingStageName, scoreRule, 0, fmt.Sprintf("分类名称与标准分类\"%s\"相同", in.TypeName))
+		}
+		if target, ok := builtinCategoryNames[name]; ok {
+			if id, subID, ok := m.builtinTarget(target); ok {
+				return m.decide(id, subID, MappingStageName, scoreRule, 0, fmt.Sprintf("内置别名\"%s\"", in.TypeName))
+			}
+		}
+
+	}
+
+	// 3. 模式匹配规则
+	// 分类名称已表明媒体类型时跳过指向其他一级分类的规则，避免"韩国电影"被地区规则（韩国 → 韩剧）归入电视剧
+	media, _ := builtinMediaType(name)
+	for _, rule := range m.patterns {
+		if media != 0 && rule.standardID != media {
+			continue
+		}
+		if field, value, ok := rule.match(in); ok {
+			explanation := fmt.Sprintf("%s（%s）匹配 %s \"%s\"", rule.label, rule.pattern, field, value)
+			if rule.byRule {
+				return m.ruleDecision(rule.standardID, rule.standardSubID, MappingStagePattern, scorePattern, rule.ruleID, explanation)
+			}
+			return m.decide(rule.standardID, rule.standardSubID, MappingStagePattern, scorePattern, rule.ruleID, explanation)
+		}
+	}
+
+	if name != "" {
+		// 4. 内置关键词
+		if id, subID, explanation, ok := m.keywordTarget(name); ok {
+			return m.keywordDecision(in, id, subID, explanation)
+		}
+	}
+
+	// 5. 分类器
+	if prediction, ok := m.classify(in); ok {
+		return m.decide(prediction.StandardID, prediction.StandardSubID, MappingStageClassifier, prediction.Score, 0, prediction.Explanation)
+	}
+
+	// 6. 默认
+	return m.decide(OtherCategoryID, nil, MappingStageDefault, 0, 0, "没有匹配的规则")
+}
+
+func (m *CategoryMapper) decide(standardID int, standardSubID *int, stage string, score float64, ruleID uint, explanation string) CategoryDecision {
+	d := CategoryDecision{
+		StandardID:    standardID,
+		StandardSubID: standardSubID,
+		Stage:         stage,
+		Confidence:    ScoreConfidence(score),
+		Score:         score,
+		RuleID:        ruleID,
+	}
+	d.StandardName, d.StandardSubName = m.taxonomy.Names(standardID, standardSubID)
+	d.Explanation = fmt.Sprintf("%s → %s", explanation, categoryLabel(d.StandardName, d.StandardSubName))
+	return d
+}
+
+// ruleDecision 映射规则确定的结果
+func (m *CategoryMapper) ruleDecision(standardID int, standardSubID *int, stage string, score float64, ruleID uint, explanation string) CategoryDecision {
+	d := m.decide(standardID, standardSubID, stage, score, ruleID, explanation)
+	d.ByRule = true
+	return d
+}
+
+// keywordDecision 内置关键词的结果，有分类器时用分类器评分
+func (m *CategoryMapper) keywordDecision(in CategoryInput, standardID int, standardSubID *int, explanation string) CategoryDecision {
+	if m.classifier == nil {
+		return m.decide(standardID, standardSubID, MappingStageKeyword, scoreKeyword, 0, explanation)
+	}
+	score, ok := m.classifier.Score(in, standardID, standardSubID)
+	if !ok {
+		return m.decide(standardID, standardSubID, MappingStageKeyword, scoreKeyword, 0, explanation)
+	}
+	if score < keywordOverrideScore {
+		if prediction, ok := m.classify(in); ok && prediction.StandardID != standardID && prediction.Score >= scoreHighThreshold {
+			return m.decide(prediction.StandardID, prediction.StandardSubID, MappingStageClassifier, prediction.Score, 0,
+				fmt.Sprintf("%s，但分类器评分只有 %.2f；%s", explanation, score, prediction.Explanation))
+		}
+	}
+	return m.decide(standardID, standardSubID, MappingStageKeyword, score, 0, fmt.Sprintf("%s（分类器评分 %.2f）", explanation, score))
+}
+
+// classify 分类器的预测（分类需在标准分类中存在）
+func (m *CategoryMapper) classify(in CategoryInput) (ClassifierPrediction, bool) {
+	if m.classifier == nil {
+		return ClassifierPrediction{}, false
+	}
+	prediction, ok := m.classifier.Predict(in)
+	if !ok || prediction.StandardID == OtherCategoryID {
+		return ClassifierPrediction{}, false
+	}
+	if _, exists := m.taxonomy.Category(prediction.StandardID); !exists {
+		return ClassifierPrediction{}, false
+	}
+	if prediction.StandardSubID != nil {
+		if _, exists := m.taxonomy.SubCategory(*prediction.StandardSubID); !exists {
+			prediction.StandardSubID = nil
+		}
+	}
+	return prediction, true
+}
+
+// taxonomyByName 按名称查找标准分类（先子分类后一级分类）
+func (m *CategoryMapper) taxonomyByName(name string) (int, *int, bool) {
+	for _, cat := range m.taxonomy.Categories {
+		for _, sub := range cat.Subcategories {
+			if normalizeCategoryName(sub.Name) == name {
+				subID := sub.ID
+				return cat.ID, &subID, true
+			}
+		}
+	}
+	for _, cat := range m.taxonomy.Categories {
+		if normalizeCategoryName(cat.Name) == name {
+			return cat.ID, nil, true
+		}
+	}
+	return 0, nil, false
+}
+
+// builtinTarget 内置表中的分类需在标准分类中存在（子分类不存在时只映射到一级分类）
+func (m *CategoryMapper) builtinTarget(target builtinCategory) (int, *int, bool) {
+	if _, ok := m.taxonomy.Category(target.id); !ok {
+		return 0, nil, false
+	}
+	if target.subID == 0 {
+		return target.id, nil, true
+	}
+	if sub, ok := m.taxonomy.SubCategory(target.subID); ok && sub.CategoryID == target.id {
+		subID := target.subID
+		return target.id, &subID, true
+	}
+	return target.id, nil, true
+}
+
+// SourceMappingImportAction 导入 category_mapping.json 中 source_mappings 的变更集操作
+const SourceMappingImportAction = "import_source_mappings"
+
+// EnsureSourceMappingRules 把 category_mapping.json 的 source_mappings 导入为精确映射规则（只在启动时调用一次）
+// 导入记录为变更集；已经导入过时不再导入，规则被管理员删除或回滚后不会恢复
+func EnsureSourceMappingRules(db *gorm.DB) {
+	var imported int64
+	if db.Model(&models.MappingChangeset{}).Where("action = ?", SourceMappingImportAction).Count(&imported).Error != nil || imported > 0 {
+		return
+	}
+	data, err := os.ReadFile(CategoryMappingFile)
+	if err != nil {
+		return
+	}
+	change := BeginMappingChange(db, "system", SourceMappingImportAction)
+	defer change.End()
+	created, err := ImportSourceMappings(db, data)
+	if err != nil {
+		fmt.Printf("⚠️ 导入资源站分类映射失败: %v\n", err)
+		return
+	}
+	// 没有需要导入的映射时也记录变更集，作为已导入的标记
+	change.RecordEmpty = true
+	change.Summary = fmt.Sprintf("从 %s 导入资源站分类映射 %d 条", CategoryMappingFile, created)
+}
+
+// ImportSourceMappings 从 category_mapping.json 格式导入 source_mappings（只补充还没有规则的资源站分类，不覆盖已有规则）
+// 返回新增的规则数
+func ImportSourceMappings(db *gorm.DB, data []byte) (int, error) {
+	var file struct {
+		SourceMappings map[string]struct {
+			Mappings []fileCategoryMapping `json:"mappings"`
+		} `json:"source_mappings"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("解析分类映射文件失败: %w", err)
+	}
+	sourceKeys := make([]string, 0, len(file.SourceMappings))
+	for sourceKey := range file.SourceMappings {
+		sourceKeys = append(sourceKeys, sourceKey)
+	}
+	sort.Strings(sourceKeys)
+
+	created := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, sourceKey := range sourceKeys {
+			for _, mapping := range file.SourceMappings[sourceKey].Mappings {
+				var exists int64
+				if err := tx.Model(&models.MappingRule{}).
+					Where("source_key = ? AND source_type_id = ?", sourceKey, mapping.SourceTypeID).
+					Count(&exists).Error; err != nil {
+					return err
+				}
+				if exists > 0 {
+					continue
+				}
+				rule := models.MappingRule{
+					SourceKey:     sourceKey,
+					SourceTypeID:  mapping.SourceTypeID,
+					SourceName:    mapping.SourceName,
+					StandardID:    mapping.StandardID,
+					StandardSubID: mapping.StandardSubID,
+					Priority:      100,
+					MatchType:     MatchTypeExact,
+					IsActive:      true,
+				}
+				if err := tx.Create(&rule).Error; err != nil {
+					return fmt.Errorf("创建映射规则失败 (%s 分类 %d): %w", sourceKey, mapping.SourceTypeID, err)
+				}
+				created++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+func normalizeCategoryName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// builtinCategory 内置表的目标分类（subID 为0表示只映射到一级分类）
+type builtinCategory struct {
+	id    int
+	subID int
+}
+
+// builtinCategoryNames 常见的资源站分类名称别名（与标准分类同名的不需要列出）
+var builtinCategoryNames = map[string]builtinCategory{
+	"电影片":  {1, 0},
+	"伦理":   {1, 112},
+	"连续剧":  {2, 0},
+	"大陆剧":  {2, 201},
+	"内地剧":  {2, 201},
+	"香港剧":  {2, 202},
+	"港剧":   {2, 202},
+	"台剧":   {2, 203},
+	"美剧":   {2, 204},
+	"韩国剧":  {2, 205},
+	"日本剧":  {2, 206},
+	"马泰剧":  {2, 207},
+	"动画":   {4, 0},
+	"中国动漫": {4, 401},
+	"日本动漫": {4, 402},
+	"动漫电影": {4, 0},
+	"记录片":  {5, 0},
+}
+
+// builtinMediaKeywords 表示媒体类型的关键词（按顺序匹配，先匹配的优先，如"微电影"是短剧而不是电影）
+// "剧"在去掉"喜剧""剧情"后才表示电视剧；"片"放在最后，"纪录片""动画片"已被前面的类型匹配
+var builtinMediaKeywords = []struct {
+	keywords []string
+	id       int
+}{
+	{[]string{"综艺", "真人秀", "variety"}, 3},
+	{[]string{"动漫", "动画", "anime", "cartoon"}, 4},
+	{[]string{"纪录", "记录", "documentary"}, 5},
+	{[]string{"短剧", "微电影", "微剧"}, 6},
+	{[]string{"电影", "movie", "film"}, 1},
+	{[]string{"剧", "tv", "series"}, 2},
+	{[]string{"片"}, 1},
+}
+
+// builtinGenreKeywords 电影题材关键词（名称没有表明媒体类型时按电影处理）
+var builtinGenreKeywords = []struct {
+	keywords []string
+	subID    int
+}{
+	{[]string{"动作", "武侠", "功夫"}, 101},
+	{[]string{"喜剧", "搞笑", "comedy"}, 102},
+	{[]string{"爱情", "浪漫", "言情"}, 103},
+	{[]string{"科幻", "魔幻", "sci-fi"}, 104},
+	{[]string{"恐怖", "惊悚", "鬼片", "horror"}, 105},
+	{[]string{"剧情", "文艺", "drama"}, 106},
+	{[]string{"战争", "军事"}, 107},
+	{[]string{"悬疑", "推理", "mystery"}, 108},
+}
+
+// builtinRegionKeywords 地区关键词，只用于在媒体类型确定的分类中选择子分类（一级分类ID → 子分类ID）
+var builtinRegionKeywords = []struct {
+	keywords []string
+	subIDs   map[int]int
+}{
+	{[]string{"国产", "大陆", "内地", "chinese"}, map[int]int{2: 201, 3: 301, 4: 401}},
+	{[]string{"香港", "hk", "tvb"}, map[int]int{2: 202, 3: 302, 4: 404}},
+	{[]string{"台湾"}, map[int]int{2: 203, 3: 302, 4: 404}},
+	{[]string{"韩国", "korea", "korean"}, map[int]int{2: 205, 3: 303, 4: 402}},
+	{[]string{"日本", "japan", "japanese"}, map[int]int{2: 206, 3: 303, 4: 402}},
+	{[]string{"欧美", "美国", "英国", "american", "usa"}, map[int]int{2: 204, 3: 304, 4: 403}},
+	{[]string{"泰国"}, map[int]int{2: 207}},
+}
+
+// builtinMediaType 分类名称表明的媒体类型（标准分类ID），没有时为0
+func builtinMediaType(name string) (int, string) {
+	cleaned := strings.NewReplacer("喜剧", "", "剧情", "").Replace(name)
+	for _, item := range builtinMediaKeywords {
+		if keyword, ok := containsKeyword(cleaned, item.keywords); ok {
+			return item.id, keyword
+		}
+	}
+	return 0, ""
+}
+
+// keywordTarget 按内置关键词确定分类：先按媒体类型确定一级分类，再按题材（电影）或地区选择子分类
+// 只有地区、没有媒体类型的名称（如"韩国"）不匹配，交给后面的阶段
+func (m *CategoryMapper) keywordTarget(name string) (int, *int, string, bool) {
+	media, mediaKeyword := builtinMediaType(name)
+	target := builtinCategory{id: media}
+	var matched []string
+	if media != 0 {
+		matched = append(matched, mediaKeyword)
+	}
+
+	if media == 0 || media == MovieCategoryID {
+		for _, item := range builtinGenreKeywords {
+			if keyword, ok := containsKeyword(name, item.keywords); ok {
+				target = builtinCategory{MovieCategoryID, item.subID}
+				matched = append(matched, keyword)
+				break
+			}
+		}
+	} else {
+		for _, item := range builtinRegionKeywords {
+			if keyword, ok := containsKeyword(name, item.keywords); ok {
+				if subID, ok := item.subIDs[media]; ok {
+					target.subID = subID
+					matched = append(matched, keyword)
+				}
+				break
+			}
+		}
+	}
+	if target.id == 0 {
+		return 0, nil, "", false
+	}
+
+	id, subID, ok := m.builtinTarget(target)
+	if !ok {
+		return 0, nil, "", false
+	}
+	return id, subID, fmt.Sprintf("分类名称包含关键词\"%s\"", strings.Join(matched, "\"\"")), true
+}
+
+func containsKeyword(name string, keywords []string) (string, bool) {
+	for _, keyword := range keywords {
+		if strings.Contains(name, keyword) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
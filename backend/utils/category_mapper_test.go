@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestCategoryMapperBuiltinKeywords(t *testing.T) {
+	db := newTestDB(t)
+	mapper := NewCategoryMapper(db)
+
+	tests := []struct {
+		typeName string
+		id       int
+		subID    int // 0 表示没有子分类
+		stage    string
+	}{
+		{"韩国电影", 1, 0, MappingStageKeyword},
+		{"香港电影", 1, 0, MappingStageKeyword},
+		{"欧美电影", 1, 0, MappingStageKeyword},
+		{"动作电影", 1, 101, MappingStageKeyword},
+		{"喜剧电影", 1, 102, MappingStageKeyword},
+		{"剧情", 1, 106, MappingStageKeyword},
+		{"港片", 1, 0, MappingStageKeyword},
+		{"国产电视剧", 2, 201, MappingStageKeyword},
+		{"韩国连续剧", 2, 205, MappingStageKeyword},
+		{"TVB剧集", 2, 202, MappingStageKeyword},
+		{"韩国综艺", 3, 303, MappingStageKeyword},
+		{"大陆综艺节目", 3, 301, MappingStageKeyword},
+		{"日本动画", 4, 402, MappingStageKeyword},
+		{"国产动画", 4, 401, MappingStageKeyword},
+		{"欧美动画", 4, 403, MappingStageKeyword},
+		{"国产短剧", 6, 0, MappingStageKeyword},
+		{"微电影", 6, 0, MappingStageKeyword},
+		{"纪录片", 5, 0, MappingStageName},
+		{"韩国", OtherCategoryID, 0, MappingStageDefault},
+	}
+	for _, tt := range tests {
+		d := mapper.Map(CategoryInput{SourceKey: "test", SourceTypeID: 1, TypeName: tt.typeName})
+		subID := 0
+		if d.StandardSubID != nil {
+			subID = *d.StandardSubID
+		}
+		if d.StandardID != tt.id || subID != tt.subID || d.Stage != tt.stage {
+			t.Errorf("%s: 得到 %d/%d (%s)，期望 %d/%d (%s)；%s", tt.typeName, d.StandardID, subID, d.Stage, tt.id, tt.subID, tt.stage, d.Explanation)
+		}
+	}
+}
+
+func TestCategoryMapperFuzzyRulesRespectMediaType(t *testing.T) {
+	db := newTestDB(t)
+	sub := func(id int) *int { return &id }
+	// 与 CategoryMappingService.InitializeMappingRules 写入的默认模糊规则相同
+	rules := []models.FuzzyMatchRule{
+		{Pattern: "动作|武侠|功夫", StandardID: 1, StandardSubID: sub(101), Priority: 200, IsActive: true},
+		{Pattern: "喜剧|搞笑", StandardID: 1, StandardSubID: sub(102), Priority: 200, IsActive: true},
+		{Pattern: "国产|大陆|内地", StandardID: 2, StandardSubID: sub(201), Priority: 200, IsActive: true},
+		{Pattern: "港剧|港片|香港", StandardID: 2, StandardSubID: sub(202), Priority: 200, IsActive: true},
+		{Pattern: "韩剧|韩国", StandardID: 2, StandardSubID: sub(205), Priority: 200, IsActive: true},
+		{Pattern: "日剧|日本", StandardID: 2, StandardSubID: sub(206), Priority: 200, IsActive: true},
+		{Pattern: "欧美|美剧|英剧", StandardID: 2, StandardSubID: sub(204), Priority: 200, IsActive: true},
+	}
+	if err := db.Create(&rules).Error; err != nil {
+		t.Fatal(err)
+	}
+	mapper := NewCategoryMapper(db)
+
+	tests := []struct {
+		typeName string
+		id       int
+		subID    int
+		stage    string
+	}{
+		{"韩国电影", 1, 0, MappingStageKeyword},
+		{"韩国综艺", 3, 303, MappingStageKeyword},
+		{"香港电影", 1, 0, MappingStageKeyword},
+		{"港片", 1, 0, MappingStageKeyword},
+		{"欧美电影", 1, 0, MappingStageKeyword},
+		{"国产动画", 4, 401, MappingStageKeyword},
+		{"国产短剧", 6, 0, MappingStageKeyword},
+		{"日本动画", 4, 402, MappingStageKeyword},
+		{"动作电影", 1, 101, MappingStagePattern},
+		{"韩国连续剧", 2, 205, MappingStagePattern},
+		{"香港剧集", 2, 202, MappingStagePattern},
+		{"韩国", 2, 205, MappingStagePattern},
+	}
+	for _, tt := range tests {
+		d := mapper.Map(CategoryInput{SourceKey: "test", SourceTypeID: 1, TypeName: tt.typeName})
+		subID := 0
+		if d.StandardSubID != nil {
+			subID = *d.StandardSubID
+		}
+		if d.StandardID != tt.id || subID != tt.subID || d.Stage != tt.stage {
+			t.Errorf("%s: 得到 %d/%d (%s)，期望 %d/%d (%s)；%s", tt.typeName, d.StandardID, subID, d.Stage, tt.id, tt.subID, tt.stage, d.Explanation)
+		}
+	}
+}
+
+func TestEnsureSourceMappingRulesImportsOnce(t *testing.T) {
+	tests := []struct {
+		name        string
+		preimport   bool // 启动前规则已全部存在（如旧版本已导入）
+		wantChanges int
+	}{
+		{"补充缺少的规则", false, 122},
+		{"没有需要导入的规则时也只执行一次", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			t.Chdir("..") // category_mapping.json 在 backend 目录下
+
+			// 管理员已修改的规则不被覆盖
+			existing := models.MappingRule{SourceKey: "hhzy", SourceTypeID: 9, SourceName: "动作片", StandardID: 2, MatchType: MatchTypeExact, Priority: 100, IsActive: true}
+			db.Create(&existing)
+			if tt.preimport {
+				data, _ := os.ReadFile(CategoryMappingFile)
+				if _, err := ImportSourceMappings(db, data); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			EnsureSourceMappingRules(db)
+			var changesets []models.MappingChangeset
+			db.Where("action = ?", SourceMappingImportAction).Find(&changesets)
+			if len(changesets) != 1 || changesets[0].ChangeCount != tt.wantChanges {
+				t.Fatalf("导入应记录为一个变更集（%d 条变化），得到 %+v", tt.wantChanges, changesets)
+			}
+			var rule models.MappingRule
+			db.First(&rule, existing.ID)
+			if rule.StandardID != 2 {
+				t.Errorf("已有规则被覆盖为 %d", rule.StandardID)
+			}
+
+			d := NewCategoryMapper(db).Map(CategoryInput{SourceKey: "hhzy", SourceTypeID: 11, TypeName: "喜剧片"})
+			if d.Stage != MappingStageExact || !d.ByRule || d.StandardID != 1 || d.RuleID == 0 {
+				t.Errorf("导入的规则没有生效: %+v", d)
+			}
+
+			// 规则删除后不再重新导入
+			db.Where("1 = 1").Delete(&models.MappingRule{})
+			EnsureSourceMappingRules(db)
+			var n int64
+			db.Model(&models.MappingRule{}).Count(&n)
+			if n != 0 {
+				t.Errorf("删除后又导入了 %d 条规则", n)
+			}
+		})
+	}
+}
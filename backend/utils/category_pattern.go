@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"vodcms/models"
+)
+
+// 模式匹配规则（映射引擎的 pattern 阶段）
+// 1. mapping_rules 中 match_type 为 fuzzy/pattern 的规则：除了自身的分类ID，还匹配同一资源站中名称相符的其他分类
+//    fuzzy 的 pattern 为关键词（| 分隔，包含任一即匹配），pattern 的 pattern 为正则；pattern 为空时使用 source_name
+// 2. fuzzy_match_rules：pattern 为正则，keywords 为关键词数组，可匹配分类名称、视频标签、地区和标题
+// 所有正则都不区分大小写，创建规则时校验，按优先级依次匹配
+
+// 模糊规则可以匹配的字段
+const (
+	MatchFieldTypeName = "type_name"
+	MatchFieldVodClass = "vod_class"
+	MatchFieldVodArea  = "vod_area"
+	MatchFieldTitle    = "title"
+	MatchFieldContent  = "content" // 简介，只用于子分类推断规则
+)
+
+// CategoryMatchFields 所有可匹配的字段
+var CategoryMatchFields = []string{MatchFieldTypeName, MatchFieldVodClass, MatchFieldVodArea, MatchFieldTitle}
+
+// 映射规则的匹配方式
+const (
+	MatchTypeExact   = "exact"
+	MatchTypeFuzzy   = "fuzzy"
+	MatchTypePattern = "pattern"
+)
+
+// CompileCategoryPattern 编译规则中的正则（不区分大小写）
+func CompileCategoryPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, fmt.Errorf("pattern 不能为空")
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("pattern 不是有效的正则: %w", err)
+	}
+	if re.MatchString("") {
+		return nil, fmt.Errorf("pattern 会匹配空字符串，请检查是否有多余的 |")
+	}
+	return re, nil
+}
+
+// ParseMatchFields 解析并校验匹配字段（为空时为 type_name），返回规范化的逗号分隔字符串
+func ParseMatchFields(value string) (string, error) {
+	fields := splitMatchFields(value)
+	for _, field := range fields {
+		valid := false
+		for _, known := range CategoryMatchFields {
+			if field == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", fmt.Errorf("不支持的匹配字段 %s，可选: %s", field, strings.Join(CategoryMatchFields, ", "))
+		}
+	}
+	return strings.Join(fields, ","), nil
+}
+
+// ParseRuleKeywords 解析模糊规则的关键词（JSON数组）
+func ParseRuleKeywords(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var keywords []string
+	if err := json.Unmarshal([]byte(value), &keywords); err != nil {
+		return nil, fmt.Errorf("keywords 必须是JSON字符串数组: %w", err)
+	}
+	result := keywords[:0]
+	for _, keyword := range keywords {
+		if keyword = normalizeCategoryName(keyword); keyword != "" {
+			result = append(result, keyword)
+		}
+	}
+	return result, nil
+}
+
+// ValidateFuzzyMatchRule 校验并规范化模糊规则
+func ValidateFuzzyMatchRule(rule *models.FuzzyMatchRule) error {
+	if _, err := CompileCategoryPattern(rule.Pattern); err != nil {
+		return err
+	}
+	if _, err := ParseRuleKeywords(rule.Keywords); err != nil {
+		return err
+	}
+	fields, err := ParseMatchFields(rule.MatchFields)
+	if err != nil {
+		return err
+	}
+	rule.MatchFields = fields
+	return nil
+}
+
+// ValidateMappingRuleMatch 校验映射规则的匹配方式
+func ValidateMappingRuleMatch(rule *models.MappingRule) error {
+	switch rule.MatchType {
+	case "", MatchTypeExact:
+		return nil
+	case MatchTypeFuzzy:
+		if len(splitKeywords(mappingRulePattern(rule))) == 0 {
+			return fmt.Errorf("fuzzy 规则需要 pattern 或 source_name")
+		}
+		return nil
+	case MatchTypePattern:
+		_, err := CompileCategoryPattern(mappingRulePattern(rule))
+		return err
+	}
+	return fmt.Errorf("不支持的 match_type %s，可选: exact, fuzzy, pattern", rule.MatchType)
+}
+
+// patternRule 编译后的模式规则
+type patternRule struct {
+	ruleID        uint
+	label         string // 用于说明，如 "规则 #3" 或 "模糊规则 #5"
+	pattern       string
+	sourceKey     string // 为空表示所有资源站
+	re            *regexp.Regexp
+	keywords      []string
+	fields        []string
+	standardID    int
+	standardSubID *int
+	byRule        bool // 来自 mapping_rules，命中即算映射规则确定；模糊规则的结果只是推测
+}
+
+// match 返回命中的字段和值
+func (r *patternRule) match(in CategoryInput) (string, string, bool) {
+	if r.sourceKey != "" && r.sourceKey != in.SourceKey {
+		return "", "", false
+	}
+	for _, field := range r.fields {
+		value := in.field(field)
+		if value == "" {
+			continue
+		}
+		if r.re != nil && r.re.MatchString(value) {
+			return field, value, true
+		}
+		lower := strings.ToLower(value)
+		for _, keyword := range r.keywords {
+			if strings.Contains(lower, keyword) {
+				return field, value, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// compileMappingPatternRule 编译 match_type 为 fuzzy/pattern 的映射规则（exact 规则返回 nil）
+func compileMappingPatternRule(rule models.MappingRule) *patternRule {
+	r := &patternRule{
+		ruleID:        rule.ID,
+		label:         fmt.Sprintf("规则 #%d", rule.ID),
+		pattern:       mappingRulePattern(&rule),
+		sourceKey:     rule.SourceKey,
+		fields:        []string{MatchFieldTypeName},
+		standardID:    rule.StandardID,
+		standardSubID: rule.StandardSubID,
+		byRule:        true,
+	}
+	switch rule.MatchType {
+	case MatchTypeFuzzy:
+		r.keywords = splitKeywords(r.pattern)
+	case MatchTypePattern:
+		re, err := CompileCategoryPattern(r.pattern)
+		if err != nil {
+			fmt.Printf("⚠️ 映射规则 #%d 的正则无效，已跳过: %v\n", rule.ID, err)
+			return nil
+		}
+		r.re = re
+	default:
+		return nil
+	}
+	if r.re == nil && len(r.keywords) == 0 {
+		return nil
+	}
+	return r
+}
+
+// compileFuzzyMatchRule 编译模糊规则（正则无效时按 | 分隔的关键词处理，兼容旧数据）
+func compileFuzzyMatchRule(rule models.FuzzyMatchRule) *patternRule {
+	r := &patternRule{
+		ruleID:        rule.ID,
+		label:         fmt.Sprintf("模糊规则 #%d", rule.ID),
+		pattern:       rule.Pattern,
+		fields:        splitMatchFields(rule.MatchFields),
+		standardID:    rule.StandardID,
+		standardSubID: rule.StandardSubID,
+	}
+	if re, err := CompileCategoryPattern(rule.Pattern); err == nil {
+		r.re = re
+	} else if strings.TrimSpace(rule.Pattern) != "" {
+		fmt.Printf("⚠️ 模糊规则 #%d 的正则无效，按关键词匹配: %v\n", rule.ID, err)
+		r.keywords = splitKeywords(rule.Pattern)
+	}
+	keywords, _ := ParseRuleKeywords(rule.Keywords)
+	r.keywords = append(r.keywords, keywords...)
+	if r.re == nil && len(r.keywords) == 0 {
+		return nil
+	}
+	return r
+}
+
+// field 获取用于匹配的字段值
+func (in CategoryInput) field(name string) string {
+	switch name {
+	case MatchFieldTypeName:
+		return in.TypeName
+	case MatchFieldVodClass:
+		return in.VodClass
+	case MatchFieldVodArea:
+		return in.VodArea
+	case MatchFieldTitle:
+		return in.Title
+	case MatchFieldContent:
+		return in.Content
+	}
+	return ""
+}
+
+func mappingRulePattern(rule *models.MappingRule) string {
+	if strings.TrimSpace(rule.Pattern) != "" {
+		return rule.Pattern
+	}
+	return rule.SourceName
+}
+
+func splitMatchFields(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return []string{MatchFieldTypeName}
+	}
+	return fields
+}
+
+func splitKeywords(value string) []string {
+	var keywords []string
+	for _, keyword := range strings.Split(value, "|") {
+		if keyword = normalizeCategoryName(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestCompileCategoryPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ok      bool
+		match   []string
+		noMatch []string
+	}{
+		{"动作|武侠", true, []string{"动作片", "武侠剧"}, []string{"喜剧"}},
+		{"^TV$", true, []string{"tv", "TV"}, []string{"TVB"}},
+		{"", false, nil, nil},
+		{"   ", false, nil, nil},
+		{"动作|", false, nil, nil},
+		{"a*", false, nil, nil},
+		{"(动作", false, nil, nil},
+	}
+	for _, tt := range tests {
+		re, err := CompileCategoryPattern(tt.pattern)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: err = %v，期望 ok=%v", tt.pattern, err, tt.ok)
+			continue
+		}
+		for _, s := range tt.match {
+			if !re.MatchString(s) {
+				t.Errorf("%q 应匹配 %q", tt.pattern, s)
+			}
+		}
+		for _, s := range tt.noMatch {
+			if re.MatchString(s) {
+				t.Errorf("%q 不应匹配 %q", tt.pattern, s)
+			}
+		}
+	}
+}
+
+func TestFuzzyRuleMatchFields(t *testing.T) {
+	rule := compileFuzzyMatchRule(models.FuzzyMatchRule{ID: 1, Pattern: "韩国", Keywords: `["Korea"]`, MatchFields: "vod_area,title", StandardID: 2})
+	if rule == nil {
+		t.Fatal("规则编译失败")
+	}
+	tests := []struct {
+		in    CategoryInput
+		field string
+		ok    bool
+	}{
+		{CategoryInput{TypeName: "韩国", VodArea: "大陆"}, "", false},
+		{CategoryInput{VodArea: "韩国"}, MatchFieldVodArea, true},
+		{CategoryInput{Title: "South KOREA"}, MatchFieldTitle, true},
+	}
+	for _, tt := range tests {
+		field, _, ok := rule.match(tt.in)
+		if ok != tt.ok || field != tt.field {
+			t.Errorf("%+v: 得到 %s/%v，期望 %s/%v", tt.in, field, ok, tt.field, tt.ok)
+		}
+	}
+
+	// 无效正则按 | 分隔的关键词处理
+	legacy := compileFuzzyMatchRule(models.FuzzyMatchRule{ID: 2, Pattern: "(港剧|港片", StandardID: 2})
+	if legacy == nil || legacy.re != nil {
+		t.Fatal("无效正则应按关键词处理")
+	}
+	if _, _, ok := legacy.match(CategoryInput{TypeName: "港片"}); !ok {
+		t.Error("关键词\"港片\"应匹配")
+	}
+}
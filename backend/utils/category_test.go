@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestImportTaxonomyRejectsOverlappingIDs(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"子分类使用一级分类ID", `{"standard_categories":{"1":{"id":1,"name":"电影","subcategories":{"2":"冲突"}}}}`},
+		{"一级分类使用子分类ID", `{"standard_categories":{"101":{"id":101,"name":"冲突","subcategories":{}}}}`},
+		{"子分类使用其他分类ID", `{"standard_categories":{"1":{"id":1,"name":"电影","subcategories":{"99":"冲突"}}}}`},
+	}
+	for _, tt := range tests {
+		if _, _, err := ImportTaxonomy(db, []byte(tt.data)); err == nil {
+			t.Errorf("%s: 应返回错误", tt.name)
+		}
+	}
+
+	var count int64
+	db.Model(&models.StandardSubCategory{}).Where("name = ?", "冲突").Count(&count)
+	if count > 0 {
+		t.Errorf("导入失败时应整体回滚，仍有 %d 个冲突子分类", count)
+	}
+	db.Model(&models.StandardCategory{}).Where("id = ?", 101).Count(&count)
+	if count > 0 {
+		t.Error("导入失败时不应新增一级分类 101")
+	}
+}
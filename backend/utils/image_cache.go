@@ -0,0 +1,404 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册 GIF 解码
+	"image/jpeg"
+	_ "image/png" // 注册 PNG 解码
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"vodcms/config"
+)
+
+// 封面图片缓存（内容寻址存储）
+// 1. blobs/ab/<sha256(内容)>：原图，相同内容只保存一份
+// 2. urls/ab/<sha256(地址)>：地址到内容摘要的索引，第一行为内容摘要，第二行为 Content-Type
+// 3. sized/ab/<sha256(内容)>_w240.jpg：按宽度缩放后的 JPEG
+// 缩放宽度按档位向上取整，避免任意宽度产生大量缓存文件；不会放大原图
+
+// ImageWidths 缩放宽度档位
+var ImageWidths = []int{120, 240, 360, 480, 720, 1080}
+
+const (
+	imageMaxBytes     = 10 << 20   // 原图最大 10MB
+	imageMaxPixels    = 40_000_000 // 解码前检查像素数，防止超大图片占满内存
+	imageJPEGQuality  = 82
+	imageFetchTimeout = 15 * time.Second
+	imageFailureTTL   = 10 * time.Minute // 下载失败后的冷却时间
+	imageFailureMax   = 10000            // 失败记录上限，超出时清理过期记录
+	imagePrewarmWidth = 240              // 预热列表页使用的缩略图
+	imagePrewarmConc  = 4
+)
+
+// CachedImage 缓存中的图片文件
+type CachedImage struct {
+	Path        string
+	ContentType string
+	ETag        string
+}
+
+// imageCall 同一地址同时只下载一次，其他请求等待结果
+type imageCall struct {
+	wg    sync.WaitGroup
+	image *CachedImage
+	err   error
+}
+
+var (
+	imageInflight = struct {
+		sync.Mutex
+		calls map[string]*imageCall
+	}{calls: make(map[string]*imageCall)}
+
+	imageFailures = struct {
+		sync.Mutex
+		until map[string]time.Time
+	}{until: make(map[string]time.Time)}
+
+	imageClient = &http.Client{
+		Timeout: imageFetchTimeout,
+		Transport: &http.Transport{
+			Proxy:       http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: imageDialControl}).DialContext,
+		},
+	}
+)
+
+// ImageWidthBucket 把请求宽度向上取整到档位，0 表示原图
+func ImageWidthBucket(width int) int {
+	if width <= 0 {
+		return 0
+	}
+	for _, w := range ImageWidths {
+		if width <= w {
+			return w
+		}
+	}
+	return ImageWidths[len(ImageWidths)-1]
+}
+
+// GetCachedImage 获取缓存的图片，不存在时下载（width 为 0 返回原图）
+func GetCachedImage(rawURL string, width int) (*CachedImage, error) {
+	width = ImageWidthBucket(width)
+	return imageOnce(fmt.Sprintf("%s|%d", rawURL, width), func() (*CachedImage, error) {
+		original, err := cachedOriginal(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		if width == 0 {
+			return original, nil
+		}
+		return cachedResized(original, width)
+	})
+}
+
+// PrewarmImages 后台预热封面（原图和列表缩略图），失败只统计不重试
+func PrewarmImages(urls []string) {
+	seen := make(map[string]bool)
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok, failed := 0, 0
+
+	for i := 0; i < imagePrewarmConc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rawURL := range jobs {
+				_, err := GetCachedImage(rawURL, imagePrewarmWidth)
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					ok++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, rawURL := range urls {
+		if rawURL == "" || seen[rawURL] {
+			continue
+		}
+		seen[rawURL] = true
+		jobs <- rawURL
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ok+failed > 0 {
+		fmt.Printf("🖼️ 封面预热完成: 成功 %d 张，失败 %d 张\n", ok, failed)
+	}
+}
+
+func imageOnce(key string, fn func() (*CachedImage, error)) (*CachedImage, error) {
+	imageInflight.Lock()
+	if call, ok := imageInflight.calls[key]; ok {
+		imageInflight.Unlock()
+		call.wg.Wait()
+		return call.image, call.err
+	}
+	call := &imageCall{}
+	call.wg.Add(1)
+	imageInflight.calls[key] = call
+	imageInflight.Unlock()
+
+	call.image, call.err = fn()
+	call.wg.Done()
+
+	imageInflight.Lock()
+	delete(imageInflight.calls, key)
+	imageInflight.Unlock()
+	return call.image, call.err
+}
+
+// cachedOriginal 通过地址索引查找原图，不存在时下载
+func cachedOriginal(rawURL string) (*CachedImage, error) {
+	indexPath := imageCachePath("urls", sha256Hex([]byte(rawURL)))
+	if data, err := os.ReadFile(indexPath); err == nil {
+		lines := strings.SplitN(string(data), "\n", 2)
+		if len(lines) == 2 {
+			blobPath := imageCachePath("blobs", lines[0])
+			if _, err := os.Stat(blobPath); err == nil {
+				return &CachedImage{Path: blobPath, ContentType: lines[1], ETag: imageETag(lines[0], 0)}, nil
+			}
+		}
+	}
+
+	imageFailures.Lock()
+	until, failed := imageFailures.until[rawURL]
+	imageFailures.Unlock()
+	if failed && time.Now().Before(until) {
+		return nil, fmt.Errorf("图片最近下载失败，稍后重试")
+	}
+
+	data, contentType, err := fetchImage(rawURL)
+	if err != nil {
+		recordImageFailure(rawURL)
+		return nil, err
+	}
+
+	hash := sha256Hex(data)
+	blobPath := imageCachePath("blobs", hash)
+	if _, err := os.Stat(blobPath); err != nil {
+		if err := writeFileAtomic(blobPath, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeFileAtomic(indexPath, []byte(hash+"\n"+contentType)); err != nil {
+		return nil, err
+	}
+	return &CachedImage{Path: blobPath, ContentType: contentType, ETag: imageETag(hash, 0)}, nil
+}
+
+// cachedResized 缩放并转换为 JPEG
+func cachedResized(original *CachedImage, width int) (*CachedImage, error) {
+	hash := filepath.Base(original.Path)
+	sizedPath := imageCachePath("sized", fmt.Sprintf("%s_w%d.jpg", hash, width))
+	resized := &CachedImage{Path: sizedPath, ContentType: "image/jpeg", ETag: imageETag(hash, width)}
+	if _, err := os.Stat(sizedPath); err == nil {
+		return resized, nil
+	}
+
+	data, err := os.ReadFile(original.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("不支持的图片格式: %w", err)
+	}
+	if cfg.Width*cfg.Height > imageMaxPixels {
+		return nil, fmt.Errorf("图片尺寸过大: %dx%d", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resizeImage(src, width), &jpeg.Options{Quality: imageJPEGQuality}); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(sizedPath, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return resized, nil
+}
+
+// fetchImage 下载原图，带上图片所在站点的 Referer 以绕过防盗链
+func fetchImage(rawURL string) ([]byte, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", fmt.Errorf("无效的图片地址: %s", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; VodCMS-ImageProxy/1.0)")
+	req.Header.Set("Referer", u.Scheme+"://"+u.Host+"/")
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("图片下载失败: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, imageMaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > imageMaxBytes {
+		return nil, "", fmt.Errorf("图片超过 %dMB", imageMaxBytes>>20)
+	}
+	// 资源站返回的 Content-Type 经常不准，以内容为准
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("不是图片: %s", contentType)
+	}
+	return data, contentType, nil
+}
+
+// resizeImage 按宽度等比缩放（区域平均），透明部分填充白色
+func resizeImage(src image.Image, width int) *image.RGBA {
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	if width > sw {
+		width = sw
+	}
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+
+	// 先转换为 RGBA，便于直接读取像素
+	rgba := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(rgba, rgba.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Over)
+	if width == sw {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy0, sy1 := y*sh/height, (y+1)*sh/height
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < width; x++ {
+			sx0, sx1 := x*sw/width, (x+1)*sw/width
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			var r, g, b, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				i := rgba.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(rgba.Pix[i])
+					g += uint32(rgba.Pix[i+1])
+					b += uint32(rgba.Pix[i+2])
+					i += 4
+					n++
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = 255
+		}
+	}
+	return dst
+}
+
+// imageDialControl 禁止访问内网地址（防止通过封面地址探测内网），可用 IMAGE_ALLOW_PRIVATE=1 关闭
+func imageDialControl(network, address string, c syscall.RawConn) error {
+	if config.AppConfig != nil && config.AppConfig.ImageAllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("禁止访问内网地址: %s", host)
+	}
+	return nil
+}
+
+// recordImageFailure 记录下载失败的地址（冷却期内不再重试），记录过多时清理过期项
+func recordImageFailure(rawURL string) {
+	imageFailures.Lock()
+	defer imageFailures.Unlock()
+
+	now := time.Now()
+	if len(imageFailures.until) >= imageFailureMax {
+		for k, until := range imageFailures.until {
+			if now.After(until) {
+				delete(imageFailures.until, k)
+			}
+		}
+		if len(imageFailures.until) >= imageFailureMax {
+			imageFailures.until = make(map[string]time.Time)
+		}
+	}
+	imageFailures.until[rawURL] = now.Add(imageFailureTTL)
+}
+
+func imageCachePath(kind, name string) string {
+	return filepath.Join(config.AppConfig.ImageCacheDir, kind, name[:2], name)
+}
+
+func imageETag(hash string, width int) string {
+	return fmt.Sprintf(`"%s-w%d"`, hash[:16], width)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic 先写临时文件再改名，避免并发读取到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestImageWidthBucket(t *testing.T) {
+	tests := []struct{ width, want int }{
+		{0, 0}, {-5, 0}, {1, 120}, {120, 120}, {121, 240}, {700, 720}, {5000, 1080},
+	}
+	for _, tt := range tests {
+		if got := ImageWidthBucket(tt.width); got != tt.want {
+			t.Errorf("ImageWidthBucket(%d) = %d，期望 %d", tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRecordImageFailurePrunes(t *testing.T) {
+	imageFailures.Lock()
+	imageFailures.until = make(map[string]time.Time)
+	expired := time.Now().Add(-time.Minute)
+	for i := 0; i < imageFailureMax-1; i++ {
+		imageFailures.until[fmt.Sprintf("https://old/%d.jpg", i)] = expired
+	}
+	imageFailures.until["https://recent.jpg"] = time.Now().Add(time.Minute)
+	imageFailures.Unlock()
+	t.Cleanup(func() {
+		imageFailures.Lock()
+		imageFailures.until = make(map[string]time.Time)
+		imageFailures.Unlock()
+	})
+
+	recordImageFailure("https://new.jpg")
+
+	imageFailures.Lock()
+	defer imageFailures.Unlock()
+	if len(imageFailures.until) != 2 {
+		t.Errorf("达到上限时应清理过期记录，剩余 %d 条", len(imageFailures.until))
+	}
+	for _, key := range []string{"https://recent.jpg", "https://new.jpg"} {
+		if _, ok := imageFailures.until[key]; !ok {
+			t.Errorf("未过期的记录 %s 不应被清理", key)
+		}
+	}
+}
@@ -4,118 +4,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"time"
 
 	"vodcms/config"
 	"vodcms/models"
+
 	"gorm.io/gorm"
 )
 
-// CategoryMappingHelper 分类映射辅助结构
-type CategoryMappingHelper struct {
-	mappings map[string]map[int]CategoryMapResult
-}
-
-// CategoryMapResult 映射结果
-type CategoryMapResult struct {
-	StandardID      int
-	StandardSubID   *int
-	StandardName    string
-	StandardSubName string
-}
-
-// LoadCategoryMappings 加载分类映射
-func LoadCategoryMappings() (*CategoryMappingHelper, error) {
-	file, err := os.ReadFile("category_mapping.json")
-	if err != nil {
-		return nil, fmt.Errorf("读取分类映射文件失败: %w", err)
-	}
-
-	var config struct {
-		StandardCategories map[string]struct {
-			ID            int               `json:"id"`
-			Name          string            `json:"name"`
-			Subcategories map[string]string `json:"subcategories"`
-		} `json:"standard_categories"`
-		SourceMappings map[string]struct {
-			Mappings []struct {
-				SourceTypeID  int  `json:"source_type_id"`
-				StandardID    int  `json:"standard_id"`
-				StandardSubID *int `json:"standard_sub_category_id"`
-			} `json:"mappings"`
-		} `json:"source_mappings"`
-	}
-
-	if err := json.Unmarshal(file, &config); err != nil {
-		return nil, fmt.Errorf("解析分类映射文件失败: %w", err)
-	}
-
-	helper := &CategoryMappingHelper{
-		mappings: make(map[string]map[int]CategoryMapResult),
-	}
-
-	// 构建映射表
-	for sourceKey, sourceMapping := range config.SourceMappings {
-		helper.mappings[sourceKey] = make(map[int]CategoryMapResult)
-		for _, mapping := range sourceMapping.Mappings {
-			result := CategoryMapResult{
-				StandardID:    mapping.StandardID,
-				StandardSubID: mapping.StandardSubID,
-			}
-
-			// 获取标准分类名称
-			if stdCat, ok := config.StandardCategories[fmt.Sprintf("%d", mapping.StandardID)]; ok {
-				result.StandardName = stdCat.Name
-
-				// 获取子分类名称
-				if mapping.StandardSubID != nil {
-					if subName, ok := stdCat.Subcategories[fmt.Sprintf("%d", *mapping.StandardSubID)]; ok {
-						result.StandardSubName = subName
-					}
-				}
-			}
-
-			helper.mappings[sourceKey][mapping.SourceTypeID] = result
-		}
-	}
-
-	return helper, nil
-}
-
-// MapCategory 映射分类
-func (h *CategoryMappingHelper) MapCategory(sourceKey string, sourceTypeID int) CategoryMapResult {
-	// 默认值
-	defaultResult := CategoryMapResult{
-		StandardID:   99,
-		StandardName: "其他",
-	}
-
-	if sourceMappings, ok := h.mappings[sourceKey]; ok {
-		if result, ok := sourceMappings[sourceTypeID]; ok {
-			return result
-		}
-	}
-
-	return defaultResult
+// ImportResult 一次导入的结果
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
 }
 
 // ImportVideoFromJSON 从JSON文件导入视频到数据库
-func ImportVideoFromJSON(sourceKey string) error {
+// collectionLogID 为本次采集任务的日志ID，会记录到字段变更历史中（手动导入传0）
+func ImportVideoFromJSON(sourceKey string, collectionLogID uint) (*ImportResult, error) {
 	db := config.GetDB()
 
-	// 加载分类映射
-	categoryHelper, err := LoadCategoryMappings()
-	if err != nil {
-		fmt.Printf("⚠️ 加载分类映射失败: %v，将使用原始分类\n", err)
-		categoryHelper = nil
-	}
+	// 加载分类映射规则（本次导入共用）
+	mapper := NewCategoryMapper(db)
 
 	// 读取JSON文件
 	filename := fmt.Sprintf("%s_vod.json", sourceKey)
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return fmt.Errorf("读取文件失败: %w", err)
+		return nil, fmt.Errorf("读取文件失败: %w", err)
 	}
 
 	// 解析JSON
@@ -134,7 +50,7 @@ func ImportVideoFromJSON(sourceKey string) error {
 	}
 
 	if err := json.Unmarshal(data, &fileData); err != nil {
-		return fmt.Errorf("解析JSON失败: %w", err)
+		return nil, fmt.Errorf("解析JSON失败: %w", err)
 	}
 
 	fmt.Printf("📥 开始导入 %s 的视频数据，共 %d 条\n", fileData.SourceInfo.Name, len(fileData.Videos))
@@ -143,46 +59,87 @@ func ImportVideoFromJSON(sourceKey string) error {
 	successCount := 0
 	updateCount := 0
 	errorCount := 0
+	unmapped := newUnmappedTracker(mapper) // 本次导入遇到的未映射分类
+	var newPics []string                   // 新增或更换的封面，导入后预热
+	// 视频事件按导入合并，避免首次采集新资源站时产生成千上万次投递
+	createdEvents := newWebhookVideoBatch(WebhookEventVideoCreated)
+	episodeEvents := newWebhookVideoBatch(WebhookEventEpisodeAdded)
 
 	for _, videoData := range fileData.Videos {
 		video := mapToVideo(videoData)
 
-		// 🔥 使用数据库映射规则（优先）+ JSON配置（备用）
-		standardID, standardSubID, standardName, standardSubName := 
-			mapCategoryWithDB(db, video.SourceKey, video.TypeID, video.TypeName, categoryHelper)
+		// 🔥 使用统一的映射引擎
+		decision := mapper.Map(VideoCategoryInput(&video))
 
-		video.StandardCategoryID = standardID
-		video.StandardCategoryName = standardName
-		video.StandardSubCategoryID = standardSubID
-		video.StandardSubCategoryName = standardSubName
+		video.StandardCategoryID = decision.StandardID
+		video.StandardCategoryName = decision.StandardName
+		video.StandardSubCategoryID = decision.StandardSubID
+		video.StandardSubCategoryName = decision.StandardSubName
+		video.StandardSubCategoryConfidence = decision.SubConfidence
+		unmapped.observe(&video, decision)
 
 		// 检查是否已存在（根据vod_id和source_key）
 		var existingVideo models.Video
 		result := db.Where("vod_id = ? AND source_key = ?", video.VodID, video.SourceKey).First(&existingVideo)
 
 		if result.RowsAffected > 0 {
-			// 更新现有记录
+			// 更新现有记录（保留被锁定的字段，并记录字段级差异）
 			video.ID = existingVideo.ID
 			video.CreatedAt = existingVideo.CreatedAt
-			if err := db.Save(&video).Error; err != nil {
+			ApplyLockedFields(&video, &existingVideo)
+			FillVideoPinyin(&video)
+			changes := DiffVideoFields(&existingVideo, &video)
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Save(&video).Error; err != nil {
+					return err
+				}
+				return RecordVideoHistory(tx, &video, changes, "import", collectionLogID)
+			})
+			if err != nil {
 				fmt.Printf("  ❌ 更新失败 (ID:%d): %v\n", video.VodID, err)
 				errorCount++
 			} else {
 				updateCount++
+				if video.VodPic != existingVideo.VodPic {
+					newPics = append(newPics, video.VodPic)
+				}
+				if oldCount, newCount := VideoEpisodeCount(&existingVideo), VideoEpisodeCount(&video); newCount > oldCount {
+					data := webhookVideoData(&video)
+					data["old_episodes"] = oldCount
+					data["episodes"] = newCount
+					episodeEvents.add(video.VodID, data)
+				}
 			}
 		} else {
-			// 创建新记录
+			// 创建新记录（其他资源站已有该视频时不算新视频）
+			FillVideoPinyin(&video)
+			var existing int64
+			db.Model(&models.Video{}).Where("vod_id = ?", video.VodID).Count(&existing)
 			if err := db.Create(&video).Error; err != nil {
 				fmt.Printf("  ❌ 创建失败 (ID:%d): %v\n", video.VodID, err)
 				errorCount++
 			} else {
 				successCount++
+				newPics = append(newPics, video.VodPic)
+				if existing == 0 {
+					createdEvents.add(video.VodID, webhookVideoData(&video))
+				}
 			}
 		}
 	}
 
 	fmt.Printf("✅ 导入完成: 新增 %d 条，更新 %d 条，失败 %d 条\n", successCount, updateCount, errorCount)
-	return nil
+	unmapped.save(db)
+	createdEvents.fire(db, sourceKey, collectionLogID)
+	episodeEvents.fire(db, sourceKey, collectionLogID)
+	if successCount+updateCount > 0 {
+		TouchCatalog()
+	}
+	if len(newPics) > 0 && config.AppConfig.ImagePrewarm {
+		go PrewarmImages(newPics)
+	}
+	return &ImportResult{Created: successCount, Updated: updateCount, Failed: errorCount}, nil
 }
 
 // mapToVideo 将map转换为Video模型
@@ -248,7 +205,7 @@ func mapToVideo(data map[string]interface{}) models.Video {
 	video.VodPubdate = getString(data, "vod_pubdate")
 	video.VodLevel = getInt(data, "vod_level")
 	video.VodCopyright = getInt(data, "vod_copyright")
-	video.VodLock = getInt(data, "vod_lock")
+	// vod_lock 用作本地字段锁定标记，不从源站读取
 	video.GroupID = getInt(data, "group_id")
 
 	// 来源信息
@@ -305,75 +262,3 @@ func getFloat(m map[string]interface{}, key string) float64 {
 	}
 	return 0.0
 }
-
-// mapCategoryWithDB 使用数据库规则映射分类（增强版）
-// 优先级：1. 数据库精确规则 2. JSON配置 3. 默认值
-func mapCategoryWithDB(db *gorm.DB, sourceKey string, sourceTypeID int, sourceTypeName string, helper *CategoryMappingHelper) (int, *int, string, string) {
-	// 默认值
-	defaultStandardID := 99
-	defaultStandardName := "其他"
-	var defaultStandardSubID *int = nil
-	defaultStandardSubName := ""
-
-	// 1. 优先从数据库查找精确匹配规则
-	var rule models.MappingRule
-	err := db.Where("source_key = ? AND source_type_id = ? AND is_active = ?",
-		sourceKey, sourceTypeID, true).
-		Order("priority ASC").
-		First(&rule).Error
-
-	if err == nil {
-		// 找到数据库规则
-		standardName, standardSubName := getStandardCategoryNames(db, rule.StandardID, rule.StandardSubID)
-		return rule.StandardID, rule.StandardSubID, standardName, standardSubName
-	}
-
-	// 2. 从JSON配置查找（向后兼容）
-	if helper != nil {
-		result := helper.MapCategory(sourceKey, sourceTypeID)
-		if result.StandardID != 99 {
-			return result.StandardID, result.StandardSubID, result.StandardName, result.StandardSubName
-		}
-	}
-
-	// 3. 返回默认值
-	return defaultStandardID, defaultStandardSubID, defaultStandardName, defaultStandardSubName
-}
-
-// getStandardCategoryNames 从category_mapping.json获取标准分类名称
-func getStandardCategoryNames(db *gorm.DB, standardID int, standardSubID *int) (string, string) {
-	file, err := os.ReadFile("category_mapping.json")
-	if err != nil {
-		return "", ""
-	}
-
-	var config struct {
-		StandardCategories map[string]struct {
-			ID            int               `json:"id"`
-			Name          string            `json:"name"`
-			Subcategories map[string]string `json:"subcategories"`
-		} `json:"standard_categories"`
-	}
-
-	if err := json.Unmarshal(file, &config); err != nil {
-		return "", ""
-	}
-
-	stdKey := fmt.Sprintf("%d", standardID)
-	stdCat, ok := config.StandardCategories[stdKey]
-	if !ok {
-		return "", ""
-	}
-
-	standardName := stdCat.Name
-	standardSubName := ""
-
-	if standardSubID != nil {
-		subKey := fmt.Sprintf("%d", *standardSubID)
-		if subName, ok := stdCat.Subcategories[subKey]; ok {
-			standardSubName = subName
-		}
-	}
-
-	return standardName, standardSubName
-}
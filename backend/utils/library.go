@@ -0,0 +1,428 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// 媒体库导出（Emby / Jellyfin / Kodi 通过 .strm 文件播放网络地址）
+// 1. 电影：movies/片名 (年份)/片名 (年份).strm + movie.nfo
+// 2. 剧集：tvshows/片名 (年份)/tvshow.nfo + Season 1/S01E01.strm
+// 3. 输出目录下的 .vodcms_library.json 记录每个视频的目录和内容摘要，
+// 再次导出时只重写有变化的视频，并删除已不存在的视频目录
+// 4. 输出目录应专用于媒体库导出，视频目录在重写前会被整体清空
+
+const (
+	libraryManifestName = ".vodcms_library.json"
+	libraryBatchSize    = 200
+	libraryMovieDir     = "movies"
+	libraryShowDir      = "tvshows"
+	libraryNameMaxLen   = 80
+)
+
+// LibraryExportStats 媒体库导出统计
+type LibraryExportStats struct {
+	Total     int `json:"total"`     // 已处理的视频数
+	Written   int `json:"written"`   // 新增或重写
+	Unchanged int `json:"unchanged"` // 未变化，跳过
+	Skipped   int `json:"skipped"`   // 没有可用的播放地址
+	Removed   int `json:"removed"`   // 已删除的视频目录
+}
+
+// libraryManifest 导出清单，key 为 vod_id
+type libraryManifest struct {
+	UpdatedAt time.Time                   `json:"updated_at"`
+	Items     map[int]libraryManifestItem `json:"items"`
+}
+
+type libraryManifestItem struct {
+	Path string `json:"path"` // 相对输出目录的视频目录
+	Hash string `json:"hash"`
+}
+
+// libraryItem 一个视频要写出的目录和文件（文件路径相对视频目录）
+type libraryItem struct {
+	Path  string
+	Files map[string][]byte
+}
+
+// hash 目录和文件内容的摘要，用于判断是否需要重写
+func (item *libraryItem) hash() string {
+	names := make([]string, 0, len(item.Files))
+	for name := range item.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha1.New()
+	h.Write([]byte(item.Path + "\n"))
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\n%d\n", name, len(item.Files[name]))
+		h.Write(item.Files[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// libraryNFO Kodi 格式的 movie.nfo / tvshow.nfo
+type libraryNFO struct {
+	XMLName       xml.Name
+	Title         string            `xml:"title"`
+	OriginalTitle string            `xml:"originaltitle,omitempty"`
+	Plot          string            `xml:"plot,omitempty"`
+	Year          string            `xml:"year,omitempty"`
+	Genres        []string          `xml:"genre"`
+	Directors     []string          `xml:"director"`
+	Actors        []libraryNFOActor `xml:"actor"`
+	Thumb         *libraryNFOThumb  `xml:"thumb,omitempty"`
+	UniqueID      libraryNFOID      `xml:"uniqueid"`
+}
+
+type libraryNFOActor struct {
+	Name string `xml:"name"`
+}
+
+type libraryNFOThumb struct {
+	Aspect string `xml:"aspect,attr"`
+	URL    string `xml:",chardata"`
+}
+
+type libraryNFOID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	ID      string `xml:",chardata"`
+}
+
+// ExportLibrary 把视频导出为媒体库目录，progress 在每批处理完后回调（可为 nil）
+func ExportLibrary(db *gorm.DB, dir string, progress func(LibraryExportStats)) (*LibraryExportStats, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("输出目录不能为空")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %w", err)
+	}
+
+	old, err := loadLibraryManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[int]libraryManifestItem)
+	claimed := make(map[string]bool) // 本次导出已占用的目录
+	stats := &LibraryExportStats{}
+
+	subQuery := db.Table("videos").
+		Select("MAX(id) as id").
+		Group("vod_id")
+	query := db.Model(&models.Video{}).
+		Where("id IN (?)", subQuery).
+		Order("vod_id ASC")
+
+	var videos []models.Video
+	err = query.FindInBatches(&videos, libraryBatchSize, func(tx *gorm.DB, batch int) error {
+		vodIDs := make([]int, 0, len(videos))
+		for _, video := range videos {
+			vodIDs = append(vodIDs, video.VodID)
+		}
+		var rows []models.Video
+		if err := db.Where("vod_id IN ?", vodIDs).Order("collected_at DESC, id DESC").Find(&rows).Error; err != nil {
+			return err
+		}
+		sources := make(map[int][]models.Video)
+		for _, row := range rows {
+			sources[row.VodID] = append(sources[row.VodID], row)
+		}
+
+		for _, video := range videos {
+			stats.Total++
+			item := buildLibraryItem(sources[video.VodID], claimed)
+			if item == nil {
+				stats.Skipped++
+				continue
+			}
+			claimed[item.Path] = true
+			entry := libraryManifestItem{Path: item.Path, Hash: item.hash()}
+
+			prev, ok := old.Items[video.VodID]
+			if ok && prev == entry && libraryPathExists(dir, entry.Path) {
+				current[video.VodID] = entry
+				stats.Unchanged++
+				continue
+			}
+			if ok && prev.Path != entry.Path && !claimed[prev.Path] {
+				removeLibraryPath(dir, prev.Path)
+			}
+			if err := writeLibraryItem(dir, item); err != nil {
+				return fmt.Errorf("写入 %s 失败: %w", item.Path, err)
+			}
+			current[video.VodID] = entry
+			stats.Written++
+		}
+
+		if progress != nil {
+			progress(*stats)
+		}
+		return nil
+	}).Error
+	if err != nil {
+		// 未处理到的视频保留原记录，下次导出时继续
+		for vodID, item := range old.Items {
+			if _, ok := current[vodID]; !ok {
+				current[vodID] = item
+			}
+		}
+		if saveErr := saveLibraryManifest(dir, current); saveErr != nil {
+			fmt.Printf("⚠️ 保存媒体库清单失败: %v\n", saveErr)
+		}
+		return stats, err
+	}
+
+	// 清理已删除或已没有播放地址的视频
+	for vodID, item := range old.Items {
+		if _, ok := current[vodID]; ok {
+			continue
+		}
+		if !claimed[item.Path] {
+			removeLibraryPath(dir, item.Path)
+		}
+		stats.Removed++
+	}
+
+	if err := saveLibraryManifest(dir, current); err != nil {
+		return stats, err
+	}
+	if progress != nil {
+		progress(*stats)
+	}
+	return stats, nil
+}
+
+// buildLibraryItem 生成一个视频的目录和文件，没有可用播放地址时返回 nil
+// sources 为同一 vod_id 的所有资源站记录，按采集时间倒序
+func buildLibraryItem(sources []models.Video, claimed map[string]bool) *libraryItem {
+	if len(sources) == 0 {
+		return nil
+	}
+	video := &sources[0]
+
+	var groups []PlayGroup
+	for i := range sources {
+		groups = append(groups, VideoPlayGroups(&sources[i])...)
+	}
+	group := pickLibraryGroup(MergePlayGroups(groups))
+	if group == nil {
+		return nil
+	}
+
+	name := libraryFileName(video.VodName)
+	if name == "" {
+		name = strconv.Itoa(video.VodID)
+	}
+	if year := libraryYear(video.VodYear); year != "" {
+		name += " (" + year + ")"
+	}
+
+	isMovie := libraryIsMovie(video, len(group.Episodes))
+	root := libraryShowDir
+	if isMovie {
+		root = libraryMovieDir
+	}
+	path := root + "/" + name
+	if claimed[path] {
+		// 同名同年份的不同视频，用 vod_id 区分
+		path += " [" + strconv.Itoa(video.VodID) + "]"
+	}
+
+	item := &libraryItem{Path: path, Files: make(map[string][]byte)}
+	if isMovie {
+		item.Files["movie.nfo"] = buildLibraryNFO("movie", video)
+		for i, episode := range group.Episodes {
+			file := name + ".strm"
+			if len(group.Episodes) > 1 {
+				file = fmt.Sprintf("%s-part%d.strm", name, i+1)
+			}
+			item.Files[file] = []byte(episode.URL + "\n")
+		}
+	} else {
+		item.Files["tvshow.nfo"] = buildLibraryNFO("tvshow", video)
+		for i, episode := range group.Episodes {
+			file := fmt.Sprintf("Season 1/S01E%02d.strm", i+1)
+			item.Files[file] = []byte(episode.URL + "\n")
+		}
+	}
+	return item
+}
+
+// pickLibraryGroup 优先选择可直接播放的播放组（strm 不支持网页解析地址）
+func pickLibraryGroup(groups []PlayGroup) *PlayGroup {
+	for i := range groups {
+		if IsDirectMediaURL(groups[i].Episodes[0].URL) {
+			return &groups[i]
+		}
+	}
+	if len(groups) > 0 {
+		return &groups[0]
+	}
+	return nil
+}
+
+// libraryIsMovie 电影分类按电影导出，其他标准分类按剧集导出，未分类的按集数判断
+func libraryIsMovie(video *models.Video, episodes int) bool {
+	if video.StandardCategoryID > 0 {
+		return video.StandardCategoryID == MovieCategoryID
+	}
+	return episodes <= 1
+}
+
+// buildLibraryNFO 生成 movie.nfo / tvshow.nfo
+func buildLibraryNFO(root string, video *models.Video) []byte {
+	nfo := libraryNFO{
+		XMLName:  xml.Name{Local: root},
+		Title:    video.VodName,
+		Plot:     StripHTML(video.VodContent),
+		Year:     libraryYear(video.VodYear),
+		UniqueID: libraryNFOID{Type: "vodcms", Default: true, ID: strconv.Itoa(video.VodID)},
+	}
+	if video.VodEn != "" && video.VodEn != video.VodName {
+		nfo.OriginalTitle = video.VodEn
+	}
+	for _, genre := range []string{video.StandardCategoryName, video.StandardSubCategoryName} {
+		if genre != "" {
+			nfo.Genres = append(nfo.Genres, genre)
+		}
+	}
+	nfo.Directors = splitLibraryNames(video.VodDirector)
+	for _, actor := range splitLibraryNames(video.VodActor) {
+		nfo.Actors = append(nfo.Actors, libraryNFOActor{Name: actor})
+	}
+	if video.VodPic != "" {
+		nfo.Thumb = &libraryNFOThumb{Aspect: "poster", URL: video.VodPic}
+	}
+
+	data, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return nil
+	}
+	return append([]byte(xml.Header), append(data, '\n')...)
+}
+
+var (
+	libraryNameSeparators = regexp.MustCompile(`[,，/、|]+`)
+	libraryYearPattern    = regexp.MustCompile(`^\d{4}$`)
+)
+
+// splitLibraryNames 拆分演员/导演列表
+func splitLibraryNames(s string) []string {
+	var names []string
+	for _, name := range libraryNameSeparators.Split(s, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// libraryYear 只接受四位年份
+func libraryYear(year string) string {
+	year = strings.TrimSpace(year)
+	if libraryYearPattern.MatchString(year) && year != "0000" {
+		return year
+	}
+	return ""
+}
+
+// libraryFileName 把片名转换为合法的文件夹名
+func libraryFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	if utf8.RuneCountInString(name) > libraryNameMaxLen {
+		name = string([]rune(name)[:libraryNameMaxLen])
+	}
+	return strings.Trim(name, ". ")
+}
+
+// writeLibraryItem 清空视频目录后写入所有文件
+func writeLibraryItem(dir string, item *libraryItem) error {
+	target := filepath.Join(dir, filepath.FromSlash(item.Path))
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	for name, data := range item.Files {
+		file := filepath.Join(target, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeLibraryPath 删除视频目录，只处理输出目录内的相对路径
+func removeLibraryPath(dir, path string) {
+	path = filepath.FromSlash(path)
+	if path == "" || !filepath.IsLocal(path) {
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(dir, path)); err != nil {
+		fmt.Printf("⚠️ 删除 %s 失败: %v\n", path, err)
+	}
+}
+
+func libraryPathExists(dir, path string) bool {
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path)))
+	return err == nil
+}
+
+func loadLibraryManifest(dir string) (*libraryManifest, error) {
+	manifest := &libraryManifest{Items: make(map[int]libraryManifestItem)}
+	data, err := os.ReadFile(filepath.Join(dir, libraryManifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取媒体库清单失败: %w", err)
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("解析媒体库清单失败: %w", err)
+	}
+	if manifest.Items == nil {
+		manifest.Items = make(map[int]libraryManifestItem)
+	}
+	return manifest, nil
+}
+
+// saveLibraryManifest 先写临时文件再改名，避免中断时清单损坏
+func saveLibraryManifest(dir string, items map[int]libraryManifestItem) error {
+	data, err := json.MarshalIndent(libraryManifest{UpdatedAt: time.Now(), Items: items}, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(dir, libraryManifestName)
+	if err := os.WriteFile(file+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("保存媒体库清单失败: %w", err)
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return fmt.Errorf("保存媒体库清单失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestLibraryFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"庆余年", "庆余年"},
+		{"黑客帝国：矩阵重启", "黑客帝国：矩阵重启"}, // 全角冒号合法
+		{"A/B: C?", "A B C"},
+		{"  多个   空格 ", "多个 空格"},
+		{"../../etc/passwd", "etc passwd"}, // 不能跳出输出目录
+		{"..", ""},
+		{"片名.", "片名"},
+		{"换行\n制表\t", "换行 制表"},
+		{strings.Repeat("长", libraryNameMaxLen+5), strings.Repeat("长", libraryNameMaxLen)},
+	}
+	for _, tt := range tests {
+		if got := libraryFileName(tt.name); got != tt.want {
+			t.Errorf("libraryFileName(%q) = %q，期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveLibraryPath(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "library")
+	outside := filepath.Join(root, "outside")
+	for _, d := range []string{filepath.Join(dir, "movies", "A"), outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path    string
+		target  string
+		removed bool
+	}{
+		{"", dir, false},
+		{"../outside", outside, false},
+		{outside, outside, false}, // 绝对路径
+		{"movies/A", filepath.Join(dir, "movies", "A"), true},
+	}
+	for _, tt := range tests {
+		removeLibraryPath(dir, tt.path)
+		_, err := os.Stat(tt.target)
+		if removed := os.IsNotExist(err); removed != tt.removed {
+			t.Errorf("removeLibraryPath(%q): removed = %v，期望 %v", tt.path, removed, tt.removed)
+		}
+	}
+}
+
+func TestExportLibraryPaths(t *testing.T) {
+	db := newTestDB(t)
+	videos := []models.Video{
+		{VodID: 1, VodName: "黑客帝国", VodYear: "1999", SourceKey: "a", StandardCategoryID: 1, StandardCategoryName: "电影",
+			VodPlayFrom: "m3u8", VodPlayURL: "正片$https://a.example.com/1.m3u8"},
+		{VodID: 2, VodName: "黑客帝国", VodYear: "1999", SourceKey: "a", StandardCategoryID: 1, StandardCategoryName: "电影",
+			VodPlayFrom: "m3u8", VodPlayURL: "正片$https://a.example.com/2.m3u8"},
+		{VodID: 3, VodName: "../逃逸/剧", VodYear: "未知", SourceKey: "a", StandardCategoryID: 2, StandardCategoryName: "电视剧",
+			VodPlayFrom: "m3u8", VodPlayURL: "第1集$https://a.example.com/3-1.m3u8#第2集$https://a.example.com/3-2.m3u8"},
+		{VodID: 4, VodName: "没有地址", SourceKey: "a"},
+	}
+	if err := db.Create(&videos).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "library")
+	// 被篡改的清单：指向输出目录外的记录不能被删除
+	outside := filepath.Join(root, "outside")
+	os.MkdirAll(outside, 0755)
+	os.MkdirAll(dir, 0755)
+	manifest, _ := json.Marshal(libraryManifest{Items: map[int]libraryManifestItem{99: {Path: "../outside", Hash: "x"}}})
+	os.WriteFile(filepath.Join(dir, libraryManifestName), manifest, 0644)
+
+	stats, err := ExportLibrary(db, dir, nil)
+	if err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	if stats.Total != 4 || stats.Written != 3 || stats.Skipped != 1 || stats.Removed != 1 {
+		t.Errorf("统计 %+v", stats)
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("输出目录外的目录被删除: %v", err)
+	}
+
+	files := []string{
+		"movies/黑客帝国 (1999)/黑客帝国 (1999).strm",
+		"movies/黑客帝国 (1999)/movie.nfo",
+		"movies/黑客帝国 (1999) [2]/黑客帝国 (1999).strm", // 同名同年份用 vod_id 区分
+		"tvshows/逃逸 剧/tvshow.nfo",
+		"tvshows/逃逸 剧/Season 1/S01E02.strm",
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+			t.Errorf("缺少文件 %s", file)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "movies", "黑客帝国 (1999) [2]", "黑客帝国 (1999).strm"))
+	if string(data) != "https://a.example.com/2.m3u8\n" {
+		t.Errorf("strm 内容 %q", data)
+	}
+
+	// 没有变化时不重写；片名变化后删除旧目录
+	stats, err = ExportLibrary(db, dir, nil)
+	if err != nil || stats.Unchanged != 3 || stats.Written != 0 {
+		t.Fatalf("再次导出 %+v %v", stats, err)
+	}
+	db.Model(&models.Video{}).Where("vod_id = ?", 3).Update("vod_name", "新片名")
+	stats, err = ExportLibrary(db, dir, nil)
+	if err != nil || stats.Written != 1 {
+		t.Fatalf("改名后导出 %+v %v", stats, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tvshows", "逃逸 剧")); !os.IsNotExist(err) {
+		t.Error("旧目录没有删除")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tvshows", "新片名", "Season 1", "S01E01.strm")); err != nil {
+		t.Error("缺少改名后的目录")
+	}
+}
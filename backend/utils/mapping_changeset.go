@@ -0,0 +1,447 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// 映射规则变更集
+// 所有修改映射规则（mapping_rules、fuzzy_match_rules、subcategory_rules）的操作都记录为一个变更集：
+// 1. 修改前调用 BeginMappingChange 记录规则快照，修改后调用 End 对比快照生成变更集（没有变化时不记录）
+//    同一时间只允许一个规则修改操作，保证对比结果只包含本次操作的修改
+// 2. 回滚：按相反顺序把变更集中的规则恢复为修改前的状态；规则之后又被修改过时视为冲突（可强制回滚）
+//    回滚总会记录一个变更集并标记 rolled_back_by（规则已是修改前的状态时为空变更集），同一变更集只能回滚一次
+// 3. 对比：合并两个时间点之间的所有变更集，得到每条规则的净变化
+// 快照不包含 updated_at，只修改了更新时间的规则不算变化
+
+// 规则变化类型
+const (
+	MappingChangeCreate = "create"
+	MappingChangeUpdate = "update"
+	MappingChangeDelete = "delete"
+)
+
+var (
+	// ErrChangesetRolledBack 变更集已经回滚过
+	ErrChangesetRolledBack = errors.New("该变更集已回滚")
+	// ErrRollbackConflict 规则在变更集之后又被修改过
+	ErrRollbackConflict = errors.New("部分规则在该变更集之后又被修改过")
+)
+
+// MappingRuleChange 一条规则的变化（before/after 为规则的 JSON，新增时没有 before，删除时没有 after）
+type MappingRuleChange struct {
+	Table  string          `json:"table"`
+	RuleID uint            `json:"rule_id"`
+	Op     string          `json:"op"`
+	Fields []string        `json:"fields,omitempty"` // update 时变化的字段
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// MappingRollbackConflict 回滚冲突：规则的当前状态与变更集修改后的状态不同
+type MappingRollbackConflict struct {
+	Table    string          `json:"table"`
+	RuleID   uint            `json:"rule_id"`
+	Expected json.RawMessage `json:"expected"` // 变更集修改后的状态（为空表示已删除）
+	Current  json.RawMessage `json:"current"`  // 当前状态（为空表示不存在）
+}
+
+// mappingRuleTable 参与版本记录的规则表
+type mappingRuleTable struct {
+	name    string
+	newRow  func() interface{}
+	newRows func() interface{}
+}
+
+var mappingRuleTables = []mappingRuleTable{
+	{"mapping_rules", func() interface{} { return &models.MappingRule{} }, func() interface{} { return &[]models.MappingRule{} }},
+	{"fuzzy_match_rules", func() interface{} { return &models.FuzzyMatchRule{} }, func() interface{} { return &[]models.FuzzyMatchRule{} }},
+	{"subcategory_rules", func() interface{} { return &models.SubCategoryRule{} }, func() interface{} { return &[]models.SubCategoryRule{} }},
+}
+
+// mappingSnapshot 各规则表的快照：表名 → 规则ID → JSON
+type mappingSnapshot map[string]map[uint]json.RawMessage
+
+var mappingChangeMu sync.Mutex
+
+// MappingChange 一次规则修改操作
+type MappingChange struct {
+	Author     string
+	Action     string
+	Summary    string // 为空时按变化数量生成
+	RollbackOf *uint
+	// RecordEmpty 没有变化时也记录变更集（用于标记只执行一次的操作已执行）
+	RecordEmpty bool
+
+	db        *gorm.DB
+	before    mappingSnapshot
+	ended     bool
+	changeset *models.MappingChangeset
+}
+
+// BeginMappingChange 开始一次规则修改（必须调用 End，建议 defer change.End()）
+// 从 Begin 到 End 期间持有全局锁，其他规则修改会等待：参数校验、查找要修改的规则等可能提前返回的操作应放在 Begin 之前，
+// 只有"先检查再写入"的查询（如按来源查找已有规则后决定新增或更新）需要放在锁内
+func BeginMappingChange(db *gorm.DB, author, action string) *MappingChange {
+	mappingChangeMu.Lock()
+	change := &MappingChange{Author: author, Action: action, db: db}
+	before, err := snapshotMappingRules(db)
+	if err != nil {
+		fmt.Printf("⚠️ 读取映射规则快照失败，本次修改不会记录变更集: %v\n", err)
+	}
+	change.before = before
+	return change
+}
+
+// End 结束修改并生成变更集（没有变化时返回 nil，重复调用返回同一结果）
+func (c *MappingChange) End() *models.MappingChangeset {
+	if c.ended {
+		return c.changeset
+	}
+	c.ended = true
+	defer mappingChangeMu.Unlock()
+	if c.before == nil {
+		return nil
+	}
+
+	after, err := snapshotMappingRules(c.db)
+	if err != nil {
+		fmt.Printf("⚠️ 读取映射规则快照失败，本次修改不会记录变更集: %v\n", err)
+		return nil
+	}
+	changes := diffMappingSnapshots(c.before, after)
+	if len(changes) == 0 && c.RollbackOf == nil && !c.RecordEmpty {
+		return nil
+	}
+	if changes == nil {
+		changes = []MappingRuleChange{} // 回滚时规则已是修改前的状态，仍记录空变更集用于标记已回滚（RecordEmpty 同理）
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		fmt.Printf("⚠️ 编码映射规则变更失败: %v\n", err)
+		return nil
+	}
+
+	changeset := &models.MappingChangeset{
+		Author:      c.Author,
+		Action:      c.Action,
+		Summary:     c.Summary,
+		Changes:     string(data),
+		ChangeCount: len(changes),
+		RollbackOf:  c.RollbackOf,
+	}
+	if changeset.Summary == "" {
+		changeset.Summary = summarizeMappingChanges(changes)
+	}
+	if err := c.db.Create(changeset).Error; err != nil {
+		fmt.Printf("⚠️ 保存映射规则变更集失败: %v\n", err)
+		return nil
+	}
+	if c.RollbackOf != nil {
+		c.db.Model(&models.MappingChangeset{}).Where("id = ?", *c.RollbackOf).Update("rolled_back_by", changeset.ID)
+	}
+	fmt.Printf("📝 映射规则变更集 #%d（%s，%s）: %s\n", changeset.ID, changeset.Action, changeset.Author, changeset.Summary)
+	c.changeset = changeset
+	return changeset
+}
+
+// ParseMappingChanges 解析变更集中的规则变化
+func ParseMappingChanges(changeset *models.MappingChangeset) ([]MappingRuleChange, error) {
+	var changes []MappingRuleChange
+	if changeset.Changes == "" {
+		return changes, nil
+	}
+	if err := json.Unmarshal([]byte(changeset.Changes), &changes); err != nil {
+		return nil, fmt.Errorf("解析变更集失败: %w", err)
+	}
+	return changes, nil
+}
+
+// RollbackMappingChangeset 回滚变更集，返回回滚产生的变更集（规则已是修改前的状态时 change_count 为 0）
+// 有冲突且 force 为 false 时返回 ErrRollbackConflict 和冲突列表
+func RollbackMappingChangeset(db *gorm.DB, id uint, author string, force bool) (*models.MappingChangeset, []MappingRuleChange, []MappingRollbackConflict, error) {
+	var target models.MappingChangeset
+	if err := db.First(&target, id).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	if target.RolledBackBy != nil {
+		return nil, nil, nil, ErrChangesetRolledBack
+	}
+	changes, err := ParseMappingChanges(&target)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	change := BeginMappingChange(db, author, "rollback")
+	defer change.End()
+	if change.before == nil {
+		return nil, nil, nil, fmt.Errorf("读取当前规则失败")
+	}
+	// 加锁后再检查一次，避免并发的两次回滚都通过上面的检查
+	if err := db.First(&target, id).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	if target.RolledBackBy != nil {
+		return nil, nil, nil, ErrChangesetRolledBack
+	}
+
+	var conflicts []MappingRollbackConflict
+	for _, rc := range changes {
+		current := change.before[rc.Table][rc.RuleID]
+		if !bytes.Equal(current, rc.After) {
+			conflicts = append(conflicts, MappingRollbackConflict{Table: rc.Table, RuleID: rc.RuleID, Expected: rc.After, Current: current})
+		}
+	}
+	if len(conflicts) > 0 && !force {
+		return nil, changes, conflicts, ErrRollbackConflict
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i := len(changes) - 1; i >= 0; i-- {
+			if err := restoreMappingRule(tx, changes[i].Table, changes[i].RuleID, changes[i].Before); err != nil {
+				return fmt.Errorf("恢复 %s #%d 失败: %w", changes[i].Table, changes[i].RuleID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, changes, conflicts, err
+	}
+	// 只有回滚成功时才关联目标变更集（冲突或失败提前返回时 End 不会标记已回滚）
+	change.RollbackOf = &target.ID
+	change.Summary = fmt.Sprintf("回滚变更集 #%d（%s）", target.ID, target.Action)
+	return change.End(), changes, conflicts, nil
+}
+
+// ResolveMappingChangesetPoint 把时间点解析为变更集ID（该时间点及之前最后一个变更集）
+// value 可以是变更集ID、RFC3339 时间、"2006-01-02 15:04:05" 或 "2006-01-02"；为空时 latest 为 true 取最新，否则为 0（最初）
+func ResolveMappingChangesetPoint(db *gorm.DB, value string, latest bool) (uint, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if !latest {
+			return 0, nil
+		}
+		var id uint
+		err := db.Model(&models.MappingChangeset{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+		return id, err
+	}
+	if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return uint(id), nil
+	}
+
+	var at time.Time
+	var err error
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if at, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("无法识别的时间点 %s（可用变更集ID、RFC3339 或 2006-01-02 15:04:05）", value)
+	}
+	var id uint
+	err = db.Model(&models.MappingChangeset{}).Where("created_at <= ?", at).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// DiffMappingChangesets 合并变更集 (fromID, toID] 中的修改，得到每条规则的净变化
+func DiffMappingChangesets(db *gorm.DB, fromID, toID uint) ([]MappingRuleChange, int, error) {
+	var changesets []models.MappingChangeset
+	if err := db.Where("id > ? AND id <= ?", fromID, toID).Order("id ASC").Find(&changesets).Error; err != nil {
+		return nil, 0, err
+	}
+
+	type net struct {
+		table  string
+		ruleID uint
+		before json.RawMessage
+		after  json.RawMessage
+	}
+	nets := make(map[string]*net)
+	var order []string
+	for i := range changesets {
+		changes, err := ParseMappingChanges(&changesets[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, rc := range changes {
+			key := fmt.Sprintf("%s:%d", rc.Table, rc.RuleID)
+			n, ok := nets[key]
+			if !ok {
+				n = &net{table: rc.Table, ruleID: rc.RuleID, before: rc.Before}
+				nets[key] = n
+				order = append(order, key)
+			}
+			n.after = rc.After
+		}
+	}
+
+	result := []MappingRuleChange{}
+	for _, key := range order {
+		n := nets[key]
+		if rc, ok := newMappingRuleChange(n.table, n.ruleID, n.before, n.after); ok {
+			result = append(result, rc)
+		}
+	}
+	return result, len(changesets), nil
+}
+
+// Scope 规则变化影响的重新分类范围（模糊规则和子分类规则影响全部视频）
+func (rc MappingRuleChange) Scope() RecategorizeOptions {
+	if rc.Table != "mapping_rules" {
+		return RecategorizeOptions{}
+	}
+	data := rc.After
+	if data == nil {
+		data = rc.Before
+	}
+	var rule models.MappingRule
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return RecategorizeOptions{}
+	}
+	return RecategorizeOptions{SourceKey: rule.SourceKey, SourceTypeID: rule.SourceTypeID}
+}
+
+// snapshotMappingRules 读取所有规则表
+func snapshotMappingRules(db *gorm.DB) (mappingSnapshot, error) {
+	snapshot := make(mappingSnapshot, len(mappingRuleTables))
+	for _, table := range mappingRuleTables {
+		rows := table.newRows()
+		if err := db.Order("id ASC").Find(rows).Error; err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(rows)
+		if err != nil {
+			return nil, err
+		}
+		var items []map[string]interface{}
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+
+		snapshot[table.name] = make(map[uint]json.RawMessage, len(items))
+		for _, item := range items {
+			id, _ := item["id"].(float64)
+			delete(item, "updated_at")
+			row, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			snapshot[table.name][uint(id)] = row
+		}
+	}
+	return snapshot, nil
+}
+
+// diffMappingSnapshots 对比两次快照
+func diffMappingSnapshots(before, after mappingSnapshot) []MappingRuleChange {
+	var changes []MappingRuleChange
+	for _, table := range mappingRuleTables {
+		ids := make(map[uint]bool)
+		for id := range before[table.name] {
+			ids[id] = true
+		}
+		for id := range after[table.name] {
+			ids[id] = true
+		}
+		sorted := make([]uint, 0, len(ids))
+		for id := range ids {
+			sorted = append(sorted, id)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		for _, id := range sorted {
+			if rc, ok := newMappingRuleChange(table.name, id, before[table.name][id], after[table.name][id]); ok {
+				changes = append(changes, rc)
+			}
+		}
+	}
+	return changes
+}
+
+// newMappingRuleChange 根据修改前后的状态生成规则变化（没有变化时 ok 为 false）
+func newMappingRuleChange(table string, id uint, before, after json.RawMessage) (MappingRuleChange, bool) {
+	rc := MappingRuleChange{Table: table, RuleID: id, Before: before, After: after}
+	switch {
+	case before == nil && after == nil:
+		return rc, false
+	case before == nil:
+		rc.Op = MappingChangeCreate
+	case after == nil:
+		rc.Op = MappingChangeDelete
+	case bytes.Equal(before, after):
+		return rc, false
+	default:
+		rc.Op = MappingChangeUpdate
+		rc.Fields = changedRuleFields(before, after)
+	}
+	return rc, true
+}
+
+// changedRuleFields 两个规则 JSON 之间变化的字段
+func changedRuleFields(before, after json.RawMessage) []string {
+	var a, b map[string]interface{}
+	json.Unmarshal(before, &a)
+	json.Unmarshal(after, &b)
+	var fields []string
+	for key, value := range b {
+		if !reflect.DeepEqual(a[key], value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// restoreMappingRule 把规则恢复为指定状态（data 为空时删除）
+func restoreMappingRule(tx *gorm.DB, tableName string, id uint, data json.RawMessage) error {
+	for _, table := range mappingRuleTables {
+		if table.name != tableName {
+			continue
+		}
+		if data == nil {
+			return tx.Delete(table.newRow(), id).Error
+		}
+		row := table.newRow()
+		if err := json.Unmarshal(data, row); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Table(tableName).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			// 先插入再整行更新，避免带默认值的零值字段（如 is_active=false）被默认值覆盖
+			if err := tx.Create(row).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(row).Error
+	}
+	return fmt.Errorf("未知的规则表 %s", tableName)
+}
+
+func summarizeMappingChanges(changes []MappingRuleChange) string {
+	counts := map[string]int{}
+	for _, rc := range changes {
+		counts[rc.Op]++
+	}
+	return fmt.Sprintf("新增 %d 条，修改 %d 条，删除 %d 条规则", counts[MappingChangeCreate], counts[MappingChangeUpdate], counts[MappingChangeDelete])
+}
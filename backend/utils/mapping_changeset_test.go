@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+func TestDiffMappingSnapshots(t *testing.T) {
+	before := mappingSnapshot{
+		"mapping_rules": {
+			1: json.RawMessage(`{"id":1,"standard_id":1}`),
+			2: json.RawMessage(`{"id":2,"standard_id":2}`),
+			3: json.RawMessage(`{"id":3,"standard_id":3}`),
+		},
+	}
+	after := mappingSnapshot{
+		"mapping_rules": {
+			1: json.RawMessage(`{"id":1,"standard_id":1}`),
+			2: json.RawMessage(`{"id":2,"standard_id":4,"priority":50}`),
+		},
+		"fuzzy_match_rules": {
+			5: json.RawMessage(`{"id":5,"pattern":"动作"}`),
+		},
+	}
+
+	changes := diffMappingSnapshots(before, after)
+	want := []struct {
+		table  string
+		ruleID uint
+		op     string
+		fields []string
+	}{
+		{"mapping_rules", 2, MappingChangeUpdate, []string{"priority", "standard_id"}},
+		{"mapping_rules", 3, MappingChangeDelete, nil},
+		{"fuzzy_match_rules", 5, MappingChangeCreate, nil},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("得到 %d 条变化，期望 %d 条: %+v", len(changes), len(want), changes)
+	}
+	for i, w := range want {
+		rc := changes[i]
+		if rc.Table != w.table || rc.RuleID != w.ruleID || rc.Op != w.op || !reflect.DeepEqual(rc.Fields, w.fields) {
+			t.Errorf("第 %d 条为 %s #%d %s %v，期望 %s #%d %s %v", i, rc.Table, rc.RuleID, rc.Op, rc.Fields, w.table, w.ruleID, w.op, w.fields)
+		}
+	}
+
+	if changes := diffMappingSnapshots(before, before); len(changes) != 0 {
+		t.Errorf("相同快照不应有变化: %+v", changes)
+	}
+}
+
+// createMappingRule 在一个变更集中新增映射规则
+func createMappingRule(t *testing.T, db *gorm.DB, rule *models.MappingRule) *models.MappingChangeset {
+	t.Helper()
+	change := BeginMappingChange(db, "tester", "add_rule")
+	if err := db.Create(rule).Error; err != nil {
+		change.End()
+		t.Fatalf("创建规则失败: %v", err)
+	}
+	return change.End()
+}
+
+func TestDiffMappingChangesets(t *testing.T) {
+	db := newTestDB(t)
+
+	rule := models.MappingRule{SourceKey: "a", SourceTypeID: 1, SourceName: "动作片", StandardID: 1, MatchType: MatchTypeExact, IsActive: true}
+	first := createMappingRule(t, db, &rule)
+	if first == nil || first.ChangeCount != 1 {
+		t.Fatalf("新增规则应生成变更集: %+v", first)
+	}
+
+	change := BeginMappingChange(db, "tester", "update_rule")
+	db.Model(&rule).Update("standard_id", 2)
+	second := change.End()
+
+	other := models.MappingRule{SourceKey: "a", SourceTypeID: 2, SourceName: "喜剧片", StandardID: 1, MatchType: MatchTypeExact, IsActive: true}
+	third := createMappingRule(t, db, &other)
+
+	change = BeginMappingChange(db, "tester", "delete_rule")
+	db.Delete(&models.MappingRule{}, other.ID)
+	change.End()
+
+	// 全部变更集：第一条规则为新增（合并了后续修改），第二条规则新增后又删除，没有净变化
+	changes, count, err := DiffMappingChangesets(db, 0, third.ID+1)
+	if err != nil {
+		t.Fatalf("对比失败: %v", err)
+	}
+	if count != 4 || len(changes) != 1 || changes[0].RuleID != rule.ID || changes[0].Op != MappingChangeCreate {
+		t.Fatalf("得到 %d 个变更集、%+v", count, changes)
+	}
+	var got models.MappingRule
+	json.Unmarshal(changes[0].After, &got)
+	if got.StandardID != 2 {
+		t.Errorf("净变化应为修改后的状态，standard_id = %d", got.StandardID)
+	}
+
+	// 从第一个变更集之后开始：只有修改
+	changes, _, err = DiffMappingChangesets(db, first.ID, second.ID)
+	if err != nil || len(changes) != 1 || changes[0].Op != MappingChangeUpdate || !reflect.DeepEqual(changes[0].Fields, []string{"standard_id"}) {
+		t.Errorf("得到 %+v（%v）", changes, err)
+	}
+}
+
+func TestRollbackMappingChangesetNoop(t *testing.T) {
+	db := newTestDB(t)
+
+	rule := models.MappingRule{SourceKey: "a", SourceTypeID: 1, SourceName: "动作片", StandardID: 1, MatchType: MatchTypeExact, IsActive: true}
+	target := createMappingRule(t, db, &rule)
+
+	// 规则被手动删除后，规则已是变更集之前的状态
+	change := BeginMappingChange(db, "tester", "delete_rule")
+	db.Delete(&models.MappingRule{}, rule.ID)
+	change.End()
+
+	changeset, _, conflicts, err := RollbackMappingChangeset(db, target.ID, "tester", true)
+	if err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Errorf("规则已被删除，应有 1 个冲突，得到 %+v", conflicts)
+	}
+	if changeset == nil || changeset.ChangeCount != 0 || changeset.RollbackOf == nil || *changeset.RollbackOf != target.ID {
+		t.Fatalf("应记录空的回滚变更集: %+v", changeset)
+	}
+
+	var saved models.MappingChangeset
+	db.First(&saved, target.ID)
+	if saved.RolledBackBy == nil || *saved.RolledBackBy != changeset.ID {
+		t.Errorf("rolled_back_by 应为 %d，得到 %v", changeset.ID, saved.RolledBackBy)
+	}
+	if _, _, _, err := RollbackMappingChangeset(db, target.ID, "tester", true); !errors.Is(err, ErrChangesetRolledBack) {
+		t.Errorf("重复回滚应返回 ErrChangesetRolledBack，得到 %v", err)
+	}
+}
+
+func TestRollbackMappingChangesetConflict(t *testing.T) {
+	db := newTestDB(t)
+
+	rule := models.MappingRule{SourceKey: "a", SourceTypeID: 1, SourceName: "动作片", StandardID: 1, MatchType: MatchTypeExact, IsActive: true}
+	target := createMappingRule(t, db, &rule)
+	db.Model(&rule).Update("standard_id", 2) // 变更集之后又被修改
+
+	_, _, conflicts, err := RollbackMappingChangeset(db, target.ID, "tester", false)
+	if !errors.Is(err, ErrRollbackConflict) || len(conflicts) != 1 {
+		t.Fatalf("应返回冲突，得到 %v %+v", err, conflicts)
+	}
+	var saved models.MappingChangeset
+	db.First(&saved, target.ID)
+	if saved.RolledBackBy != nil {
+		t.Error("冲突时不应标记为已回滚")
+	}
+
+	changeset, _, _, err := RollbackMappingChangeset(db, target.ID, "tester", true)
+	if err != nil || changeset == nil || changeset.ChangeCount != 1 {
+		t.Fatalf("强制回滚应删除规则: %+v（%v）", changeset, err)
+	}
+	var count int64
+	db.Model(&models.MappingRule{}).Where("id = ?", rule.ID).Count(&count)
+	if count != 0 {
+		t.Error("回滚新增规则后规则应被删除")
+	}
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"vodcms/models"
+
+	"gorm.io/gorm"
+)
+
+//go:embed pinyin_dict.txt
+var pinyinDictData string
+
+var (
+	pinyinOnce sync.Once
+	// pinyinChars 单字 -> 拼音
+	pinyinChars map[rune]string
+	// pinyinPhrases 词组 -> 逐字拼音（修正多音字）
+	pinyinPhrases map[string][]string
+	// pinyinMaxPhrase 最长词组字数
+	pinyinMaxPhrase int
+)
+
+// loadPinyinDict 解析内嵌字典（首次使用时加载）
+func loadPinyinDict() {
+	pinyinChars = make(map[rune]string, 21000)
+	pinyinPhrases = make(map[string][]string)
+
+	for _, line := range strings.Split(pinyinDictData, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "@") {
+			parts := strings.Fields(line[1:])
+			if len(parts) < 2 || utf8.RuneCountInString(parts[0]) != len(parts)-1 {
+				continue
+			}
+			pinyinPhrases[parts[0]] = parts[1:]
+			if n := len(parts) - 1; n > pinyinMaxPhrase {
+				pinyinMaxPhrase = n
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		// 多音字取第一次出现的读音（字典开头列出常用读音），其余读音由词组修正
+		for _, r := range parts[1] {
+			if _, ok := pinyinChars[r]; !ok {
+				pinyinChars[r] = parts[0]
+			}
+		}
+	}
+}
+
+// PinyinSyllables 把文本转换为拼音音节列表
+// 汉字逐字转换（优先匹配多音字词组），连续的字母数字作为一个整体（转小写），其余字符忽略
+func PinyinSyllables(text string) []string {
+	pinyinOnce.Do(loadPinyinDict)
+
+	runes := []rune(text)
+	var result []string
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		if isASCIIAlnum(r) {
+			j := i
+			for j < len(runes) && isASCIIAlnum(runes[j]) {
+				j++
+			}
+			result = append(result, strings.ToLower(string(runes[i:j])))
+			i = j
+			continue
+		}
+
+		if unicode.Is(unicode.Han, r) {
+			matched := false
+			for n := min(pinyinMaxPhrase, len(runes)-i); n >= 2; n-- {
+				if syllables, ok := pinyinPhrases[string(runes[i:i+n])]; ok {
+					result = append(result, syllables...)
+					i += n
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+			if py, ok := pinyinChars[r]; ok {
+				result = append(result, py)
+			}
+		}
+		i++
+	}
+	return result
+}
+
+// Pinyin 获取全拼和首字母，如 "庆余年2" -> ("qingyunian2", "qyn2")
+func Pinyin(text string) (full, initials string) {
+	syllables := PinyinSyllables(text)
+	var fb, ib strings.Builder
+	for _, s := range syllables {
+		fb.WriteString(s)
+		if s[0] >= '0' && s[0] <= '9' {
+			// 数字整体保留，方便搜索 "qyn2"
+			ib.WriteString(s)
+		} else {
+			ib.WriteByte(s[0])
+		}
+	}
+	return fb.String(), ib.String()
+}
+
+// PinyinLetter 获取首字母（大写A-Z，数字开头返回 0-9，无法识别返回空）
+func PinyinLetter(text string) string {
+	for _, s := range PinyinSyllables(text) {
+		c := s[0]
+		if c >= 'a' && c <= 'z' {
+			return string(c - 'a' + 'A')
+		}
+		if c >= '0' && c <= '9' {
+			return "0-9"
+		}
+	}
+	return ""
+}
+
+// IsValidVodLetter 判断 vod_letter 是否为合法的首字母（A-Z 或 0-9）
+func IsValidVodLetter(letter string) bool {
+	if letter == "0-9" {
+		return true
+	}
+	return len(letter) == 1 && letter[0] >= 'A' && letter[0] <= 'Z'
+}
+
+// FillVideoPinyin 根据片名生成拼音字段，并补全 vod_letter
+// 上游的 vod_letter 为合法首字母（A-Z 或 0-9）时保留，为空或不合法时才从片名推导；被锁定的字段保留原值
+func FillVideoPinyin(video *models.Video) {
+	locked := make(map[string]bool)
+	for _, field := range LockedFields(video) {
+		locked[field] = true
+	}
+
+	full, initials := Pinyin(video.VodName)
+	if !locked["vod_pinyin"] {
+		video.VodPinyin = full
+	}
+	if !locked["vod_pinyin_initials"] {
+		video.VodPinyinInitials = initials
+	}
+	if locked["vod_letter"] {
+		return
+	}
+
+	letter := strings.ToUpper(strings.TrimSpace(video.VodLetter))
+	if len(letter) == 1 && letter[0] >= '0' && letter[0] <= '9' {
+		letter = "0-9"
+	}
+	if !IsValidVodLetter(letter) {
+		letter = PinyinLetter(video.VodName)
+	}
+	video.VodLetter = letter
+}
+
+// BackfillVideoPinyin 为尚未生成拼音的视频补全拼音字段（启动时执行，升级前导入的数据也能按拼音检索）
+func BackfillVideoPinyin(db *gorm.DB) (int, error) {
+	total := 0
+	var videos []models.Video
+	err := db.Where("(vod_pinyin IS NULL OR vod_pinyin = '') AND vod_name != ''").
+		FindInBatches(&videos, 500, func(tx *gorm.DB, batch int) error {
+			for i := range videos {
+				FillVideoPinyin(&videos[i])
+				if err := tx.Model(&videos[i]).UpdateColumns(map[string]interface{}{
+					"vod_pinyin":          videos[i].VodPinyin,
+					"vod_pinyin_initials": videos[i].VodPinyinInitials,
+					"vod_letter":          videos[i].VodLetter,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			total += len(videos)
+			return nil
+		}).Error
+	return total, err
+}
+
+func isASCIIAlnum(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestPinyin(t *testing.T) {
+	tests := []struct {
+		text     string
+		full     string
+		initials string
+		letter   string
+	}{
+		{"庆余年2", "qingyunian2", "qyn2", "Q"},
+		{"重生之门", "chongshengzhimen", "cszm", "C"},
+		{"都挺好", "doutinghao", "dth", "D"},
+		{"了不起的盖茨比", "liaobuqidegaicibi", "lbqdgcb", "L"},
+		{"朝阳警事", "chaoyangjingshi", "cyjs", "C"},
+		{"首都之夜", "shouduzhiye", "sdzy", "S"},
+		{"地球脉动", "diqiumaidong", "dqmd", "D"},
+		{"Friends 第一季", "friendsdiyiji", "fdyj", "F"},
+		{"1917", "1917", "1917", "0-9"},
+		{"【】!!", "", "", ""},
+	}
+	for _, tt := range tests {
+		full, initials := Pinyin(tt.text)
+		if full != tt.full || initials != tt.initials {
+			t.Errorf("Pinyin(%q) = %q/%q，期望 %q/%q", tt.text, full, initials, tt.full, tt.initials)
+		}
+		if letter := PinyinLetter(tt.text); letter != tt.letter {
+			t.Errorf("PinyinLetter(%q) = %q，期望 %q", tt.text, letter, tt.letter)
+		}
+	}
+}
+
+func TestFillVideoPinyinLetter(t *testing.T) {
+	tests := []struct {
+		name   string
+		letter string
+		locked string
+		want   string
+	}{
+		{"庆余年", "", "", "Q"},            // 为空时推导
+		{"庆余年", "q", "", "Q"},           // 合法值规范为大写
+		{"庆余年", "Y", "", "Y"},           // 上游值合法时保留
+		{"庆余年", "7", "", "0-9"},         // 单个数字归为 0-9
+		{"庆余年", "0-9", "", "0-9"},       // 上游值合法时保留
+		{"庆余年", "QY", "", "Q"},          // 不合法时推导
+		{"庆余年", "#", "", "Q"},           // 不合法时推导
+		{"【】", "#", "", ""},             // 无法推导时清空
+		{"庆余年", "#", "vod_letter", "#"}, // 被锁定时保留
+	}
+	for _, tt := range tests {
+		video := models.Video{VodName: tt.name, VodLetter: tt.letter, VodLockFields: tt.locked}
+		FillVideoPinyin(&video)
+		if video.VodLetter != tt.want {
+			t.Errorf("%s/%q: vod_letter = %q，期望 %q", tt.name, tt.letter, video.VodLetter, tt.want)
+		}
+		if video.VodPinyin != "qingyunian" && tt.name == "庆余年" {
+			t.Errorf("%s: vod_pinyin = %q", tt.name, video.VodPinyin)
+		}
+	}
+}
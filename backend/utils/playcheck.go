@@ -0,0 +1,680 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"vodcms/config"
+	"vodcms/models"
+)
+
+// 播放地址可用性检测
+// 1. 每个资源站按采集时间从新到旧抽样视频，最近检测过的跳过
+// 2. 每个播放组随机抽一集检测，结果按（视频记录, 播放组）保存，重复检测时覆盖，不再检测的播放组的结果会被删除
+// 3. m3u8：主播放列表需至少有一个子码率，媒体播放列表需至少有一个分片，且第一个分片可以访问
+//    主播放列表中的 #EXT-X-STREAM-INF 用于识别画质（取分辨率最高的子码率，并继续检测该子码率）
+// 4. 其他直链（mp4 等）：请求前 1KB 能正常返回即可
+// 5. 需要解析的网页地址不检测
+// 6. 与封面代理一样不访问内网地址（IMAGE_ALLOW_PRIVATE 同时放开两者）
+
+// 检测结果状态
+const (
+	PlayCheckOK      = "ok"
+	PlayCheckDead    = "dead"
+	PlayCheckTimeout = "timeout"
+)
+
+const (
+	playCheckTimeout  = 10 * time.Second
+	playCheckMaxBody  = 2 << 20 // m3u8 最大 2MB
+	playCheckWorkers  = 8
+	playCheckMaxAge   = 24 * time.Hour // 24小时内检测过的视频不重复检测
+	playCheckMaxDepth = 3              // 主播放列表最多嵌套层数
+)
+
+// playCheckClient 播放地址来自资源站，与封面代理一样禁止访问内网地址（包括重定向后的地址）
+var playCheckClient = &http.Client{
+	Timeout: playCheckTimeout,
+	Transport: &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: imageDialControl}).DialContext,
+	},
+}
+
+// PlayCheckResult 单个地址的检测结果
+type PlayCheckResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	Variants  int    `json:"variants"`
+	Segments  int    `json:"segments"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Bandwidth int64  `json:"bandwidth"`
+	Quality   string `json:"quality"` // 画质标签（未识别到分辨率时为空）
+}
+
+// M3U8Variant 主播放列表中的子码率
+type M3U8Variant struct {
+	URI       string
+	Width     int
+	Height    int
+	Bandwidth int64
+}
+
+// PlayCheckOptions 检测任务参数
+type PlayCheckOptions struct {
+	SourceKey string `json:"source_key"` // 为空表示所有启用的资源站
+	PerSource int    `json:"per_source"` // 每个资源站抽样的视频数
+}
+
+// PlayCheckStats 检测任务统计
+type PlayCheckStats struct {
+	Videos  int `json:"videos"`
+	Groups  int `json:"groups"`
+	OK      int `json:"ok"`
+	Dead    int `json:"dead"`
+	Timeout int `json:"timeout"`
+}
+
+// PlayCheckJob 检测任务状态（定时任务和手动触发共用，同一时间只运行一个）
+type PlayCheckJob struct {
+	Status     string           `json:"status"`  // idle, running, success, failed
+	Trigger    string           `json:"trigger"` // schedule, manual
+	Options    PlayCheckOptions `json:"options"`
+	Stats      PlayCheckStats   `json:"stats"`
+	Error      string           `json:"error,omitempty"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+var (
+	playCheckJobMu   sync.Mutex
+	playCheckJob     = PlayCheckJob{Status: "idle"}
+	playCheckStarted sync.Once
+)
+
+// GetPlayCheckJob 获取检测任务状态
+func GetPlayCheckJob() PlayCheckJob {
+	playCheckJobMu.Lock()
+	defer playCheckJobMu.Unlock()
+	return playCheckJob
+}
+
+// StartPlayCheckJob 在后台启动检测任务，已有任务运行时返回 false
+func StartPlayCheckJob(db *gorm.DB, opts PlayCheckOptions, trigger string) (PlayCheckJob, bool) {
+	playCheckJobMu.Lock()
+	if playCheckJob.Status == "running" {
+		job := playCheckJob
+		playCheckJobMu.Unlock()
+		return job, false
+	}
+	now := time.Now()
+	playCheckJob = PlayCheckJob{Status: "running", Trigger: trigger, Options: opts, StartedAt: &now}
+	job := playCheckJob
+	playCheckJobMu.Unlock()
+
+	go func() {
+		stats, err := RunPlayCheck(db, opts, func(stats PlayCheckStats) {
+			playCheckJobMu.Lock()
+			playCheckJob.Stats = stats
+			playCheckJobMu.Unlock()
+		})
+
+		playCheckJobMu.Lock()
+		defer playCheckJobMu.Unlock()
+		finished := time.Now()
+		playCheckJob.FinishedAt = &finished
+		if stats != nil {
+			playCheckJob.Stats = *stats
+		}
+		if err != nil {
+			playCheckJob.Status = "failed"
+			playCheckJob.Error = err.Error()
+			return
+		}
+		playCheckJob.Status = "success"
+	}()
+	return job, true
+}
+
+// StartPlayChecker 启动定时检测（PLAY_CHECK_INTERVAL 为 0 时不启动）
+func StartPlayChecker(db *gorm.DB) {
+	interval := config.AppConfig.PlayCheckInterval
+	if interval <= 0 {
+		return
+	}
+	playCheckStarted.Do(func() {
+		go func() {
+			for {
+				time.Sleep(interval)
+				StartPlayCheckJob(db, PlayCheckOptions{}, "schedule")
+			}
+		}()
+	})
+}
+
+// RunPlayCheck 按资源站抽样检测播放地址
+func RunPlayCheck(db *gorm.DB, opts PlayCheckOptions, progress func(PlayCheckStats)) (*PlayCheckStats, error) {
+	if opts.PerSource <= 0 {
+		opts.PerSource = config.AppConfig.PlayCheckSample
+	}
+	var sourceKeys []string
+	if opts.SourceKey != "" {
+		sourceKeys = []string{opts.SourceKey}
+	} else if err := db.Model(&models.Source{}).Where("enabled = ?", true).Pluck("key", &sourceKeys).Error; err != nil {
+		return nil, err
+	}
+
+	// 清理已删除视频的检测结果
+	if err := db.Where("video_id NOT IN (?)", db.Model(&models.Video{}).Select("id")).Delete(&models.PlayCheck{}).Error; err != nil {
+		return nil, err
+	}
+
+	stats := &PlayCheckStats{}
+	fmt.Printf("🔗 开始检测播放地址: %d 个资源站，每个最多 %d 条\n", len(sourceKeys), opts.PerSource)
+
+	for _, sourceKey := range sourceKeys {
+		var videos []models.Video
+		err := db.Where("source_key = ? AND vod_play_url <> ''", sourceKey).
+			Where("id NOT IN (?)", db.Model(&models.PlayCheck{}).
+				Select("video_id").
+				Where("checked_at > ?", time.Now().Add(-playCheckMaxAge))).
+			Order("collected_at DESC").
+			Limit(opts.PerSource).
+			Find(&videos).Error
+		if err != nil {
+			return stats, err
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		jobs := make(chan *models.Video)
+		for i := 0; i < playCheckWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for video := range jobs {
+					checks, err := CheckVideoPlayGroups(db, video)
+					mu.Lock()
+					if err != nil {
+						fmt.Printf("  ⚠️ 保存检测结果失败 (ID:%d): %v\n", video.ID, err)
+					}
+					stats.Videos++
+					for _, check := range checks {
+						stats.Groups++
+						switch check.Status {
+						case PlayCheckOK:
+							stats.OK++
+						case PlayCheckTimeout:
+							stats.Timeout++
+						default:
+							stats.Dead++
+						}
+					}
+					if progress != nil {
+						progress(*stats)
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for i := range videos {
+			jobs <- &videos[i]
+		}
+		close(jobs)
+		wg.Wait()
+	}
+
+	fmt.Printf("✅ 播放地址检测完成: 视频 %d 条，播放组 %d 个，正常 %d，失效 %d，超时 %d\n",
+		stats.Videos, stats.Groups, stats.OK, stats.Dead, stats.Timeout)
+	return stats, nil
+}
+
+// CheckVideoPlayGroups 检测一条视频记录的所有播放组（每组随机抽一集）并保存结果
+func CheckVideoPlayGroups(db *gorm.DB, video *models.Video) ([]models.PlayCheck, error) {
+	groups := VideoPlayGroups(video)
+	var checks []models.PlayCheck
+	var checked []int
+	for i, group := range groups {
+		if !IsDirectMediaURL(group.Episodes[0].URL) {
+			continue
+		}
+		index := rand.Intn(len(group.Episodes))
+		episode := group.Episodes[index]
+		result := CheckPlayURL(episode.URL)
+
+		check := models.PlayCheck{
+			VideoID:      video.ID,
+			GroupIndex:   i,
+			VodID:        video.VodID,
+			SourceKey:    video.SourceKey,
+			PlayFrom:     group.From,
+			EpisodeIndex: index,
+			EpisodeName:  truncateString(episode.Name, 100),
+			EpisodeURL:   truncateString(episode.URL, 1000),
+			Status:       result.Status,
+			Error:        truncateString(result.Error, 500),
+			LatencyMs:    result.LatencyMs,
+			Variants:     result.Variants,
+			Segments:     result.Segments,
+			Width:        result.Width,
+			Height:       result.Height,
+			Bandwidth:    result.Bandwidth,
+			Quality:      result.Quality,
+			CheckedAt:    time.Now(),
+		}
+		var existing models.PlayCheck
+		if db.Where("video_id = ? AND group_index = ?", video.ID, i).Limit(1).Find(&existing).RowsAffected > 0 {
+			check.ID = existing.ID
+			check.CreatedAt = existing.CreatedAt
+		}
+		if err := db.Save(&check).Error; err != nil {
+			return checks, err
+		}
+		checks = append(checks, check)
+		checked = append(checked, i)
+	}
+
+	// 删除本次没有检测的播放组的旧结果（播放组减少或改为网页地址），避免过期结果影响健康度和排序
+	query := db.Where("video_id = ?", video.ID)
+	if len(checked) > 0 {
+		query = query.Where("group_index NOT IN ?", checked)
+	}
+	err := query.Delete(&models.PlayCheck{}).Error
+	return checks, err
+}
+
+// CheckPlayURL 检测单个播放地址
+func CheckPlayURL(rawURL string) PlayCheckResult {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return PlayCheckResult{Status: PlayCheckDead, Error: "无效的播放地址"}
+	}
+	if strings.HasSuffix(strings.ToLower(u.Path), ".m3u8") {
+		return checkM3U8(u)
+	}
+
+	start := time.Now()
+	err = probeMedia(u.String())
+	result := PlayCheckResult{LatencyMs: time.Since(start).Milliseconds()}
+	return finishPlayCheck(result, err)
+}
+
+// checkM3U8 逐层解析主播放列表，直到找到分片并确认第一个分片可以访问
+func checkM3U8(u *url.URL) PlayCheckResult {
+	var result PlayCheckResult
+	for depth := 0; depth < playCheckMaxDepth; depth++ {
+		start := time.Now()
+		body, err := fetchPlaylist(u.String())
+		if depth == 0 {
+			result.LatencyMs = time.Since(start).Milliseconds()
+		}
+		if err != nil {
+			return finishPlayCheck(result, err)
+		}
+
+		variants, segments, err := parseM3U8(body)
+		if err != nil {
+			return finishPlayCheck(result, err)
+		}
+		if len(variants) > 0 {
+			best := bestM3U8Variant(variants)
+			if depth == 0 {
+				result.Variants = len(variants)
+			}
+			if result.Height == 0 && best.Height > 0 {
+				result.Width, result.Height, result.Bandwidth = best.Width, best.Height, best.Bandwidth
+				result.Quality = QualityLabel(best.Width, best.Height)
+			}
+			if u, err = u.Parse(best.URI); err != nil {
+				return finishPlayCheck(result, fmt.Errorf("无效的子码率地址: %w", err))
+			}
+			continue
+		}
+
+		result.Segments = len(segments)
+		if len(segments) == 0 {
+			return finishPlayCheck(result, errors.New("播放列表没有分片"))
+		}
+		segment, err := u.Parse(segments[0])
+		if err != nil {
+			return finishPlayCheck(result, fmt.Errorf("无效的分片地址: %w", err))
+		}
+		if err := probeMedia(segment.String()); err != nil {
+			return finishPlayCheck(result, fmt.Errorf("分片无法访问: %w", err))
+		}
+		return finishPlayCheck(result, nil)
+	}
+	return finishPlayCheck(result, errors.New("主播放列表嵌套过深"))
+}
+
+// parseM3U8 解析播放列表，返回子码率和分片地址
+func parseM3U8(body string) (variants []M3U8Variant, segments []string, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), playCheckMaxBody)
+	first := true
+	var streamInf *M3U8Variant
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff") // 去掉 UTF-8 BOM
+			if line != "#EXTM3U" {
+				return nil, nil, errors.New("不是有效的m3u8文件")
+			}
+			first = false
+			continue
+		}
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			streamInf = parseStreamInf(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+		case strings.HasPrefix(line, "#"):
+		case streamInf != nil:
+			streamInf.URI = line
+			variants = append(variants, *streamInf)
+			streamInf = nil
+		default:
+			segments = append(segments, line)
+		}
+	}
+	if first {
+		return nil, nil, errors.New("播放列表为空")
+	}
+	return variants, segments, scanner.Err()
+}
+
+// parseStreamInf 解析 #EXT-X-STREAM-INF 的 RESOLUTION 和 BANDWIDTH 属性
+// 属性以逗号分隔，引号内的逗号（如 CODECS="avc1,mp4a"）不作为分隔符
+func parseStreamInf(attrs string) *M3U8Variant {
+	variant := &M3U8Variant{}
+	inQuote := false
+	start := 0
+	for i := 0; i <= len(attrs); i++ {
+		if i < len(attrs) && attrs[i] == '"' {
+			inQuote = !inQuote
+		}
+		if i < len(attrs) && (attrs[i] != ',' || inQuote) {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(attrs[start:i]), "=")
+		start = i + 1
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(key) {
+		case "RESOLUTION":
+			if w, h, ok := strings.Cut(strings.ToLower(value), "x"); ok {
+				variant.Width, _ = strconv.Atoi(w)
+				variant.Height, _ = strconv.Atoi(h)
+			}
+		case "BANDWIDTH":
+			variant.Bandwidth, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return variant
+}
+
+// bestM3U8Variant 选择分辨率最高的子码率（分辨率相同或未标注时比较码率）
+func bestM3U8Variant(variants []M3U8Variant) M3U8Variant {
+	best := variants[0]
+	for _, v := range variants[1:] {
+		if v.Width*v.Height > best.Width*best.Height ||
+			(v.Width*v.Height == best.Width*best.Height && v.Bandwidth > best.Bandwidth) {
+			best = v
+		}
+	}
+	return best
+}
+
+// QualityLabel 根据分辨率生成画质标签，宽屏影片（如 1920x800）按宽度计算
+func QualityLabel(width, height int) string {
+	lines := height
+	if w := width * 9 / 16; w > lines {
+		lines = w
+	}
+	switch {
+	case lines >= 2160:
+		return "4K"
+	case lines >= 1440:
+		return "2K"
+	case lines >= 1080:
+		return "1080P"
+	case lines >= 720:
+		return "720P"
+	case lines >= 480:
+		return "480P"
+	case lines > 0:
+		return "360P"
+	}
+	return ""
+}
+
+func fetchPlaylist(rawURL string) (string, error) {
+	resp, err := playCheckRequest(rawURL, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, playCheckMaxBody))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// probeMedia 只请求前 1KB 确认地址可以访问
+func probeMedia(rawURL string) error {
+	resp, err := playCheckRequest(rawURL, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+	return nil
+}
+
+func playCheckRequest(rawURL string, partial bool) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; VodCMS-LinkChecker/1.0)")
+	if partial {
+		req.Header.Set("Range", "bytes=0-1023")
+	}
+	resp, err := playCheckClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func finishPlayCheck(result PlayCheckResult, err error) PlayCheckResult {
+	if err == nil {
+		result.Status = PlayCheckOK
+		return result
+	}
+	result.Error = err.Error()
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		result.Status = PlayCheckTimeout
+	} else {
+		result.Status = PlayCheckDead
+	}
+	return result
+}
+
+// PlayGroupHealth 单个播放组的检测结果
+type PlayGroupHealth struct {
+	PlayFrom  string    `json:"play_from"`
+	Status    string    `json:"status"`
+	LatencyMs int64     `json:"latency_ms"`
+	Quality   string    `json:"quality"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// PlayLinkHealth 一条视频记录（一个资源站）的播放地址可用性
+type PlayLinkHealth struct {
+	Status    string            `json:"status"`     // ok（全部正常）, partial（部分正常）, dead（全部失效）, unknown（未检测）
+	LatencyMs int64             `json:"latency_ms"` // 正常播放组中的最低耗时
+	Quality   string            `json:"quality"`    // 识别到的最高画质（未识别到时为空）
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	CheckedAt *time.Time        `json:"checked_at,omitempty"`
+	Groups    []PlayGroupHealth `json:"groups"`
+}
+
+// VideoPlayHealth 批量查询视频记录的检测结果，按 videos.id 汇总
+func VideoPlayHealth(db *gorm.DB, videoIDs []uint) map[uint]*PlayLinkHealth {
+	result := make(map[uint]*PlayLinkHealth, len(videoIDs))
+	for _, id := range videoIDs {
+		result[id] = &PlayLinkHealth{Status: "unknown", Groups: []PlayGroupHealth{}}
+	}
+	if len(videoIDs) == 0 {
+		return result
+	}
+
+	var checks []models.PlayCheck
+	db.Where("video_id IN ?", videoIDs).Order("video_id ASC, group_index ASC").Find(&checks)
+	ok := make(map[uint]int)
+	for _, check := range checks {
+		health := result[check.VideoID]
+		health.Groups = append(health.Groups, PlayGroupHealth{
+			PlayFrom:  check.PlayFrom,
+			Status:    check.Status,
+			LatencyMs: check.LatencyMs,
+			Quality:   check.Quality,
+			Width:     check.Width,
+			Height:    check.Height,
+			CheckedAt: check.CheckedAt,
+		})
+		if check.Width*check.Height > health.Width*health.Height {
+			health.Width, health.Height, health.Quality = check.Width, check.Height, check.Quality
+		}
+		if health.CheckedAt == nil || check.CheckedAt.After(*health.CheckedAt) {
+			checkedAt := check.CheckedAt
+			health.CheckedAt = &checkedAt
+		}
+		if check.Status == PlayCheckOK {
+			if ok[check.VideoID] == 0 || check.LatencyMs < health.LatencyMs {
+				health.LatencyMs = check.LatencyMs
+			}
+			ok[check.VideoID]++
+		}
+	}
+	for id, health := range result {
+		switch {
+		case len(health.Groups) == 0:
+		case ok[id] == len(health.Groups):
+			health.Status = "ok"
+		case ok[id] > 0:
+			health.Status = "partial"
+		default:
+			health.Status = "dead"
+		}
+	}
+	return result
+}
+
+// SourceLinkHealth 资源站播放地址可用性汇总
+type SourceLinkHealth struct {
+	SourceKey    string     `json:"source_key"`
+	Checked      int64      `json:"checked"` // 检测过的播放组数
+	OK           int64      `json:"ok"`
+	Dead         int64      `json:"dead"`
+	Timeout      int64      `json:"timeout"`
+	OKRate       float64    `json:"ok_rate"`        // 正常比例（0-1）
+	AvgLatencyMs int64      `json:"avg_latency_ms"` // 正常播放组的平均耗时
+	LastChecked  *time.Time `json:"last_checked_at,omitempty"`
+}
+
+// GetSourceLinkHealth 按资源站汇总检测结果（sourceKey 为空时返回所有资源站）
+func GetSourceLinkHealth(db *gorm.DB, sourceKey string) ([]SourceLinkHealth, error) {
+	var rows []struct {
+		SourceKey   string
+		Checked     int64
+		OK          int64
+		Dead        int64
+		Timeout     int64
+		AvgLatency  float64
+		LastChecked int64
+	}
+	query := db.Model(&models.PlayCheck{}).
+		Select(`source_key, COUNT(*) AS checked,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS ok,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS dead,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS timeout,
+			COALESCE(AVG(CASE WHEN status = ? THEN latency_ms END), 0) AS avg_latency,
+			CAST(strftime('%s', MAX(checked_at)) AS INTEGER) AS last_checked`,
+			PlayCheckOK, PlayCheckDead, PlayCheckTimeout, PlayCheckOK).
+		Group("source_key").
+		Order("source_key ASC")
+	if sourceKey != "" {
+		query = query.Where("source_key = ?", sourceKey)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	list := make([]SourceLinkHealth, 0, len(rows))
+	for _, row := range rows {
+		health := SourceLinkHealth{
+			SourceKey:    row.SourceKey,
+			Checked:      row.Checked,
+			OK:           row.OK,
+			Dead:         row.Dead,
+			Timeout:      row.Timeout,
+			AvgLatencyMs: int64(row.AvgLatency),
+		}
+		if row.Checked > 0 {
+			health.OKRate = float64(row.OK) / float64(row.Checked)
+		}
+		if row.LastChecked > 0 {
+			t := time.Unix(row.LastChecked, 0)
+			health.LastChecked = &t
+		}
+		list = append(list, health)
+	}
+	return list, nil
+}
+
+// SourceQualities 各资源站配置的默认画质（未识别到分辨率时使用）
+func SourceQualities(db *gorm.DB) map[string]string {
+	var sources []models.Source
+	db.Select("key, quality").Where("quality <> ''").Find(&sources)
+	qualities := make(map[string]string, len(sources))
+	for _, source := range sources {
+		qualities[source.Key] = source.Quality
+	}
+	return qualities
+}
+
+// PlayQuality 播放源的画质：优先使用识别结果，其次使用资源站配置，都没有时为"标准"
+func PlayQuality(health *PlayLinkHealth, sourceQuality string) (quality string, detected bool) {
+	if health != nil && health.Quality != "" {
+		return health.Quality, true
+	}
+	if sourceQuality != "" {
+		return sourceQuality, false
+	}
+	return "标准", false
+}
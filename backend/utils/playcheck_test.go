@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"vodcms/models"
+)
+
+func TestParseM3U8(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		variants []M3U8Variant
+		segments []string
+		ok       bool
+	}{
+		{
+			name: "主播放列表",
+			body: "\ufeff#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nlow/index.m3u8\n" +
+				"#EXT-X-STREAM-INF:CODECS=\"avc1.64001f,mp4a.40.2\",RESOLUTION=1920X1080,BANDWIDTH=5000000\nhigh/index.m3u8\n",
+			variants: []M3U8Variant{
+				{URI: "low/index.m3u8", Width: 640, Height: 360, Bandwidth: 800000},
+				{URI: "high/index.m3u8", Width: 1920, Height: 1080, Bandwidth: 5000000},
+			},
+			ok: true,
+		},
+		{
+			name:     "媒体播放列表",
+			body:     "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\n0.ts\n\n#EXTINF:10,\n1.ts\n#EXT-X-ENDLIST\n",
+			segments: []string{"0.ts", "1.ts"},
+			ok:       true,
+		},
+		{name: "不是m3u8", body: "<html></html>"},
+		{name: "空文件", body: ""},
+	}
+	for _, tt := range tests {
+		variants, segments, err := parseM3U8(tt.body)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(variants, tt.variants) || !reflect.DeepEqual(segments, tt.segments) {
+			t.Errorf("%s: 得到 %+v %v，期望 %+v %v", tt.name, variants, segments, tt.variants, tt.segments)
+		}
+	}
+}
+
+func TestBestM3U8Variant(t *testing.T) {
+	best := bestM3U8Variant([]M3U8Variant{
+		{URI: "a", Width: 1280, Height: 720, Bandwidth: 3000000},
+		{URI: "b", Width: 1920, Height: 1080, Bandwidth: 4000000},
+		{URI: "c", Width: 1920, Height: 1080, Bandwidth: 6000000},
+		{URI: "d", Bandwidth: 9000000}, // 未标注分辨率
+	})
+	if best.URI != "c" {
+		t.Errorf("应选择分辨率最高且码率最高的子码率，得到 %s", best.URI)
+	}
+}
+
+func TestQualityLabel(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          string
+	}{
+		{3840, 2160, "4K"},
+		{2560, 1440, "2K"},
+		{1920, 1080, "1080P"},
+		{1920, 800, "1080P"}, // 宽屏影片按宽度计算
+		{1280, 720, "720P"},
+		{854, 480, "480P"},
+		{640, 360, "360P"},
+		{0, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := QualityLabel(tt.width, tt.height); got != tt.want {
+			t.Errorf("QualityLabel(%d, %d) = %q，期望 %q", tt.width, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestPlayQuality(t *testing.T) {
+	if q, detected := PlayQuality(&PlayLinkHealth{Quality: "1080P"}, "720P"); q != "1080P" || !detected {
+		t.Errorf("应优先使用识别结果，得到 %s/%v", q, detected)
+	}
+	if q, detected := PlayQuality(&PlayLinkHealth{}, "720P"); q != "720P" || detected {
+		t.Errorf("未识别时应使用资源站画质，得到 %s/%v", q, detected)
+	}
+	if q, _ := PlayQuality(nil, ""); q != "标准" {
+		t.Errorf("都没有时应为标准，得到 %s", q)
+	}
+}
+
+// TestCheckPlayURLRejectsPrivate 播放地址检测不能访问内网地址
+func TestCheckPlayURLRejectsPrivate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#EXTM3U\n#EXTINF:10,\n0.ts\n"))
+	}))
+	defer server.Close()
+
+	for _, rawURL := range []string{server.URL + "/index.m3u8", server.URL + "/movie.mp4"} {
+		result := CheckPlayURL(rawURL)
+		if result.Status != PlayCheckDead || !strings.Contains(result.Error, "内网") {
+			t.Errorf("%s: 应拒绝访问内网地址，得到 %+v", rawURL, result)
+		}
+	}
+	if result := CheckPlayURL("ftp://example.com/a.mp4"); result.Status != PlayCheckDead {
+		t.Errorf("非 http 地址应判定为失效，得到 %+v", result)
+	}
+}
+
+// TestCheckVideoPlayGroupsRemovesStale 不再检测的播放组（已删除或改为网页地址）的旧结果应被删除
+func TestCheckVideoPlayGroupsRemovesStale(t *testing.T) {
+	db := newTestDB(t)
+
+	video := models.Video{VodID: 1, SourceKey: "a", VodPlayFrom: "web", VodPlayURL: "第1集$https://example.com/play/1.html"}
+	if err := db.Create(&video).Error; err != nil {
+		t.Fatalf("创建视频失败: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		db.Create(&models.PlayCheck{VideoID: video.ID, GroupIndex: i, SourceKey: "a", Status: PlayCheckOK, CheckedAt: time.Now()})
+	}
+	db.Create(&models.PlayCheck{VideoID: video.ID + 100, GroupIndex: 0, SourceKey: "a", Status: PlayCheckOK, CheckedAt: time.Now()})
+
+	checks, err := CheckVideoPlayGroups(db, &video)
+	if err != nil || len(checks) != 0 {
+		t.Fatalf("网页地址不应检测: %+v（%v）", checks, err)
+	}
+	var count int64
+	db.Model(&models.PlayCheck{}).Where("video_id = ?", video.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("应删除该视频的旧结果，剩余 %d 条", count)
+	}
+	db.Model(&models.PlayCheck{}).Count(&count)
+	if count != 1 {
+		t.Errorf("不应删除其他视频的结果，剩余 %d 条", count)
+	}
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// 播放源排序
+// 同一视频在多个资源站的记录按综合得分从高到低排列，播放器可直接选第一个，失败时依次切换
+// 每项得分为 0-100，总分为加权平均：
+// 1. 资源站优先级（管理员配置，0-100）
+// 2. 画质（播放列表识别到的分辨率，未识别时为中间值）
+// 3. 可用性（播放地址检测结果，正常时按耗时扣分）
+// 4. 剧集完整度（与剧集最多的资源站相比）
+// 5. 新鲜度（与最近采集的资源站相比，每落后一天扣10分）
+
+// 各项权重（合计100）
+const (
+	playRankWeightPriority  = 25
+	playRankWeightQuality   = 20
+	playRankWeightHealth    = 30
+	playRankWeightEpisodes  = 15
+	playRankWeightFreshness = 10
+)
+
+// PlaySourceScore 播放源得分明细
+type PlaySourceScore struct {
+	Total     float64 `json:"total"`
+	Priority  float64 `json:"priority"`
+	Quality   float64 `json:"quality"`
+	Health    float64 `json:"health"`
+	Episodes  float64 `json:"episodes"`
+	Freshness float64 `json:"freshness"`
+}
+
+// RankedPlaySource 排序后的播放源
+type RankedPlaySource struct {
+	Video    *models.Video
+	Health   *PlayLinkHealth
+	Episodes int
+	Score    PlaySourceScore
+}
+
+// SourcePriorities 各资源站的优先级
+func SourcePriorities(db *gorm.DB) map[string]int {
+	var sources []models.Source
+	db.Select("key, priority").Find(&sources)
+	priorities := make(map[string]int, len(sources))
+	for _, source := range sources {
+		priorities[source.Key] = source.Priority
+	}
+	return priorities
+}
+
+// RankPlaySources 计算得分并按总分从高到低排序（总分相同时采集时间新的在前）
+func RankPlaySources(videos []models.Video, health map[uint]*PlayLinkHealth, priorities map[string]int) []RankedPlaySource {
+	ranked := make([]RankedPlaySource, 0, len(videos))
+	maxEpisodes := 0
+	for i := range videos {
+		item := RankedPlaySource{
+			Video:    &videos[i],
+			Health:   health[videos[i].ID],
+			Episodes: VideoEpisodeCount(&videos[i]),
+		}
+		if item.Episodes > maxEpisodes {
+			maxEpisodes = item.Episodes
+		}
+		ranked = append(ranked, item)
+	}
+	if len(ranked) == 0 {
+		return ranked
+	}
+
+	newest := ranked[0].Video.CollectedAt
+	for _, item := range ranked {
+		if item.Video.CollectedAt.After(newest) {
+			newest = item.Video.CollectedAt
+		}
+	}
+
+	for i := range ranked {
+		item := &ranked[i]
+		score := &item.Score
+		score.Priority = clampScore(float64(priorities[item.Video.SourceKey]))
+		score.Quality = playQualityScore(item.Health)
+		score.Health = playHealthScore(item.Health)
+		if maxEpisodes > 0 {
+			score.Episodes = float64(item.Episodes) * 100 / float64(maxEpisodes)
+		}
+		daysBehind := newest.Sub(item.Video.CollectedAt).Hours() / 24
+		score.Freshness = clampScore(100 - daysBehind*10)
+
+		score.Total = (score.Priority*playRankWeightPriority +
+			score.Quality*playRankWeightQuality +
+			score.Health*playRankWeightHealth +
+			score.Episodes*playRankWeightEpisodes +
+			score.Freshness*playRankWeightFreshness) / 100
+		roundScore(score)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score.Total != ranked[j].Score.Total {
+			return ranked[i].Score.Total > ranked[j].Score.Total
+		}
+		return ranked[i].Video.CollectedAt.After(ranked[j].Video.CollectedAt)
+	})
+	return ranked
+}
+
+// playQualityScore 按识别到的分辨率打分，未识别时为50
+func playQualityScore(health *PlayLinkHealth) float64 {
+	if health == nil || health.Quality == "" {
+		return 50
+	}
+	switch health.Quality {
+	case "4K":
+		return 100
+	case "2K":
+		return 95
+	case "1080P":
+		return 90
+	case "720P":
+		return 70
+	case "480P":
+		return 40
+	}
+	return 20
+}
+
+// playHealthScore 按检测结果打分：全部正常100（耗时每秒扣10分，最多扣30分），部分正常60，未检测50，全部失效0
+func playHealthScore(health *PlayLinkHealth) float64 {
+	if health == nil {
+		return 50
+	}
+	switch health.Status {
+	case "ok":
+		penalty := float64(health.LatencyMs) / 100
+		if penalty > 30 {
+			penalty = 30
+		}
+		return 100 - penalty
+	case "partial":
+		return 60
+	case "dead":
+		return 0
+	}
+	return 50
+}
+
+func clampScore(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 100 {
+		return 100
+	}
+	return v
+}
+
+// roundScore 保留一位小数，便于展示
+func roundScore(score *PlaySourceScore) {
+	for _, v := range []*float64{&score.Total, &score.Priority, &score.Quality, &score.Health, &score.Episodes, &score.Freshness} {
+		*v = float64(int64(*v*10+0.5)) / 10
+	}
+}
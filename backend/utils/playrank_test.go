@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"vodcms/models"
+)
+
+// rankVideo 构造排序用的视频记录（episodes 为剧集数，age 为距最近采集的时间）
+func rankVideo(id uint, sourceKey string, episodes int, age time.Duration) models.Video {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	urls := make([]string, episodes)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("第%d集$https://%s.example.com/%d.m3u8", i+1, sourceKey, i+1)
+	}
+	return models.Video{
+		ID:          id,
+		VodID:       1,
+		SourceKey:   sourceKey,
+		VodPlayFrom: "m3u8",
+		VodPlayURL:  strings.Join(urls, "#"),
+		CollectedAt: now.Add(-age),
+	}
+}
+
+func TestRankPlaySources(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name       string
+		videos     []models.Video
+		health     map[uint]*PlayLinkHealth
+		priorities map[string]int
+		wantOrder  []string
+		wantTotal  []float64
+	}{
+		{
+			name:      "可用性优先于采集顺序",
+			videos:    []models.Video{rankVideo(1, "a", 10, 0), rankVideo(2, "b", 10, 0)},
+			health:    map[uint]*PlayLinkHealth{1: {Status: "dead"}, 2: {Status: "ok"}},
+			wantOrder: []string{"b", "a"},
+			wantTotal: []float64{65, 35},
+		},
+		{
+			name:      "剧集更完整的在前",
+			videos:    []models.Video{rankVideo(1, "a", 5, 0), rankVideo(2, "b", 10, 0)},
+			wantOrder: []string{"b", "a"},
+			wantTotal: []float64{50, 42.5},
+		},
+		{
+			name:      "每落后一天扣10分新鲜度",
+			videos:    []models.Video{rankVideo(1, "a", 10, 2*day), rankVideo(2, "b", 10, 0)},
+			wantOrder: []string{"b", "a"},
+			wantTotal: []float64{50, 48},
+		},
+		{
+			name:      "总分相同时采集时间新的在前",
+			videos:    []models.Video{rankVideo(1, "a", 10, time.Minute), rankVideo(2, "b", 10, 0)},
+			wantOrder: []string{"b", "a"},
+			wantTotal: []float64{50, 50},
+		},
+		{
+			name:   "画质高但较慢的资源站",
+			videos: []models.Video{rankVideo(1, "a", 10, 0), rankVideo(2, "b", 10, 0)},
+			health: map[uint]*PlayLinkHealth{
+				1: {Status: "ok", LatencyMs: 500, Quality: "1080P"},
+				2: {Status: "ok", LatencyMs: 0, Quality: "720P"},
+			},
+			wantOrder: []string{"a", "b"},
+			wantTotal: []float64{71.5, 69},
+		},
+		{
+			name:       "优先级限制在 0-100",
+			videos:     []models.Video{rankVideo(1, "a", 10, 0), rankVideo(2, "b", 10, 0)},
+			priorities: map[string]int{"b": 150, "a": -10},
+			wantOrder:  []string{"b", "a"},
+			wantTotal:  []float64{75, 50},
+		},
+		{
+			name:      "没有记录",
+			wantOrder: []string{},
+			wantTotal: []float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := RankPlaySources(tt.videos, tt.health, tt.priorities)
+			order := make([]string, 0, len(ranked))
+			totals := make([]float64, 0, len(ranked))
+			for _, item := range ranked {
+				order = append(order, item.Video.SourceKey)
+				totals = append(totals, item.Score.Total)
+			}
+			if fmt.Sprint(order) != fmt.Sprint(tt.wantOrder) || fmt.Sprint(totals) != fmt.Sprint(tt.wantTotal) {
+				t.Errorf("排序 %v 总分 %v，期望 %v %v", order, totals, tt.wantOrder, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestSourcePriorities(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]models.Source{
+		{Name: "A", Key: "a", BaseURL: "https://a.example.com", Priority: 80},
+		{Name: "B", Key: "b", BaseURL: "https://b.example.com"},
+	})
+	priorities := SourcePriorities(db)
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"a", 80},
+		{"b", 0},
+		{"missing", 0},
+	}
+	for _, tt := range tests {
+		if got := priorities[tt.key]; got != tt.want {
+			t.Errorf("%s: priority = %d，期望 %d", tt.key, got, tt.want)
+		}
+	}
+}
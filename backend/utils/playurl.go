@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"vodcms/models"
+)
+
+// 苹果CMS播放地址格式：
+// 1. vod_play_from 为播放组名称，多组用 $$$ 分隔，例如 "m3u8$$$wjm3u8"
+// 2. vod_play_url 为每组的剧集列表，同样用 $$$ 分隔，组内剧集用 # 分隔，剧集为 "名称$地址"
+// 3. vod_play_server / vod_play_note 与播放组一一对应，用 $$$ 分隔
+const (
+	PlayGroupSeparator = "$$$"
+	EpisodeSeparator   = "#"
+	EpisodeURLSep      = "$"
+)
+
+// Episode 单集
+type Episode struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// PlayGroup 播放组（一个播放器/线路的剧集列表）
+type PlayGroup struct {
+	From      string    `json:"from"`
+	Server    string    `json:"server"`
+	Note      string    `json:"note"`
+	Episodes  []Episode `json:"episodes"`
+	SourceKey string    `json:"source_key"` // 所属资源站
+}
+
+// ParsePlayGroups 解析苹果CMS格式的播放地址
+func ParsePlayGroups(from, server, note, urls string) []PlayGroup {
+	if strings.TrimSpace(urls) == "" {
+		return nil
+	}
+	froms := strings.Split(from, PlayGroupSeparator)
+	servers := strings.Split(server, PlayGroupSeparator)
+	notes := strings.Split(note, PlayGroupSeparator)
+
+	var groups []PlayGroup
+	for i, groupURL := range strings.Split(urls, PlayGroupSeparator) {
+		group := PlayGroup{
+			From:   pickPart(froms, i),
+			Server: pickPart(servers, i),
+			Note:   pickPart(notes, i),
+		}
+		for j, item := range strings.Split(groupURL, EpisodeSeparator) {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			episode := Episode{URL: item}
+			if k := strings.Index(item, EpisodeURLSep); k >= 0 {
+				episode.Name = item[:k]
+				episode.URL = item[k+len(EpisodeURLSep):]
+			}
+			if episode.Name == "" {
+				episode.Name = "第" + strconv.Itoa(j+1) + "集"
+			}
+			if episode.URL != "" {
+				group.Episodes = append(group.Episodes, episode)
+			}
+		}
+		if len(group.Episodes) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// VideoPlayGroups 解析视频的播放组
+func VideoPlayGroups(video *models.Video) []PlayGroup {
+	groups := ParsePlayGroups(video.VodPlayFrom, video.VodPlayServer, video.VodPlayNote, video.VodPlayURL)
+	for i := range groups {
+		groups[i].SourceKey = video.SourceKey
+	}
+	return groups
+}
+
+// VideoDownGroups 解析视频的下载组
+func VideoDownGroups(video *models.Video) []PlayGroup {
+	groups := ParsePlayGroups(video.VodDownFrom, video.VodDownServer, video.VodDownNote, video.VodDownURL)
+	for i := range groups {
+		groups[i].SourceKey = video.SourceKey
+	}
+	return groups
+}
+
+// MergePlayGroups 合并多个资源站的播放组
+// 播放组名称对应下游的播放器编码，因此不改名：同名播放组只保留剧集最多的一组（数量相同时保留靠前的）
+// groups 应按资源站优先级排列
+func MergePlayGroups(groups []PlayGroup) []PlayGroup {
+	var merged []PlayGroup
+	index := make(map[string]int)
+	for _, group := range groups {
+		if group.From == "" {
+			group.From = group.SourceKey
+		}
+		if i, ok := index[group.From]; ok {
+			if len(group.Episodes) > len(merged[i].Episodes) {
+				merged[i] = group
+			}
+			continue
+		}
+		index[group.From] = len(merged)
+		merged = append(merged, group)
+	}
+	return merged
+}
+
+// FormatPlayGroups 把播放组还原为苹果CMS格式（from, server, note, url）
+func FormatPlayGroups(groups []PlayGroup) (from, server, note, urls string) {
+	froms := make([]string, 0, len(groups))
+	servers := make([]string, 0, len(groups))
+	notes := make([]string, 0, len(groups))
+	groupURLs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		froms = append(froms, group.From)
+		servers = append(servers, group.Server)
+		notes = append(notes, group.Note)
+		groupURLs = append(groupURLs, FormatEpisodes(group.Episodes))
+	}
+	return strings.Join(froms, PlayGroupSeparator),
+		joinOptionalParts(servers),
+		joinOptionalParts(notes),
+		strings.Join(groupURLs, PlayGroupSeparator)
+}
+
+// joinOptionalParts 拼接可选字段，全部为空时返回空字符串
+func joinOptionalParts(parts []string) string {
+	for _, part := range parts {
+		if part != "" {
+			return strings.Join(parts, PlayGroupSeparator)
+		}
+	}
+	return ""
+}
+
+// FormatEpisodes 把剧集列表还原为 "名称$地址#名称$地址" 格式
+func FormatEpisodes(episodes []Episode) string {
+	items := make([]string, 0, len(episodes))
+	for _, episode := range episodes {
+		items = append(items, episode.Name+EpisodeURLSep+episode.URL)
+	}
+	return strings.Join(items, EpisodeSeparator)
+}
+
+// IsDirectMediaURL 判断是否为可直接播放的媒体地址（而非需要解析的网页地址）
+func IsDirectMediaURL(rawURL string) bool {
+	path := strings.ToLower(rawURL)
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	for _, ext := range []string{".m3u8", ".mp4", ".flv", ".mkv", ".ts", ".mpd"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func pickPart(parts []string, i int) string {
+	if i < len(parts) {
+		return strings.TrimSpace(parts[i])
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlayGroups(t *testing.T) {
+	groups := ParsePlayGroups(
+		"m3u8$$$wjm3u8",
+		"$$$server2",
+		"",
+		"第01集$https://a.com/1.m3u8#第02集$https://a.com/2.m3u8$$$https://b.com/1.m3u8##花絮$#正片$https://b.com/2.m3u8",
+	)
+	want := []PlayGroup{
+		{From: "m3u8", Episodes: []Episode{
+			{Name: "第01集", URL: "https://a.com/1.m3u8"},
+			{Name: "第02集", URL: "https://a.com/2.m3u8"},
+		}},
+		{From: "wjm3u8", Server: "server2", Episodes: []Episode{
+			{Name: "第1集", URL: "https://b.com/1.m3u8"},
+			{Name: "正片", URL: "https://b.com/2.m3u8"},
+		}},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("解析结果 %+v，期望 %+v", groups, want)
+	}
+
+	if groups := ParsePlayGroups("m3u8", "", "", "  "); groups != nil {
+		t.Errorf("空地址应返回 nil，得到 %+v", groups)
+	}
+	// 没有剧集的播放组被丢弃，播放组名称缺失时为空
+	groups = ParsePlayGroups("m3u8", "", "", "$$$$https://c.com/1.mp4")
+	if len(groups) != 1 || groups[0].From != "" || groups[0].Episodes[0].Name != "第1集" {
+		t.Errorf("解析结果 %+v", groups)
+	}
+}
+
+func TestMergePlayGroups(t *testing.T) {
+	eps := func(n int) []Episode {
+		episodes := make([]Episode, n)
+		for i := range episodes {
+			episodes[i] = Episode{Name: "第" + string(rune('1'+i)) + "集", URL: "u"}
+		}
+		return episodes
+	}
+	merged := MergePlayGroups([]PlayGroup{
+		{From: "m3u8", SourceKey: "a", Episodes: eps(2)},
+		{From: "wjm3u8", SourceKey: "a", Episodes: eps(3)},
+		{From: "m3u8", SourceKey: "b", Episodes: eps(3)},   // 剧集更多，替换 a 的 m3u8
+		{From: "wjm3u8", SourceKey: "b", Episodes: eps(3)}, // 数量相同，保留靠前的 a
+		{From: "", SourceKey: "c", Episodes: eps(1)},       // 没有名称时使用资源站
+	})
+
+	want := []struct {
+		from, source string
+		episodes     int
+	}{
+		{"m3u8", "b", 3},
+		{"wjm3u8", "a", 3},
+		{"c", "c", 1},
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("合并后 %d 组，期望 %d 组: %+v", len(merged), len(want), merged)
+	}
+	for i, w := range want {
+		g := merged[i]
+		if g.From != w.from || g.SourceKey != w.source || len(g.Episodes) != w.episodes {
+			t.Errorf("第 %d 组为 %s/%s/%d，期望 %s/%s/%d", i, g.From, g.SourceKey, len(g.Episodes), w.from, w.source, w.episodes)
+		}
+	}
+}
+
+func TestFormatPlayGroupsRoundTrip(t *testing.T) {
+	from, server, note, urls := "m3u8$$$mp4", "", "", "第1集$https://a.com/1.m3u8#第2集$https://a.com/2.m3u8$$$正片$https://a.com/1.mp4"
+	gotFrom, gotServer, gotNote, gotURLs := FormatPlayGroups(ParsePlayGroups(from, server, note, urls))
+	if gotFrom != from || gotServer != server || gotNote != note || gotURLs != urls {
+		t.Errorf("还原结果 %q %q %q %q", gotFrom, gotServer, gotNote, gotURLs)
+	}
+}
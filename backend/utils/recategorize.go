@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// 重新分类
+// 映射规则变化后，按当前规则重新计算已入库视频的标准分类
+// 1. 范围：全部视频、某个资源站，或某个资源站的某个分类
+// 2. 预览（dry_run）只统计分类变化，不修改数据
+// 3. 分批读取和更新，每批一个事务，分类变化记录到视频历史（action=recategorize）
+// 4. 锁定了标准分类的视频不会被修改
+
+const recategorizeBatchSize = 500
+
+// RecategorizeOptions 重新分类参数
+type RecategorizeOptions struct {
+	SourceKey    string `json:"source_key"`     // 为空表示全部资源站
+	SourceTypeID int    `json:"source_type_id"` // 为0表示该资源站的全部分类（需同时指定 source_key）
+	DryRun       bool   `json:"dry_run"`
+}
+
+// RecategorizeMove 一组分类变化
+type RecategorizeMove struct {
+	FromID      int    `json:"from_id"`
+	FromSubID   *int   `json:"from_sub_id"`
+	FromName    string `json:"from_name"`
+	ToID        int    `json:"to_id"`
+	ToSubID     *int   `json:"to_sub_id"`
+	ToName      string `json:"to_name"`
+	Count       int    `json:"count"`
+	Description string `json:"description"` // 如 "12 个视频从 其他 移到 电影/动作片"
+}
+
+// RecategorizeResult 重新分类结果
+type RecategorizeResult struct {
+	Scanned int                `json:"scanned"`
+	Changed int                `json:"changed"`
+	Locked  int                `json:"locked"` // 因锁定分类而跳过的视频数
+	Moves   []RecategorizeMove `json:"moves"`
+}
+
+// RecategorizeJob 重新分类任务状态（同一时间只运行一个）
+type RecategorizeJob struct {
+	Status     string               `json:"status"`  // idle, running, success, failed
+	Trigger    string               `json:"trigger"` // manual, rule_change
+	Options    RecategorizeOptions  `json:"options"`
+	Result     *RecategorizeResult  `json:"result,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	Pending    *RecategorizeOptions `json:"pending,omitempty"` // 运行期间规则再次变化时，结束后补跑
+	StartedAt  *time.Time           `json:"started_at,omitempty"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+}
+
+var (
+	recategorizeJobMu sync.Mutex
+	recategorizeJob   = RecategorizeJob{Status: "idle"}
+)
+
+// GetRecategorizeJob 获取重新分类任务状态
+func GetRecategorizeJob() RecategorizeJob {
+	recategorizeJobMu.Lock()
+	defer recategorizeJobMu.Unlock()
+	return recategorizeJob
+}
+
+// StartRecategorizeJob 在后台启动重新分类任务，已有任务运行时返回 false
+func StartRecategorizeJob(db *gorm.DB, opts RecategorizeOptions, trigger string) (RecategorizeJob, bool) {
+	opts.DryRun = false
+	recategorizeJobMu.Lock()
+	defer recategorizeJobMu.Unlock()
+	if recategorizeJob.Status == "running" {
+		return recategorizeJob, false
+	}
+	startRecategorizeLocked(db, opts, trigger)
+	return recategorizeJob, true
+}
+
+// TriggerRecategorize 映射规则变化后触发重新分类
+// 已有任务运行时合并到待补跑的范围，当前任务结束后自动执行
+func TriggerRecategorize(db *gorm.DB, opts RecategorizeOptions) {
+	opts.DryRun = false
+	recategorizeJobMu.Lock()
+	defer recategorizeJobMu.Unlock()
+	if recategorizeJob.Status == "running" {
+		if recategorizeJob.Pending == nil {
+			recategorizeJob.Pending = &opts
+		} else {
+			merged := MergeRecategorizeScope(*recategorizeJob.Pending, opts)
+			recategorizeJob.Pending = &merged
+		}
+		return
+	}
+	startRecategorizeLocked(db, opts, "rule_change")
+}
+
+// MergeRecategorizeScope 合并两个范围（取能同时覆盖两者的最小范围）
+func MergeRecategorizeScope(a, b RecategorizeOptions) RecategorizeOptions {
+	if a.SourceKey != b.SourceKey || a.SourceKey == "" {
+		return RecategorizeOptions{}
+	}
+	if a.SourceTypeID != b.SourceTypeID {
+		return RecategorizeOptions{SourceKey: a.SourceKey}
+	}
+	return a
+}
+
+func startRecategorizeLocked(db *gorm.DB, opts RecategorizeOptions, trigger string) {
+	now := time.Now()
+	recategorizeJob = RecategorizeJob{Status: "running", Trigger: trigger, Options: opts, StartedAt: &now}
+	fmt.Printf("🔄 开始重新分类: %s\n", describeRecategorizeScope(opts))
+
+	go func() {
+		result, err := RunRecategorize(db, opts)
+
+		recategorizeJobMu.Lock()
+		defer recategorizeJobMu.Unlock()
+		finished := time.Now()
+		recategorizeJob.FinishedAt = &finished
+		recategorizeJob.Result = result
+		if err != nil {
+			recategorizeJob.Status = "failed"
+			recategorizeJob.Error = err.Error()
+			fmt.Printf("❌ 重新分类失败: %v\n", err)
+		} else {
+			recategorizeJob.Status = "success"
+			fmt.Printf("✅ 重新分类完成: 检查 %d 个视频，调整 %d 个\n", result.Scanned, result.Changed)
+		}
+
+		if pending := recategorizeJob.Pending; pending != nil {
+			startRecategorizeLocked(db, *pending, "rule_change")
+		}
+	}()
+}
+
+// RunRecategorize 按当前映射规则重新计算视频分类（DryRun 时只统计不修改）
+func RunRecategorize(db *gorm.DB, opts RecategorizeOptions) (*RecategorizeResult, error) {
+	if opts.SourceTypeID != 0 && opts.SourceKey == "" {
+		return nil, fmt.Errorf("指定 source_type_id 时必须同时指定 source_key")
+	}
+
+	mapper := NewCategoryMapper(db)
+	moves := make(map[string]*RecategorizeMove)
+	result := &RecategorizeResult{Moves: []RecategorizeMove{}}
+
+	query := db.Model(&models.Video{}).Select("id, vod_id, vod_name, vod_class, vod_area, vod_blurb, vod_content, source_key, type_id, type_name, vod_lock_fields, " +
+		"standard_category_id, standard_category_name, standard_sub_category_id, standard_sub_category_name, standard_sub_category_confidence")
+	if opts.SourceKey != "" {
+		query = query.Where("source_key = ?", opts.SourceKey)
+	}
+	if opts.SourceTypeID != 0 {
+		query = query.Where("type_id = ?", opts.SourceTypeID)
+	}
+
+	type update struct {
+		video   models.Video
+		changes map[string]FieldChange
+	}
+
+	var batch []models.Video
+	err := query.FindInBatches(&batch, recategorizeBatchSize, func(_ *gorm.DB, _ int) error {
+		var updates []update
+		for _, video := range batch {
+			result.Scanned++
+			target := mapper.Map(VideoCategoryInput(&video))
+
+			changes := map[string]FieldChange{}
+			setCategoryChange(changes, "standard_category_id", video.StandardCategoryID, target.StandardID)
+			setCategoryChange(changes, "standard_category_name", video.StandardCategoryName, target.StandardName)
+			setCategoryChange(changes, "standard_sub_category_id", video.StandardSubCategoryID, target.StandardSubID)
+			setCategoryChange(changes, "standard_sub_category_name", video.StandardSubCategoryName, target.StandardSubName)
+			if len(changes) == 0 && video.StandardSubCategoryConfidence == target.SubConfidence {
+				continue
+			}
+			if isCategoryLocked(&video) {
+				if len(changes) > 0 {
+					result.Locked++
+				}
+				continue
+			}
+			if len(changes) == 0 {
+				// 只有子分类置信度变化：直接更新，不算分类变化也不记录历史
+				video.StandardSubCategoryConfidence = target.SubConfidence
+				updates = append(updates, update{video: video})
+				continue
+			}
+
+			moveKey := fmt.Sprintf("%d:%s>%d:%s", video.StandardCategoryID, subIDKey(video.StandardSubCategoryID), target.StandardID, subIDKey(target.StandardSubID))
+			move, ok := moves[moveKey]
+			if !ok {
+				move = &RecategorizeMove{
+					FromID:    video.StandardCategoryID,
+					FromSubID: video.StandardSubCategoryID,
+					FromName:  categoryLabel(video.StandardCategoryName, video.StandardSubCategoryName),
+					ToID:      target.StandardID,
+					ToSubID:   target.StandardSubID,
+					ToName:    categoryLabel(target.StandardName, target.StandardSubName),
+				}
+				moves[moveKey] = move
+			}
+			move.Count++
+			result.Changed++
+
+			video.StandardCategoryID = target.StandardID
+			video.StandardSubCategoryID = target.StandardSubID
+			video.StandardCategoryName = target.StandardName
+			video.StandardSubCategoryName = target.StandardSubName
+			video.StandardSubCategoryConfidence = target.SubConfidence
+			updates = append(updates, update{video: video, changes: changes})
+		}
+		if opts.DryRun || len(updates) == 0 {
+			return nil
+		}
+
+		return db.Transaction(func(tx *gorm.DB) error {
+			for i := range updates {
+				video := &updates[i].video
+				if err := tx.Model(&models.Video{}).Where("id = ?", video.ID).Updates(map[string]interface{}{
+					"standard_category_id":             video.StandardCategoryID,
+					"standard_category_name":           video.StandardCategoryName,
+					"standard_sub_category_id":         video.StandardSubCategoryID,
+					"standard_sub_category_name":       video.StandardSubCategoryName,
+					"standard_sub_category_confidence": video.StandardSubCategoryConfidence,
+				}).Error; err != nil {
+					return fmt.Errorf("更新视频 %d 失败: %w", video.ID, err)
+				}
+				if err := RecordVideoHistory(tx, video, updates[i].changes, "recategorize", 0); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}).Error
+	if err != nil {
+		return result, err
+	}
+
+	for _, move := range moves {
+		move.Description = fmt.Sprintf("%d 个视频从 %s 移到 %s", move.Count, move.FromName, move.ToName)
+		result.Moves = append(result.Moves, *move)
+	}
+	sort.Slice(result.Moves, func(i, j int) bool {
+		if result.Moves[i].Count != result.Moves[j].Count {
+			return result.Moves[i].Count > result.Moves[j].Count
+		}
+		return result.Moves[i].Description < result.Moves[j].Description
+	})
+
+	if !opts.DryRun && result.Changed > 0 {
+		TouchCatalog()
+	}
+	return result, nil
+}
+
+// describeRecategorizeScope 范围描述（用于日志）
+func describeRecategorizeScope(opts RecategorizeOptions) string {
+	switch {
+	case opts.SourceKey == "":
+		return "全部视频"
+	case opts.SourceTypeID == 0:
+		return "资源站 " + opts.SourceKey
+	}
+	return fmt.Sprintf("资源站 %s 分类 %d", opts.SourceKey, opts.SourceTypeID)
+}
+
+// isCategoryLocked 视频是否锁定了标准分类
+func isCategoryLocked(video *models.Video) bool {
+	for _, field := range LockedFields(video) {
+		if field == "standard_category_id" {
+			return true
+		}
+	}
+	return false
+}
+
+func sameSubCategory(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func subIDKey(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprint(*id)
+}
+
+func categoryLabel(name, subName string) string {
+	if subName == "" {
+		return name
+	}
+	return strings.Join([]string{name, subName}, "/")
+}
+
+func setCategoryChange(changes map[string]FieldChange, field string, oldValue, newValue interface{}) {
+	if !reflect.DeepEqual(oldValue, newValue) {
+		changes[field] = FieldChange{Old: oldValue, New: newValue}
+	}
+}
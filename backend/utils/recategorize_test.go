@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestRunRecategorize(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        RecategorizeOptions
+		wantErr     bool
+		wantScanned int
+		wantChanged int
+		wantLocked  int
+		wantMoved   bool // 未锁定的视频是否已移到电视剧
+	}{
+		{"预览只统计不修改", RecategorizeOptions{DryRun: true}, false, 4, 1, 1, false},
+		{"按资源站执行", RecategorizeOptions{SourceKey: "test"}, false, 3, 1, 1, true},
+		{"按资源站分类执行", RecategorizeOptions{SourceKey: "test", SourceTypeID: 1}, false, 3, 1, 1, true},
+		{"范围外的视频不修改", RecategorizeOptions{SourceKey: "other"}, false, 1, 0, 0, false},
+		{"分类ID需要资源站", RecategorizeOptions{SourceTypeID: 1}, true, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			db.Create(&models.MappingRule{SourceKey: "test", SourceTypeID: 1, SourceName: "火星剧", StandardID: 2, MatchType: MatchTypeExact, Priority: 100, IsActive: true})
+			videos := []models.Video{
+				{VodID: 1, VodName: "待调整", SourceKey: "test", TypeID: 1, TypeName: "火星剧", StandardCategoryID: OtherCategoryID, StandardCategoryName: OtherCategoryName},
+				{VodID: 2, VodName: "已锁定", SourceKey: "test", TypeID: 1, TypeName: "火星剧", StandardCategoryID: OtherCategoryID, StandardCategoryName: OtherCategoryName,
+					VodLockFields: "standard_category_id,standard_category_name,standard_sub_category_id,standard_sub_category_name"},
+				{VodID: 3, VodName: "已正确", SourceKey: "test", TypeID: 1, TypeName: "火星剧", StandardCategoryID: 2, StandardCategoryName: "电视剧"},
+				{VodID: 4, VodName: "其他资源站", SourceKey: "other", TypeID: 1, TypeName: "火星分类", StandardCategoryID: OtherCategoryID, StandardCategoryName: OtherCategoryName},
+			}
+			if err := db.Create(&videos).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := RunRecategorize(db, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("期望返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("重新分类失败: %v", err)
+			}
+			if result.Scanned != tt.wantScanned || result.Changed != tt.wantChanged || result.Locked != tt.wantLocked {
+				t.Errorf("scanned=%d changed=%d locked=%d，期望 %d/%d/%d",
+					result.Scanned, result.Changed, result.Locked, tt.wantScanned, tt.wantChanged, tt.wantLocked)
+			}
+			if tt.wantChanged > 0 && (len(result.Moves) != 1 || result.Moves[0].ToID != 2 || result.Moves[0].Description != "1 个视频从 其他 移到 电视剧") {
+				t.Errorf("分类变化 %+v", result.Moves)
+			}
+
+			category := func(id uint) models.Video {
+				var v models.Video
+				db.First(&v, id)
+				return v
+			}
+			wantID := OtherCategoryID
+			if tt.wantMoved {
+				wantID = 2
+			}
+			if v := category(videos[0].ID); v.StandardCategoryID != wantID {
+				t.Errorf("未锁定的视频分类为 %d，期望 %d", v.StandardCategoryID, wantID)
+			}
+			if v := category(videos[1].ID); v.StandardCategoryID != OtherCategoryID {
+				t.Errorf("锁定的视频分类被修改为 %d", v.StandardCategoryID)
+			}
+
+			var histories []models.VideoHistory
+			db.Where("action = ?", "recategorize").Find(&histories)
+			wantHistories := 0
+			if tt.wantMoved {
+				wantHistories = 1
+			}
+			if len(histories) != wantHistories || (wantHistories == 1 && histories[0].VideoID != videos[0].ID) {
+				t.Errorf("视频历史 %+v，期望 %d 条", histories, wantHistories)
+			}
+		})
+	}
+}
+
+func TestMergeRecategorizeScope(t *testing.T) {
+	tests := []struct {
+		a, b RecategorizeOptions
+		want RecategorizeOptions
+	}{
+		{RecategorizeOptions{SourceKey: "a", SourceTypeID: 1}, RecategorizeOptions{SourceKey: "a", SourceTypeID: 1}, RecategorizeOptions{SourceKey: "a", SourceTypeID: 1}},
+		{RecategorizeOptions{SourceKey: "a", SourceTypeID: 1}, RecategorizeOptions{SourceKey: "a", SourceTypeID: 2}, RecategorizeOptions{SourceKey: "a"}},
+		{RecategorizeOptions{SourceKey: "a"}, RecategorizeOptions{SourceKey: "b"}, RecategorizeOptions{}},
+		{RecategorizeOptions{}, RecategorizeOptions{}, RecategorizeOptions{}},
+	}
+	for _, tt := range tests {
+		if got := MergeRecategorizeScope(tt.a, tt.b); got != tt.want {
+			t.Errorf("MergeRecategorizeScope(%+v, %+v) = %+v，期望 %+v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
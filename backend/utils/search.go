@@ -0,0 +1,389 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 全文检索说明：
+// 1. 使用 SQLite FTS5 外部内容表 videos_fts（content='videos'），由触发器在 videos 增删改时自动同步
+// 2. 分词器使用 trigram，中文无需分词即可做子串检索；少于3个字的检索词无法命中三元组，自动改用 LIKE
+// 3. go-sqlite3 需要以 -tags sqlite_fts5 编译才包含 FTS5，不可用时整体回退为 LIKE 检索
+// 4. 片名拼音（全拼、首字母）一并建索引，输入 qyn 或 qingyunian 即可检索到 庆余年
+
+// videoSearchTable FTS5 虚拟表名
+const videoSearchTable = "videos_fts"
+
+// videoSearchColumns 全文索引的列（与 videos 表字段同名）及 bm25 权重
+var videoSearchColumns = []struct {
+	Name   string
+	Weight float64
+}{
+	{"vod_name", 10},
+	{"vod_en", 5},
+	{"vod_actor", 4},
+	{"vod_director", 4},
+	{"vod_class", 2},
+	{"vod_content", 1},
+	{"vod_pinyin", 3},
+	{"vod_pinyin_initials", 3},
+}
+
+// videoLikeColumns 回退为 LIKE 检索时匹配的列（不含简介，避免全表扫描大字段）
+var videoLikeColumns = []string{"vod_name", "vod_en", "vod_actor", "vod_director", "vod_class", "vod_pinyin", "vod_pinyin_initials"}
+
+// searchFieldAliases 检索语法中的字段前缀，例如 actor:刘德华
+var searchFieldAliases = map[string]string{
+	"name":     "vod_name",
+	"title":    "vod_name",
+	"片名":       "vod_name",
+	"en":       "vod_en",
+	"actor":    "vod_actor",
+	"演员":       "vod_actor",
+	"director": "vod_director",
+	"导演":       "vod_director",
+	"class":    "vod_class",
+	"tag":      "vod_class",
+	"类型":       "vod_class",
+	"content":  "vod_content",
+	"简介":       "vod_content",
+	"pinyin":   "vod_pinyin",
+	"py":       "vod_pinyin",
+}
+
+// ftsMinTermLength trigram 分词器能检索的最短字符数
+const ftsMinTermLength = 3
+
+var videoSearchEnabled atomic.Bool
+
+// SearchTerm 检索词
+type SearchTerm struct {
+	Column string // 限定字段，空表示任意字段
+	Value  string
+}
+
+// VideoSearch 关键词检索条件
+type VideoSearch struct {
+	Terms     []SearchTerm
+	MatchExpr string // FTS5 MATCH 表达式，为空表示未使用全文索引
+}
+
+// UsesFTS 是否使用了全文索引（可按 bm25 相关度排序）
+func (s *VideoSearch) UsesFTS() bool {
+	return s.MatchExpr != ""
+}
+
+// Mode 检索方式：fts 或 like
+func (s *VideoSearch) Mode() string {
+	if s.UsesFTS() {
+		return "fts"
+	}
+	return "like"
+}
+
+// videoSearchTriggers 同步全文索引的触发器
+var videoSearchTriggers = []string{"videos_fts_ai", "videos_fts_ad", "videos_fts_au"}
+
+// InitVideoSearch 初始化全文检索表和同步触发器
+// 表结构与当前列定义不一致时会重建索引；FTS5 不可用时删除触发器（否则 videos 的写入会失败）并返回错误，检索自动回退为 LIKE
+// 触发器缺失说明之前的写入没有同步到索引（如曾用不带 FTS5 的程序运行），此时也会重建索引
+func InitVideoSearch(db *gorm.DB) error {
+	videoSearchEnabled.Store(false)
+
+	if err := probeFTS5(db); err != nil {
+		if dropErr := dropVideoSearchTriggers(db); dropErr != nil {
+			return fmt.Errorf("FTS5 不可用（%v），且删除全文索引触发器失败: %w", err, dropErr)
+		}
+		return fmt.Errorf("FTS5 不可用: %w", err)
+	}
+
+	columns := make([]string, 0, len(videoSearchColumns))
+	for _, col := range videoSearchColumns {
+		columns = append(columns, col.Name)
+	}
+	colList := strings.Join(columns, ", ")
+	createSQL := fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, content='videos', content_rowid='id', tokenize='trigram')",
+		videoSearchTable, colList)
+
+	var existingSQL string
+	db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", videoSearchTable).Scan(&existingSQL)
+	var triggerCount int64
+	db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ?", videoSearchTriggers).Scan(&triggerCount)
+
+	rebuild := triggerCount < int64(len(videoSearchTriggers))
+	if existingSQL != createSQL {
+		if existingSQL != "" {
+			if err := db.Exec("DROP TABLE " + videoSearchTable).Error; err != nil {
+				return fmt.Errorf("删除旧全文索引失败: %w", err)
+			}
+		}
+		if err := db.Exec(createSQL).Error; err != nil {
+			return fmt.Errorf("创建全文索引失败: %w", err)
+		}
+		rebuild = true
+	}
+
+	newValues := "new." + strings.Join(columns, ", new.")
+	oldValues := "old." + strings.Join(columns, ", old.")
+	if err := dropVideoSearchTriggers(db); err != nil {
+		return fmt.Errorf("删除全文索引触发器失败: %w", err)
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TRIGGER videos_fts_ai AFTER INSERT ON videos BEGIN
+			INSERT INTO %[1]s(rowid, %[2]s) VALUES (new.id, %[3]s);
+		END`, videoSearchTable, colList, newValues),
+		fmt.Sprintf(`CREATE TRIGGER videos_fts_ad AFTER DELETE ON videos BEGIN
+			INSERT INTO %[1]s(%[1]s, rowid, %[2]s) VALUES ('delete', old.id, %[3]s);
+		END`, videoSearchTable, colList, oldValues),
+		fmt.Sprintf(`CREATE TRIGGER videos_fts_au AFTER UPDATE OF %[2]s ON videos BEGIN
+			INSERT INTO %[1]s(%[1]s, rowid, %[2]s) VALUES ('delete', old.id, %[3]s);
+			INSERT INTO %[1]s(rowid, %[2]s) VALUES (new.id, %[4]s);
+		END`, videoSearchTable, colList, oldValues, newValues),
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("创建全文索引触发器失败: %w", err)
+		}
+	}
+
+	if rebuild {
+		fmt.Println("🔎 正在重建视频全文索引...")
+		if err := db.Exec(fmt.Sprintf("INSERT INTO %[1]s(%[1]s) VALUES ('rebuild')", videoSearchTable)).Error; err != nil {
+			return fmt.Errorf("重建全文索引失败: %w", err)
+		}
+	}
+
+	videoSearchEnabled.Store(true)
+	return nil
+}
+
+// probeFTS5 用临时表检测当前程序是否编译了 FTS5 模块
+func probeFTS5(db *gorm.DB) error {
+	return db.Connection(func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE VIRTUAL TABLE temp.fts5_probe USING fts5(x)").Error; err != nil {
+			return err
+		}
+		return tx.Exec("DROP TABLE temp.fts5_probe").Error
+	})
+}
+
+// dropVideoSearchTriggers 删除全文索引触发器
+func dropVideoSearchTriggers(db *gorm.DB) error {
+	for _, name := range videoSearchTriggers {
+		if err := db.Exec("DROP TRIGGER IF EXISTS " + name).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VideoSearchAvailable 全文检索是否可用
+func VideoSearchAvailable() bool {
+	return videoSearchEnabled.Load()
+}
+
+// ParseVideoSearch 解析检索关键词
+// 支持空格分隔多个词（AND），支持 "带空格的短语" 和字段前缀，例如: actor:刘德华 "无间道"
+func ParseVideoSearch(keyword string) *VideoSearch {
+	search := &VideoSearch{}
+	for _, token := range splitSearchTokens(keyword) {
+		term := SearchTerm{Value: token}
+		if i := strings.IndexAny(token, ":："); i > 0 {
+			prefix := strings.ToLower(token[:i])
+			_, size := utf8.DecodeRuneInString(token[i:])
+			if column, ok := searchFieldAliases[prefix]; ok {
+				term.Column = column
+				term.Value = strings.Trim(token[i+size:], `"`)
+			}
+		}
+		if term.Value != "" {
+			search.Terms = append(search.Terms, term)
+		}
+	}
+
+	if !VideoSearchAvailable() {
+		return search
+	}
+
+	var parts []string
+	for _, term := range search.Terms {
+		if utf8.RuneCountInString(term.Value) < ftsMinTermLength {
+			continue
+		}
+		phrase := `"` + strings.ReplaceAll(term.Value, `"`, `""`) + `"`
+		if term.Column != "" {
+			phrase = term.Column + " : " + phrase
+		}
+		parts = append(parts, phrase)
+	}
+	search.MatchExpr = strings.Join(parts, " AND ")
+	return search
+}
+
+// splitSearchTokens 按空格拆分检索词，双引号内的空格保留
+func splitSearchTokens(keyword string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuote := false
+	for _, r := range strings.TrimSpace(keyword) {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case (r == ' ' || r == '\t' || r == '　') && !inQuote:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// Apply 为视频查询添加检索条件
+// 使用全文索引时会联接 fts 子查询，可用 VideoSearchRankColumn 按相关度排序
+func (s *VideoSearch) Apply(query *gorm.DB) *gorm.DB {
+	if s.UsesFTS() {
+		query = query.Joins(fmt.Sprintf(
+			"JOIN (SELECT rowid AS fts_rowid, bm25(%s, %s) AS fts_rank FROM %s WHERE %s MATCH ?) AS fts ON fts.fts_rowid = videos.id",
+			videoSearchTable, videoSearchWeights(), videoSearchTable, videoSearchTable), s.MatchExpr)
+	}
+
+	for _, term := range s.Terms {
+		if s.UsesFTS() && utf8.RuneCountInString(term.Value) >= ftsMinTermLength {
+			continue // 已由全文索引处理
+		}
+		like := "%" + term.Value + "%"
+		if term.Column != "" {
+			query = query.Where("videos."+term.Column+" LIKE ?", like)
+			continue
+		}
+		columns := videoLikeColumns
+		if s.UsesFTS() {
+			// 全文索引已缩小候选集，短词可以连简介一起匹配
+			columns = append(append([]string(nil), videoLikeColumns...), "vod_content")
+		}
+		conditions := make([]string, 0, len(columns))
+		args := make([]interface{}, 0, len(columns))
+		for _, col := range columns {
+			conditions = append(conditions, "videos."+col+" LIKE ?")
+			args = append(args, like)
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	return query
+}
+
+// VideoSearchRankColumn 相关度排序列（bm25 越小越相关）
+const VideoSearchRankColumn = "fts.fts_rank"
+
+func videoSearchWeights() string {
+	weights := make([]string, 0, len(videoSearchColumns))
+	for _, col := range videoSearchColumns {
+		weights = append(weights, fmt.Sprintf("%.1f", col.Weight))
+	}
+	return strings.Join(weights, ", ")
+}
+
+// Highlights 获取检索结果的高亮片段，返回 视频ID -> 字段 -> 带 <em> 标记的文本
+func (s *VideoSearch) Highlights(db *gorm.DB, ids []uint) map[uint]map[string]string {
+	result := make(map[uint]map[string]string)
+	if len(ids) == 0 || len(s.Terms) == 0 {
+		return result
+	}
+
+	if !s.UsesFTS() {
+		return s.likeHighlights(db, ids)
+	}
+
+	var rows []struct {
+		ID          uint
+		VodName     string
+		VodActor    string
+		VodDirector string
+		VodContent  string
+	}
+	err := db.Raw(fmt.Sprintf(`SELECT rowid AS id,
+			highlight(%[1]s, 0, '<em>', '</em>') AS vod_name,
+			highlight(%[1]s, 2, '<em>', '</em>') AS vod_actor,
+			highlight(%[1]s, 3, '<em>', '</em>') AS vod_director,
+			snippet(%[1]s, 5, '<em>', '</em>', '…', 32) AS vod_content
+		FROM %[1]s WHERE %[1]s MATCH ? AND rowid IN ?`, videoSearchTable), s.MatchExpr, ids).Scan(&rows).Error
+	if err != nil {
+		return result
+	}
+
+	for _, row := range rows {
+		fields := make(map[string]string)
+		for name, text := range map[string]string{
+			"vod_name":     row.VodName,
+			"vod_actor":    row.VodActor,
+			"vod_director": row.VodDirector,
+			"vod_content":  row.VodContent,
+		} {
+			if strings.Contains(text, "<em>") {
+				fields[name] = text
+			}
+		}
+		if len(fields) > 0 {
+			result[row.ID] = fields
+		}
+	}
+	return result
+}
+
+// likeHighlights LIKE 检索时在 Go 中生成简单高亮
+func (s *VideoSearch) likeHighlights(db *gorm.DB, ids []uint) map[uint]map[string]string {
+	result := make(map[uint]map[string]string)
+
+	var rows []struct {
+		ID          uint
+		VodName     string
+		VodActor    string
+		VodDirector string
+	}
+	if err := db.Table("videos").Select("id, vod_name, vod_actor, vod_director").Where("id IN ?", ids).Scan(&rows).Error; err != nil {
+		return result
+	}
+
+	for _, row := range rows {
+		fields := make(map[string]string)
+		for name, text := range map[string]string{
+			"vod_name":     row.VodName,
+			"vod_actor":    row.VodActor,
+			"vod_director": row.VodDirector,
+		} {
+			if marked, ok := s.markTerms(name, text); ok {
+				fields[name] = marked
+			}
+		}
+		if len(fields) > 0 {
+			result[row.ID] = fields
+		}
+	}
+	return result
+}
+
+// markTerms 用 <em> 标记文本中出现的检索词
+func (s *VideoSearch) markTerms(column, text string) (string, bool) {
+	marked := text
+	found := false
+	for _, term := range s.Terms {
+		if term.Column != "" && term.Column != column {
+			continue
+		}
+		if strings.Contains(marked, term.Value) {
+			marked = strings.ReplaceAll(marked, term.Value, "<em>"+term.Value+"</em>")
+			found = true
+		}
+	}
+	return marked, found
+}
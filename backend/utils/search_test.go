@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestParseVideoSearch(t *testing.T) {
+	t.Cleanup(func() { videoSearchEnabled.Store(false) })
+
+	tests := []struct {
+		keyword string
+		terms   []SearchTerm
+		match   string // 启用全文索引时的 MATCH 表达式
+	}{
+		{"庆余年", []SearchTerm{{Value: "庆余年"}}, `"庆余年"`},
+		{"演员:张若昀 庆余年", []SearchTerm{{Column: "vod_actor", Value: "张若昀"}, {Value: "庆余年"}}, `vod_actor : "张若昀" AND "庆余年"`},
+		{`"the office" 美剧`, []SearchTerm{{Value: "the office"}, {Value: "美剧"}}, `"the office"`}, // 少于3个字的词走 LIKE
+		{"actor：刘德华", []SearchTerm{{Column: "vod_actor", Value: "刘德华"}}, `vod_actor : "刘德华"`},
+		{"foo:bar", []SearchTerm{{Value: "foo:bar"}}, `"foo:bar"`}, // 未知前缀按普通词处理
+		{`a"b"c`, []SearchTerm{{Value: "abc"}}, `"abc"`},
+		{"   ", nil, ""},
+	}
+	for _, enabled := range []bool{false, true} {
+		videoSearchEnabled.Store(enabled)
+		for _, tt := range tests {
+			search := ParseVideoSearch(tt.keyword)
+			if !reflect.DeepEqual(search.Terms, tt.terms) {
+				t.Errorf("%q: 检索词为 %+v，期望 %+v", tt.keyword, search.Terms, tt.terms)
+			}
+			want := ""
+			if enabled {
+				want = tt.match
+			}
+			if search.MatchExpr != want {
+				t.Errorf("%q（fts=%v）: MATCH 为 %q，期望 %q", tt.keyword, enabled, search.MatchExpr, want)
+			}
+		}
+	}
+}
+
+func TestVideoSearchLike(t *testing.T) {
+	db := newTestDB(t)
+	videoSearchEnabled.Store(false)
+
+	videos := []models.Video{
+		{VodID: 1, VodName: "庆余年", VodActor: "张若昀", VodPinyin: "qingyunian"},
+		{VodID: 2, VodName: "庆余年2", VodActor: "张若昀,李沁", VodPinyin: "qingyunian2"},
+		{VodID: 3, VodName: "赘婿", VodActor: "郭麒麟", VodPinyin: "zhuixu"},
+	}
+	if err := db.Create(&videos).Error; err != nil {
+		t.Fatalf("创建视频失败: %v", err)
+	}
+
+	tests := []struct {
+		keyword string
+		want    []int
+	}{
+		{"庆余年", []int{1, 2}},
+		{"演员:李沁", []int{2}},
+		{"qingyunian2", []int{2}},
+		{"张若昀 赘婿", nil},
+	}
+	for _, tt := range tests {
+		search := ParseVideoSearch(tt.keyword)
+		var got []int
+		search.Apply(db.Model(&models.Video{})).Order("vod_id").Pluck("vod_id", &got)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: 结果为 %v，期望 %v", tt.keyword, got, tt.want)
+		}
+	}
+
+	highlights := ParseVideoSearch("演员:李沁").Highlights(db, []uint{videos[1].ID})
+	if got := highlights[videos[1].ID]["vod_actor"]; got != "张若昀,<em>李沁</em>" {
+		t.Errorf("高亮为 %q", got)
+	}
+}
+
+// TestInitVideoSearchWithoutFTS5 不带 FTS5 编译时应删除遗留的触发器，保证 videos 仍可写入
+func TestInitVideoSearchWithoutFTS5(t *testing.T) {
+	db := newTestDB(t)
+	t.Cleanup(func() { videoSearchEnabled.Store(false) })
+
+	// 模拟由带 FTS5 的程序创建的触发器（引用的虚拟表在当前程序中不可用）
+	err := db.Exec(`CREATE TRIGGER videos_fts_ai AFTER INSERT ON videos BEGIN
+		INSERT INTO videos_fts_missing(rowid) VALUES (new.id);
+	END`).Error
+	if err != nil {
+		t.Fatalf("创建触发器失败: %v", err)
+	}
+
+	initErr := InitVideoSearch(db)
+	if err := db.Create(&models.Video{VodID: 1, VodName: "庆余年"}).Error; err != nil {
+		t.Fatalf("初始化后写入视频失败: %v", err)
+	}
+
+	if probeFTS5(db) != nil {
+		if initErr == nil || VideoSearchAvailable() {
+			t.Error("FTS5 不可用时应返回错误并停用全文检索")
+		}
+		var count int64
+		db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ?", videoSearchTriggers).Scan(&count)
+		if count != 0 {
+			t.Errorf("FTS5 不可用时应删除触发器，仍有 %d 个", count)
+		}
+		return
+	}
+
+	// 以 -tags sqlite_fts5 运行时：重建触发器并可检索到新写入的视频
+	if initErr != nil || !VideoSearchAvailable() {
+		t.Fatalf("初始化全文检索失败: %v", initErr)
+	}
+	search := ParseVideoSearch("庆余年")
+	var count int64
+	search.Apply(db.Model(&models.Video{})).Count(&count)
+	if !search.UsesFTS() || count != 1 {
+		t.Errorf("全文检索结果 %d 条（fts=%v）", count, search.UsesFTS())
+	}
+}
@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// 子分类推断（映射引擎的第二阶段）
+// 很多资源站只有"电影""连续剧"这样的大类，映射后没有子分类，按以下顺序为视频推断：
+// 1. 子分类规则（subcategory_rules）：按优先级用正则匹配视频的标签、地区、标题或简介，命中后使用规则的置信度
+// 2. 分类器：该一级分类下评分最高的子分类（评分不低于 ClassifierMinScore）
+// 映射结果没有子分类时总是推断；子分类来自 keyword/classifier 阶段时，只有推断的置信度更高才替换
+// 精确规则、名称匹配和模式规则指定的子分类不会被改变
+// 首次启动时写入一次默认规则（见 defaultSubCategoryRules、EnsureSubCategoryRules），之后只由管理员维护
+
+// SubCategoryMatchFields 子分类规则可以匹配的字段
+var SubCategoryMatchFields = []string{MatchFieldVodClass, MatchFieldVodArea, MatchFieldTitle, MatchFieldContent}
+
+// 子分类规则的默认置信度
+const defaultSubCategoryConfidence = 0.8
+
+// subCategoryRule 编译后的子分类规则
+type subCategoryRule struct {
+	models.SubCategoryRule
+	re *regexp.Regexp
+}
+
+// ValidateSubCategoryRule 校验并规范化子分类规则
+func ValidateSubCategoryRule(rule *models.SubCategoryRule) error {
+	valid := false
+	for _, field := range SubCategoryMatchFields {
+		if rule.Field == field {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("不支持的匹配字段 %s，可选: vod_class, vod_area, title, content", rule.Field)
+	}
+	if _, err := CompileCategoryPattern(rule.Pattern); err != nil {
+		return err
+	}
+
+	sub, ok := GetTaxonomy().SubCategory(rule.StandardSubID)
+	if !ok {
+		return fmt.Errorf("子分类 %d 不存在", rule.StandardSubID)
+	}
+	if rule.StandardID == 0 {
+		rule.StandardID = sub.CategoryID
+	} else if sub.CategoryID != rule.StandardID {
+		return fmt.Errorf("子分类 %d 不属于分类 %d", rule.StandardSubID, rule.StandardID)
+	}
+
+	if rule.Confidence == 0 {
+		rule.Confidence = defaultSubCategoryConfidence
+	}
+	if rule.Confidence < 0 || rule.Confidence > 1 {
+		return fmt.Errorf("confidence 必须在 0 到 1 之间")
+	}
+	return nil
+}
+
+// SubCategorySeedAction 写入默认子分类规则的变更集操作
+const SubCategorySeedAction = "seed_subcategory_rules"
+
+// EnsureSubCategoryRules 首次启动时写入默认规则（只在启动时调用一次）
+// 写入记录为变更集；规则表不为空或已经写入过（规则被管理员删除或回滚）时不再写入
+func EnsureSubCategoryRules(db *gorm.DB, taxonomy *Taxonomy) {
+	var count, seeded int64
+	if db.Model(&models.SubCategoryRule{}).Count(&count).Error != nil || count > 0 {
+		return
+	}
+	if db.Model(&models.MappingChangeset{}).Where("action = ?", SubCategorySeedAction).Count(&seeded).Error != nil || seeded > 0 {
+		return
+	}
+	change := BeginMappingChange(db, "system", SubCategorySeedAction)
+	defer change.End()
+	change.Summary = "写入默认子分类规则"
+	seedSubCategoryRules(db, taxonomy)
+}
+
+// loadSubCategoryRules 读取启用的子分类规则，按一级分类分组（只读）
+func loadSubCategoryRules(db *gorm.DB) map[int][]*subCategoryRule {
+	var rules []models.SubCategoryRule
+	db.Where("is_active = ?", true).Order("priority ASC, id ASC").Find(&rules)
+	result := make(map[int][]*subCategoryRule)
+	for _, rule := range rules {
+		re, err := CompileCategoryPattern(rule.Pattern)
+		if err != nil {
+			fmt.Printf("⚠️ 子分类规则 #%d 的正则无效，已跳过: %v\n", rule.ID, err)
+			continue
+		}
+		result[rule.StandardID] = append(result[rule.StandardID], &subCategoryRule{SubCategoryRule: rule, re: re})
+	}
+	return result
+}
+
+// refineSubCategory 推断子分类，结果写回 d
+func (m *CategoryMapper) refineSubCategory(in CategoryInput, d *CategoryDecision) {
+	if d.StandardSubID != nil {
+		d.SubConfidence = d.Score
+	}
+	if d.StandardID == OtherCategoryID {
+		return
+	}
+	if d.StandardSubID != nil && d.Stage != MappingStageKeyword && d.Stage != MappingStageClassifier {
+		return
+	}
+
+	subID, confidence, explanation, ok := m.inferSubCategory(in, d.StandardID)
+	if !ok || sameSubCategory(d.StandardSubID, &subID) {
+		return
+	}
+	if d.StandardSubID != nil && confidence <= d.SubConfidence {
+		return
+	}
+
+	d.StandardSubID = &subID
+	d.StandardName, d.StandardSubName = m.taxonomy.Names(d.StandardID, d.StandardSubID)
+	d.SubConfidence = confidence
+	d.Explanation = fmt.Sprintf("%s；子分类：%s → %s", d.Explanation, explanation, d.StandardSubName)
+}
+
+// inferSubCategory 按子分类规则和分类器推断子分类
+func (m *CategoryMapper) inferSubCategory(in CategoryInput, standardID int) (int, float64, string, bool) {
+	for _, rule := range m.subRules[standardID] {
+		value := in.field(rule.Field)
+		if value == "" || !rule.re.MatchString(value) {
+			continue
+		}
+		if _, ok := m.taxonomy.SubCategory(rule.StandardSubID); !ok {
+			continue
+		}
+		return rule.StandardSubID, rule.Confidence,
+			fmt.Sprintf("子分类规则 #%d（%s）匹配 %s \"%s\"", rule.ID, rule.Pattern, rule.Field, truncateString(value, 90)), true
+	}
+
+	if m.classifier == nil {
+		return 0, 0, "", false
+	}
+	cat, ok := m.taxonomy.Category(standardID)
+	if !ok {
+		return 0, 0, "", false
+	}
+	bestID, bestScore := 0, 0.0
+	for _, sub := range cat.Subcategories {
+		subID := sub.ID
+		if score, ok := m.classifier.Score(in, standardID, &subID); ok && score > bestScore {
+			bestID, bestScore = sub.ID, score
+		}
+	}
+	if bestID == 0 || bestScore < ClassifierMinScore {
+		return 0, 0, "", false
+	}
+	return bestID, bestScore, fmt.Sprintf("分类器评分 %.2f", bestScore), true
+}
+
+// seedSubCategoryRules 写入默认规则（子分类不存在的规则跳过）
+func seedSubCategoryRules(db *gorm.DB, taxonomy *Taxonomy) {
+	created := 0
+	for _, rule := range defaultSubCategoryRules {
+		sub, ok := taxonomy.SubCategory(rule.StandardSubID)
+		if !ok || sub.CategoryID != rule.StandardID {
+			continue
+		}
+		rule.IsActive = true
+		if err := db.Create(&rule).Error; err != nil {
+			fmt.Printf("⚠️ 写入默认子分类规则失败: %v\n", err)
+			return
+		}
+		created++
+	}
+	if created > 0 {
+		fmt.Printf("📂 已写入默认子分类规则: %d 条\n", created)
+	}
+}
+
+// defaultSubCategoryRules 默认的子分类规则
+// 地区规则中港澳台的优先级高于大陆，避免"中国香港"被归入大陆
+var defaultSubCategoryRules = []models.SubCategoryRule{
+	// 电影：按标签
+	{StandardID: 1, StandardSubID: 101, Field: MatchFieldVodClass, Pattern: "动作|武侠|功夫", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 102, Field: MatchFieldVodClass, Pattern: "喜剧|搞笑", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 103, Field: MatchFieldVodClass, Pattern: "爱情", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 104, Field: MatchFieldVodClass, Pattern: "科幻", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 105, Field: MatchFieldVodClass, Pattern: "恐怖|惊悚", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 107, Field: MatchFieldVodClass, Pattern: "战争", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 108, Field: MatchFieldVodClass, Pattern: "悬疑|推理", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 109, Field: MatchFieldVodClass, Pattern: "犯罪", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 110, Field: MatchFieldVodClass, Pattern: "奇幻|魔幻", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 111, Field: MatchFieldVodClass, Pattern: "灾难", Confidence: 0.8, Priority: 100},
+	{StandardID: 1, StandardSubID: 106, Field: MatchFieldVodClass, Pattern: "剧情", Confidence: 0.6, Priority: 200},
+	{StandardID: 1, StandardSubID: 114, Field: MatchFieldTitle, Pattern: "netflix|网飞", Confidence: 0.7, Priority: 150},
+
+	// 电视剧：按地区
+	{StandardID: 2, StandardSubID: 202, Field: MatchFieldVodArea, Pattern: "香港|澳门", Confidence: 0.9, Priority: 90},
+	{StandardID: 2, StandardSubID: 203, Field: MatchFieldVodArea, Pattern: "台湾", Confidence: 0.9, Priority: 90},
+	{StandardID: 2, StandardSubID: 201, Field: MatchFieldVodArea, Pattern: "大陆|内地|^中国$", Confidence: 0.9, Priority: 100},
+	{StandardID: 2, StandardSubID: 205, Field: MatchFieldVodArea, Pattern: "韩国", Confidence: 0.9, Priority: 100},
+	{StandardID: 2, StandardSubID: 206, Field: MatchFieldVodArea, Pattern: "日本", Confidence: 0.9, Priority: 100},
+	{StandardID: 2, StandardSubID: 207, Field: MatchFieldVodArea, Pattern: "泰国", Confidence: 0.9, Priority: 100},
+	{StandardID: 2, StandardSubID: 204, Field: MatchFieldVodArea, Pattern: "美国|英国|欧美|法国|德国|加拿大", Confidence: 0.9, Priority: 100},
+
+	// 综艺：按地区
+	{StandardID: 3, StandardSubID: 302, Field: MatchFieldVodArea, Pattern: "香港|澳门|台湾", Confidence: 0.9, Priority: 90},
+	{StandardID: 3, StandardSubID: 301, Field: MatchFieldVodArea, Pattern: "大陆|内地|^中国$", Confidence: 0.9, Priority: 100},
+	{StandardID: 3, StandardSubID: 303, Field: MatchFieldVodArea, Pattern: "日本|韩国", Confidence: 0.9, Priority: 100},
+	{StandardID: 3, StandardSubID: 304, Field: MatchFieldVodArea, Pattern: "美国|英国|欧美", Confidence: 0.9, Priority: 100},
+
+	// 动漫：按地区
+	{StandardID: 4, StandardSubID: 404, Field: MatchFieldVodArea, Pattern: "香港|澳门|台湾", Confidence: 0.9, Priority: 90},
+	{StandardID: 4, StandardSubID: 401, Field: MatchFieldVodArea, Pattern: "大陆|内地|^中国$", Confidence: 0.9, Priority: 100},
+	{StandardID: 4, StandardSubID: 402, Field: MatchFieldVodArea, Pattern: "日本|韩国", Confidence: 0.9, Priority: 100},
+	{StandardID: 4, StandardSubID: 403, Field: MatchFieldVodArea, Pattern: "美国|英国|欧美|法国", Confidence: 0.9, Priority: 100},
+
+	// 短剧：按标签和标题
+	{StandardID: 6, StandardSubID: 604, Field: MatchFieldVodClass, Pattern: "古装|仙侠|玄幻", Confidence: 0.7, Priority: 100},
+	{StandardID: 6, StandardSubID: 605, Field: MatchFieldVodClass, Pattern: "年代|穿越", Confidence: 0.7, Priority: 100},
+	{StandardID: 6, StandardSubID: 606, Field: MatchFieldVodClass, Pattern: "悬疑|脑洞", Confidence: 0.7, Priority: 100},
+	{StandardID: 6, StandardSubID: 602, Field: MatchFieldVodClass, Pattern: "恋爱|甜宠|言情", Confidence: 0.7, Priority: 100},
+	{StandardID: 6, StandardSubID: 607, Field: MatchFieldVodClass, Pattern: "都市", Confidence: 0.6, Priority: 150},
+	{StandardID: 6, StandardSubID: 603, Field: MatchFieldTitle, Pattern: "逆袭|打脸|战神|赘婿", Confidence: 0.6, Priority: 200},
+}
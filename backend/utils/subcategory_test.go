@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestEnsureSubCategoryRulesSeedsOnce(t *testing.T) {
+	db := newTestDB(t)
+	count := func() int64 {
+		var n int64
+		db.Model(&models.SubCategoryRule{}).Count(&n)
+		return n
+	}
+
+	// 映射引擎只读取规则，不写入默认规则
+	NewCategoryMapper(db)
+	if n := count(); n != 0 {
+		t.Fatalf("创建映射引擎写入了 %d 条规则", n)
+	}
+
+	EnsureSubCategoryRules(db, GetTaxonomy())
+	seeded := count()
+	if seeded == 0 {
+		t.Fatal("没有写入默认规则")
+	}
+	var changesets []models.MappingChangeset
+	db.Where("action = ?", SubCategorySeedAction).Find(&changesets)
+	if len(changesets) != 1 || changesets[0].ChangeCount != int(seeded) {
+		t.Fatalf("默认规则应记录为一个变更集，得到 %+v", changesets)
+	}
+
+	// 规则全部删除后不再重新写入
+	db.Where("1 = 1").Delete(&models.SubCategoryRule{})
+	EnsureSubCategoryRules(db, GetTaxonomy())
+	if n := count(); n != 0 {
+		t.Errorf("删除后又写入了 %d 条默认规则", n)
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"vodcms/models"
+)
+
+// newTestDB 创建内存数据库，导入 category_mapping.json 中的标准分类并刷新分类缓存
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	err = db.AutoMigrate(
+		&models.Video{},
+		&models.Source{},
+		&models.CollectionLog{},
+		&models.VideoHistory{},
+		&models.UnmappedCategory{},
+		&models.MappingRule{},
+		&models.FuzzyMatchRule{},
+		&models.SubCategoryRule{},
+		&models.MappingChangeset{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.PlayCheck{},
+		&models.StandardCategory{},
+		&models.StandardSubCategory{},
+	)
+	if err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+
+	data, err := os.ReadFile("../" + CategoryMappingFile)
+	if err != nil {
+		t.Fatalf("读取标准分类失败: %v", err)
+	}
+	if _, _, err := ImportTaxonomy(db, data); err != nil {
+		t.Fatalf("导入标准分类失败: %v", err)
+	}
+	if err := ReloadTaxonomy(db); err != nil {
+		t.Fatalf("加载标准分类失败: %v", err)
+	}
+	return db
+}
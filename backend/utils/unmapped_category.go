@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// 未映射分类
+// 没有映射规则的资源站分类记录到 unmapped_categories，供管理员确认或补充规则
+// 只有映射规则（mapping_rules，即 CategoryDecision.ByRule）确定的分类才算已映射；
+// mapping_rules 中 fuzzy/pattern 规则命中的 pattern 阶段结果也算映射规则确定；
+// 模糊规则（fuzzy_match_rules）、keyword、classifier 阶段的结果只是推测，分类仍为 pending，推测结果作为建议映射
+// 1. 导入时更新：video_count 为该分类在库中的视频总数，last_seen_at 为最后一次导入时间
+// 2. 建议映射：推测结果投票；映射结果为"其他"时用该分类下视频的标签（vod_class）投票，取命中次数最多的标准分类
+// 3. 有规则后自动标记为 mapped；规则删除后恢复为 pending，ignored 保持不变
+//    只改状态时不更新 last_seen_at
+
+// 未映射分类状态
+const (
+	UnmappedStatusPending = "pending"
+	UnmappedStatusMapped  = "mapped"
+	UnmappedStatusIgnored = "ignored"
+)
+
+// unmappedTracker 收集一次导入中遇到的分类
+type unmappedTracker struct {
+	mapper   *CategoryMapper
+	unmapped map[string]*unmappedSeen
+	covered  map[string]coveredCategory
+}
+
+// unmappedSeen 没有规则的分类和建议投票
+type unmappedSeen struct {
+	sourceKey string
+	typeID    int
+	typeName  string
+	votes     map[string]*suggestionVote
+}
+
+type suggestionVote struct {
+	standardID    int
+	standardSubID *int
+	count         int
+}
+
+// coveredCategory 已有规则的分类
+type coveredCategory struct {
+	sourceKey string
+	typeID    int
+	decision  CategoryDecision
+}
+
+func newUnmappedTracker(mapper *CategoryMapper) *unmappedTracker {
+	return &unmappedTracker{
+		mapper:   mapper,
+		unmapped: make(map[string]*unmappedSeen),
+		covered:  make(map[string]coveredCategory),
+	}
+}
+
+// observe 记录一个视频的映射结果
+func (t *unmappedTracker) observe(video *models.Video, decision CategoryDecision) {
+	if video.TypeID <= 0 {
+		return
+	}
+	key := fmt.Sprintf("%s:%d", video.SourceKey, video.TypeID)
+	if decision.ByRule {
+		t.covered[key] = coveredCategory{video.SourceKey, video.TypeID, decision}
+		return
+	}
+
+	seen, ok := t.unmapped[key]
+	if !ok {
+		seen = &unmappedSeen{
+			sourceKey: video.SourceKey,
+			typeID:    video.TypeID,
+			typeName:  video.TypeName,
+			votes:     make(map[string]*suggestionVote),
+		}
+		t.unmapped[key] = seen
+	}
+	if decision.Stage != MappingStageDefault {
+		seen.vote(decision)
+		return
+	}
+	for _, tag := range splitLibraryNames(video.VodClass) {
+		d := t.mapper.Map(CategoryInput{SourceKey: video.SourceKey, TypeName: tag})
+		if d.Stage == MappingStageDefault || d.StandardID == OtherCategoryID {
+			continue
+		}
+		seen.vote(d)
+	}
+}
+
+func (s *unmappedSeen) vote(d CategoryDecision) {
+	voteKey := fmt.Sprintf("%d:%s", d.StandardID, subIDKey(d.StandardSubID))
+	if vote, ok := s.votes[voteKey]; ok {
+		vote.count++
+	} else {
+		s.votes[voteKey] = &suggestionVote{d.StandardID, d.StandardSubID, 1}
+	}
+}
+
+// save 写入未映射分类，并把已被规则覆盖的分类标记为 mapped
+func (t *unmappedTracker) save(db *gorm.DB) {
+	for _, covered := range t.covered {
+		if _, ok := t.unmapped[fmt.Sprintf("%s:%d", covered.sourceKey, covered.typeID)]; ok {
+			continue // 同一分类的部分视频没有规则（如按视频字段匹配的 fuzzy 映射规则）
+		}
+		db.Model(&models.UnmappedCategory{}).
+			Where("source_key = ? AND source_type_id = ? AND status = ?", covered.sourceKey, covered.typeID, UnmappedStatusPending).
+			UpdateColumns(map[string]interface{}{
+				"status":        UnmappedStatusMapped,
+				"mapped_id":     covered.decision.StandardID,
+				"mapped_sub_id": covered.decision.StandardSubID,
+			})
+	}
+
+	for _, seen := range t.unmapped {
+		if err := upsertUnmappedCategory(db, seen); err != nil {
+			fmt.Printf("⚠️ 记录未映射分类 %s/%d 失败: %v\n", seen.sourceKey, seen.typeID, err)
+		}
+	}
+}
+
+// upsertUnmappedCategory 新增或更新未映射分类，首次发现时触发 category.unmapped 事件
+func upsertUnmappedCategory(db *gorm.DB, seen *unmappedSeen) error {
+	var count int64
+	db.Model(&models.Video{}).Where("source_key = ? AND type_id = ?", seen.sourceKey, seen.typeID).Count(&count)
+
+	var unmapped models.UnmappedCategory
+	found := db.Where("source_key = ? AND source_type_id = ?", seen.sourceKey, seen.typeID).Limit(1).Find(&unmapped).RowsAffected > 0
+
+	unmapped.SourceKey = seen.sourceKey
+	unmapped.SourceTypeID = seen.typeID
+	if seen.typeName != "" {
+		unmapped.SourceName = seen.typeName
+	}
+	unmapped.VideoCount = int(count)
+	unmapped.LastSeenAt = time.Now()
+	if vote := seen.bestVote(); vote != nil {
+		id := vote.standardID
+		unmapped.SuggestedID = &id
+		unmapped.SuggestedSubID = vote.standardSubID
+	}
+	if unmapped.Status == UnmappedStatusMapped {
+		// 又没有规则了，说明原来的规则已被删除或停用
+		unmapped.Status = UnmappedStatusPending
+		unmapped.MappedID = nil
+		unmapped.MappedSubID = nil
+	}
+
+	if found {
+		return db.Save(&unmapped).Error
+	}
+	unmapped.Status = UnmappedStatusPending
+	if err := db.Create(&unmapped).Error; err != nil {
+		return err
+	}
+	FireWebhook(db, WebhookEventCategoryUnmapped, map[string]interface{}{
+		"id":               unmapped.ID,
+		"source_key":       unmapped.SourceKey,
+		"source_type_id":   unmapped.SourceTypeID,
+		"source_name":      unmapped.SourceName,
+		"video_count":      unmapped.VideoCount,
+		"suggested_id":     unmapped.SuggestedID,
+		"suggested_sub_id": unmapped.SuggestedSubID,
+	})
+	return nil
+}
+
+// bestVote 票数最多的建议（票数相同时取分类ID较小的，保证结果稳定）
+func (s *unmappedSeen) bestVote() *suggestionVote {
+	votes := make([]*suggestionVote, 0, len(s.votes))
+	for _, vote := range s.votes {
+		votes = append(votes, vote)
+	}
+	if len(votes) == 0 {
+		return nil
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		if votes[i].count != votes[j].count {
+			return votes[i].count > votes[j].count
+		}
+		if votes[i].standardID != votes[j].standardID {
+			return votes[i].standardID < votes[j].standardID
+		}
+		return subIDKey(votes[i].standardSubID) < subIDKey(votes[j].standardSubID)
+	})
+	return votes[0]
+}
+
+// SyncUnmappedCategories 按当前映射规则同步未映射分类的状态（sourceKey 为空表示全部资源站），返回状态变化的数量
+// pending 的分类有规则后标记为 mapped；mapped 的分类失去规则后恢复为 pending
+func SyncUnmappedCategories(db *gorm.DB, sourceKey string) int {
+	query := db.Where("status IN ?", []string{UnmappedStatusPending, UnmappedStatusMapped})
+	if sourceKey != "" {
+		query = query.Where("source_key = ?", sourceKey)
+	}
+	var rows []models.UnmappedCategory
+	if err := query.Find(&rows).Error; err != nil || len(rows) == 0 {
+		return 0
+	}
+
+	mapper := NewCategoryMapper(db)
+	changed := 0
+	for _, row := range rows {
+		decision := mapper.Map(CategoryInput{SourceKey: row.SourceKey, SourceTypeID: row.SourceTypeID, TypeName: row.SourceName})
+		var updates map[string]interface{}
+		switch {
+		case row.Status == UnmappedStatusPending && decision.ByRule:
+			updates = map[string]interface{}{
+				"status":        UnmappedStatusMapped,
+				"mapped_id":     decision.StandardID,
+				"mapped_sub_id": decision.StandardSubID,
+			}
+		case row.Status == UnmappedStatusMapped && !decision.ByRule:
+			updates = map[string]interface{}{
+				"status":        UnmappedStatusPending,
+				"mapped_id":     nil,
+				"mapped_sub_id": nil,
+			}
+		default:
+			continue
+		}
+		if db.Model(&models.UnmappedCategory{}).Where("id = ?", row.ID).UpdateColumns(updates).Error == nil {
+			changed++
+		}
+	}
+	if changed > 0 {
+		fmt.Printf("🏷️ 未映射分类状态已同步: %d 个\n", changed)
+	}
+	return changed
+}
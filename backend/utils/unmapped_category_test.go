@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestUnmappedCategoryOnlyResolvedByRules(t *testing.T) {
+	db := newTestDB(t)
+	videos := []models.Video{
+		{VodID: 1, VodName: "A", SourceKey: "test", TypeID: 7, TypeName: "韩国综艺"},
+		{VodID: 2, VodName: "B", SourceKey: "test", TypeID: 8, TypeName: "火星分类", VodClass: "动作"},
+	}
+	if err := db.Create(&videos).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := newUnmappedTracker(NewCategoryMapper(db))
+	for i := range videos {
+		tracker.observe(&videos[i], tracker.mapper.Map(VideoCategoryInput(&videos[i])))
+	}
+	tracker.save(db)
+
+	load := func(typeID int) models.UnmappedCategory {
+		var row models.UnmappedCategory
+		if err := db.Where("source_key = ? AND source_type_id = ?", "test", typeID).First(&row).Error; err != nil {
+			t.Fatalf("分类 %d 没有记录: %v", typeID, err)
+		}
+		return row
+	}
+
+	// 关键词推测的结果保持 pending，推测结果作为建议
+	guessed := load(7)
+	if guessed.Status != UnmappedStatusPending || guessed.SuggestedID == nil || *guessed.SuggestedID != 3 {
+		t.Errorf("韩国综艺: status=%s suggested=%v，期望 pending 且建议综艺", guessed.Status, guessed.SuggestedID)
+	}
+	// 映射为"其他"的分类按标签投票
+	other := load(8)
+	if other.Status != UnmappedStatusPending || other.SuggestedID == nil || *other.SuggestedID != 1 {
+		t.Errorf("火星分类: status=%s suggested=%v，期望 pending 且建议电影", other.Status, other.SuggestedID)
+	}
+
+	// 添加规则后标记为 mapped，删除规则后恢复为 pending
+	rule := models.MappingRule{SourceKey: "test", SourceTypeID: 7, SourceName: "韩国综艺", StandardID: 3, MatchType: MatchTypeExact, Priority: 100, IsActive: true}
+	db.Create(&rule)
+	SyncUnmappedCategories(db, "test")
+	if row := load(7); row.Status != UnmappedStatusMapped {
+		t.Errorf("添加规则后 status=%s，期望 mapped", row.Status)
+	}
+	if row := load(8); row.Status != UnmappedStatusPending {
+		t.Errorf("其他分类 status=%s，期望 pending", row.Status)
+	}
+
+	db.Model(&rule).Update("is_active", false)
+	SyncUnmappedCategories(db, "test")
+	if row := load(7); row.Status != UnmappedStatusPending {
+		t.Errorf("删除规则后 status=%s，期望 pending", row.Status)
+	}
+}
+
+func TestUnmappedCategoryPatternRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       interface{}
+		wantStatus string
+	}{
+		{"映射规则 pattern", &models.MappingRule{SourceKey: "test", SourceName: "真人秀", Pattern: "真人秀$", StandardID: 3, MatchType: MatchTypePattern, Priority: 100, IsActive: true}, UnmappedStatusMapped},
+		{"映射规则 fuzzy", &models.MappingRule{SourceKey: "test", SourceName: "真人秀", Pattern: "真人秀", StandardID: 3, MatchType: MatchTypeFuzzy, Priority: 100, IsActive: true}, UnmappedStatusMapped},
+		{"模糊规则只是推测", &models.FuzzyMatchRule{Pattern: "真人秀$", MatchFields: MatchFieldTypeName, StandardID: 3, Priority: 100, IsActive: true}, UnmappedStatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			db.Create(&models.UnmappedCategory{SourceKey: "test", SourceTypeID: 9, SourceName: "火星真人秀", Status: UnmappedStatusPending})
+			if err := db.Create(tt.rule).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			decision := NewCategoryMapper(db).Map(CategoryInput{SourceKey: "test", SourceTypeID: 9, TypeName: "火星真人秀"})
+			if decision.Stage != MappingStagePattern || decision.StandardID != 3 {
+				t.Fatalf("映射结果 %s/%d，期望 pattern 阶段映射到综艺", decision.Stage, decision.StandardID)
+			}
+			SyncUnmappedCategories(db, "test")
+			var row models.UnmappedCategory
+			db.Where("source_key = ? AND source_type_id = ?", "test", 9).First(&row)
+			if row.Status != tt.wantStatus {
+				t.Errorf("status=%s，期望 %s", row.Status, tt.wantStatus)
+			}
+		})
+	}
+}
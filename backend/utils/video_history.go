@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vodcms/models"
+
+	"gorm.io/gorm"
+)
+
+// FieldChange 单个字段的变更
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// videoHistoryIgnoredFields 不参与差异比较的字段（主键、时间戳、关联和锁定状态本身）
+var videoHistoryIgnoredFields = map[string]bool{
+	"id":              true,
+	"created_at":      true,
+	"updated_at":      true,
+	"collected_at":    true,
+	"video_type":      true,
+	"vod_lock":        true,
+	"vod_lock_fields": true,
+}
+
+var (
+	// videoFieldIndex json字段名 -> Video结构体字段下标
+	videoFieldIndex = make(map[string]int)
+	// videoFieldNames 可比较字段（按结构体定义顺序）
+	videoFieldNames []string
+)
+
+func init() {
+	t := reflect.TypeOf(models.Video{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || videoHistoryIgnoredFields[name] {
+			continue
+		}
+		videoFieldIndex[name] = i
+		videoFieldNames = append(videoFieldNames, name)
+	}
+}
+
+// VideoFieldNames 获取可追踪/可锁定的视频字段列表
+func VideoFieldNames() []string {
+	return append([]string(nil), videoFieldNames...)
+}
+
+// IsVideoField 判断是否为可追踪/可锁定的视频字段
+func IsVideoField(name string) bool {
+	_, ok := videoFieldIndex[name]
+	return ok
+}
+
+// GetVideoField 读取视频字段值（指针字段会被解引用，nil返回nil）
+func GetVideoField(video *models.Video, field string) interface{} {
+	i, ok := videoFieldIndex[field]
+	if !ok {
+		return nil
+	}
+	v := reflect.ValueOf(video).Elem().Field(i)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		return v.Elem().Interface()
+	}
+	return v.Interface()
+}
+
+// SetVideoField 设置视频字段值，value 可以是JSON解码后的值（数字为float64）
+func SetVideoField(video *models.Video, field string, value interface{}) error {
+	i, ok := videoFieldIndex[field]
+	if !ok {
+		return fmt.Errorf("未知字段: %s", field)
+	}
+	target := reflect.ValueOf(video).Elem().Field(i)
+
+	if target.Kind() == reflect.Ptr {
+		if value == nil {
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		elem := reflect.New(target.Type().Elem())
+		if err := assignFieldValue(elem.Elem(), value); err != nil {
+			return fmt.Errorf("字段 %s: %w", field, err)
+		}
+		target.Set(elem)
+		return nil
+	}
+
+	if err := assignFieldValue(target, value); err != nil {
+		return fmt.Errorf("字段 %s: %w", field, err)
+	}
+	return nil
+}
+
+// assignFieldValue 按目标类型转换并赋值
+func assignFieldValue(target reflect.Value, value interface{}) error {
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			target.SetString(v)
+		case float64:
+			target.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			target.SetString(fmt.Sprintf("%v", v))
+		}
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		target.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint64, reflect.Uint32:
+		n, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("不能为负数")
+		}
+		target.SetUint(uint64(n))
+	case reflect.Float64, reflect.Float32:
+		n, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		target.SetFloat(n)
+	default:
+		return fmt.Errorf("不支持的字段类型 %s", target.Kind())
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("无法转换为数字: %v", value)
+}
+
+// DiffVideoFields 比较两个视频版本，返回发生变化的字段
+func DiffVideoFields(oldVideo, newVideo *models.Video) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	for _, field := range videoFieldNames {
+		oldValue := GetVideoField(oldVideo, field)
+		newValue := GetVideoField(newVideo, field)
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes[field] = FieldChange{Old: oldValue, New: newValue}
+		}
+	}
+	return changes
+}
+
+// LockedFields 获取视频被锁定的字段列表
+func LockedFields(video *models.Video) []string {
+	if video.VodLockFields == "" {
+		return nil
+	}
+	var fields []string
+	for _, f := range strings.Split(video.VodLockFields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// categoryLockGroup 标准分类相关字段必须一起锁定，避免ID、名称和子分类不一致
+var categoryLockGroup = []string{
+	"standard_category_id",
+	"standard_category_name",
+	"standard_sub_category_id",
+	"standard_sub_category_name",
+	"standard_sub_category_confidence",
+}
+
+// ExpandLockFields 补全关联字段（例如锁定 standard_category_id 时同时锁定名称和子分类）
+func ExpandLockFields(fields []string) []string {
+	result := append([]string(nil), fields...)
+	for _, f := range fields {
+		for _, member := range categoryLockGroup {
+			if f == member {
+				return append(result, categoryLockGroup...)
+			}
+		}
+	}
+	return result
+}
+
+// SetLockedFields 设置视频锁定字段（补全关联字段并去重排序），并同步 VodLock 标记
+func SetLockedFields(video *models.Video, fields []string) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, f := range ExpandLockFields(fields) {
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		result = append(result, f)
+	}
+	sort.Strings(result)
+
+	video.VodLockFields = strings.Join(result, ",")
+	if len(result) > 0 {
+		video.VodLock = 1
+	} else {
+		video.VodLock = 0
+	}
+}
+
+// ApplyLockedFields 把 src 中被锁定的字段值写回 dst（采集更新时保留人工修改）
+func ApplyLockedFields(dst, src *models.Video) {
+	dst.VodLock = src.VodLock
+	dst.VodLockFields = src.VodLockFields
+	for _, field := range LockedFields(src) {
+		i, ok := videoFieldIndex[field]
+		if !ok {
+			continue
+		}
+		reflect.ValueOf(dst).Elem().Field(i).Set(reflect.ValueOf(src).Elem().Field(i))
+	}
+}
+
+// ParseHistoryChanges 解析历史记录中的字段差异
+func ParseHistoryChanges(history *models.VideoHistory) (map[string]FieldChange, error) {
+	changes := make(map[string]FieldChange)
+	if history.Changes == "" {
+		return changes, nil
+	}
+	if err := json.Unmarshal([]byte(history.Changes), &changes); err != nil {
+		return nil, fmt.Errorf("解析变更记录失败: %w", err)
+	}
+	return changes, nil
+}
+
+// RecordVideoHistory 记录一次视频变更（没有变化时不记录）
+func RecordVideoHistory(db *gorm.DB, video *models.Video, changes map[string]FieldChange, action string, collectionLogID uint) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("编码变更记录失败: %w", err)
+	}
+
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	history := models.VideoHistory{
+		VideoID:         video.ID,
+		VodID:           video.VodID,
+		SourceKey:       video.SourceKey,
+		CollectionLogID: collectionLogID,
+		Action:          action,
+		Changes:         string(data),
+		ChangedFields:   strings.Join(fields, ","),
+	}
+	return db.Create(&history).Error
+}
+
+// RestoreVideoHistory 把视频恢复到指定历史记录发生之前的版本
+// 会按时间倒序回放该记录及其之后的所有变更，恢复本身也会记录为一条 restore 历史
+// lock 为 true 时，恢复的字段会被锁定，防止下次采集再次覆盖
+func RestoreVideoHistory(db *gorm.DB, historyID uint, lock bool) (*models.Video, map[string]FieldChange, error) {
+	var target models.VideoHistory
+	if err := db.First(&target, historyID).Error; err != nil {
+		return nil, nil, fmt.Errorf("历史记录不存在: %w", err)
+	}
+
+	var video models.Video
+	if err := db.First(&video, target.VideoID).Error; err != nil {
+		return nil, nil, fmt.Errorf("视频不存在: %w", err)
+	}
+
+	var histories []models.VideoHistory
+	if err := db.Where("video_id = ? AND id >= ?", target.VideoID, target.ID).
+		Order("id DESC").Find(&histories).Error; err != nil {
+		return nil, nil, fmt.Errorf("读取历史记录失败: %w", err)
+	}
+
+	restored := video
+	for i := range histories {
+		changes, err := ParseHistoryChanges(&histories[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		for field, change := range changes {
+			if !IsVideoField(field) {
+				continue
+			}
+			if err := SetVideoField(&restored, field, change.Old); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	changes := DiffVideoFields(&video, &restored)
+	if lock && len(changes) > 0 {
+		fields := LockedFields(&restored)
+		for field := range changes {
+			fields = append(fields, field)
+		}
+		SetLockedFields(&restored, fields)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&restored).Error; err != nil {
+			return err
+		}
+		return RecordVideoHistory(tx, &restored, changes, "restore", 0)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("恢复失败: %w", err)
+	}
+
+	return &restored, changes, nil
+}
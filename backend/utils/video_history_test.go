@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestDiffVideoFields(t *testing.T) {
+	sub := 101
+	oldVideo := models.Video{ID: 1, VodName: "庆余年", VodScore: "8.0", VodDoubanScore: 7.9, StandardCategoryID: 1}
+	newVideo := oldVideo
+	newVideo.ID = 2                       // 主键不参与比较
+	newVideo.VodLockFields = "vod_name"   // 锁定状态不参与比较
+	newVideo.VodScore = "8.5"             // 字符串
+	newVideo.VodDoubanScore = 8.1         // 浮点数
+	newVideo.StandardSubCategoryID = &sub // 指针字段
+	changes := DiffVideoFields(&oldVideo, &newVideo)
+
+	want := map[string]FieldChange{
+		"vod_score":                {Old: "8.0", New: "8.5"},
+		"vod_douban_score":         {Old: 7.9, New: 8.1},
+		"standard_sub_category_id": {Old: nil, New: 101},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("差异字段为 %v，期望 %v", changes, want)
+	}
+	for field, w := range want {
+		if got, ok := changes[field]; !ok || got != w {
+			t.Errorf("%s: 得到 %+v，期望 %+v", field, got, w)
+		}
+	}
+
+	if changes := DiffVideoFields(&oldVideo, &oldVideo); len(changes) != 0 {
+		t.Errorf("相同版本不应有差异: %v", changes)
+	}
+}
+
+func TestRestoreVideoHistory(t *testing.T) {
+	db := newTestDB(t)
+
+	video := models.Video{VodID: 1, VodName: "庆余年", VodRemarks: "更新至10集", VodScore: "8.0", SourceKey: "test"}
+	if err := db.Create(&video).Error; err != nil {
+		t.Fatalf("创建视频失败: %v", err)
+	}
+
+	// 两次采集更新，各记录一条历史
+	update := func(mutate func(v *models.Video)) uint {
+		var current models.Video
+		db.First(&current, video.ID)
+		next := current
+		mutate(&next)
+		if err := db.Save(&next).Error; err != nil {
+			t.Fatalf("更新视频失败: %v", err)
+		}
+		if err := RecordVideoHistory(db, &next, DiffVideoFields(&current, &next), "import", 0); err != nil {
+			t.Fatalf("记录历史失败: %v", err)
+		}
+		var history models.VideoHistory
+		db.Order("id DESC").First(&history)
+		return history.ID
+	}
+	first := update(func(v *models.Video) { v.VodRemarks = "更新至20集"; v.VodScore = "8.5" })
+	update(func(v *models.Video) { v.VodRemarks = "全集"; v.VodName = "庆余年 第一季" })
+
+	restored, changes, err := RestoreVideoHistory(db, first, true)
+	if err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	if restored.VodName != "庆余年" || restored.VodRemarks != "更新至10集" || restored.VodScore != "8.0" {
+		t.Errorf("恢复后为 %s/%s/%s", restored.VodName, restored.VodRemarks, restored.VodScore)
+	}
+	if len(changes) != 3 {
+		t.Errorf("恢复的字段为 %v，期望 vod_name、vod_remarks、vod_score", changes)
+	}
+	if locked := strings.Join(LockedFields(restored), ","); locked != "vod_name,vod_remarks,vod_score" || restored.VodLock != 1 {
+		t.Errorf("锁定字段为 %q（vod_lock=%d）", locked, restored.VodLock)
+	}
+
+	var saved models.Video
+	db.First(&saved, video.ID)
+	if saved.VodRemarks != "更新至10集" {
+		t.Errorf("数据库中的 vod_remarks 为 %q", saved.VodRemarks)
+	}
+	var restore models.VideoHistory
+	if err := db.Where("video_id = ? AND action = ?", video.ID, "restore").First(&restore).Error; err != nil {
+		t.Fatalf("应记录 restore 历史: %v", err)
+	}
+	if restore.ChangedFields != "vod_name,vod_remarks,vod_score" {
+		t.Errorf("restore 历史的字段为 %q", restore.ChangedFields)
+	}
+
+	if _, _, err := RestoreVideoHistory(db, 9999, false); err == nil {
+		t.Error("历史记录不存在时应返回错误")
+	}
+}
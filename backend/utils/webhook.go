@@ -0,0 +1,468 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"vodcms/models"
+)
+
+// Webhook 事件通知
+// 1. 事件发生时为每个订阅了该事件的 Webhook 写入一条投递记录，再由后台协程异步发送
+// 2. 请求体为 JSON：{"event": "...", "created_at": "...", "data": {...}}
+// 3. 配置了密钥时，X-VodCMS-Signature 为 "sha256=" + HMAC-SHA256(密钥, 请求体) 的十六进制
+// 4. 非 2xx 响应或网络错误按退避时间重试，超过最大次数后标记为 failed
+// 5. 视频事件按导入批次合并：vod_ids 为本次导入涉及的全部视频，videos 只携带前 100 条的详情
+
+// Webhook 事件
+const (
+	WebhookEventPing               = "ping"
+	WebhookEventCollectionFinished = "collection.finished"
+	WebhookEventCollectionFailed   = "collection.failed"
+	WebhookEventSourceUnhealthy    = "source.unhealthy"
+	WebhookEventCategoryUnmapped   = "category.unmapped"
+	WebhookEventVideoCreated       = "video.created"
+	WebhookEventEpisodeAdded       = "video.episode_added"
+)
+
+// WebhookEventInfo 事件说明
+type WebhookEventInfo struct {
+	Event       string `json:"event"`
+	Description string `json:"description"`
+}
+
+// WebhookEvents 可订阅的事件列表
+var WebhookEvents = []WebhookEventInfo{
+	{WebhookEventCollectionFinished, "采集任务完成且数据已导入"},
+	{WebhookEventCollectionFailed, "采集或导入失败"},
+	{WebhookEventSourceUnhealthy, "资源站由正常变为采集失败"},
+	{WebhookEventCategoryUnmapped, "发现新的未映射分类"},
+	{WebhookEventVideoCreated, "新视频入库（每次导入合并为一次通知）"},
+	{WebhookEventEpisodeAdded, "视频更新了剧集（每次导入合并为一次通知）"},
+}
+
+const (
+	webhookWorkers       = 4
+	webhookTimeout       = 10 * time.Second
+	webhookPollInterval  = 15 * time.Second
+	webhookMaxBodyLength = 1000
+)
+
+// webhookBackoff 第N次失败后等待的时间，次数用完即放弃
+var webhookBackoff = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+}
+
+// WebhookMaxAttempts 最大发送次数
+var WebhookMaxAttempts = len(webhookBackoff) + 1
+
+// webhookPayload 请求体
+type webhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+var (
+	webhookQueue      = make(chan uint, 1000)
+	webhookWorkerOnce sync.Once
+	webhookClient     = &http.Client{Timeout: webhookTimeout}
+
+	// webhookCache 启用的 Webhook 列表，管理接口修改后调用 ReloadWebhooks 失效
+	webhookCache struct {
+		sync.Mutex
+		loaded bool
+		hooks  []models.Webhook
+	}
+)
+
+// ReloadWebhooks 清除 Webhook 缓存，下次触发事件时重新读取
+func ReloadWebhooks() {
+	webhookCache.Lock()
+	webhookCache.loaded = false
+	webhookCache.hooks = nil
+	webhookCache.Unlock()
+}
+
+func activeWebhooks(db *gorm.DB) ([]models.Webhook, error) {
+	webhookCache.Lock()
+	defer webhookCache.Unlock()
+	if !webhookCache.loaded {
+		var hooks []models.Webhook
+		if err := db.Where("is_active = ?", true).Find(&hooks).Error; err != nil {
+			return nil, err
+		}
+		webhookCache.hooks = hooks
+		webhookCache.loaded = true
+	}
+	return webhookCache.hooks, nil
+}
+
+// WebhookSubscribes 判断 Webhook 是否订阅了事件
+func WebhookSubscribes(hook *models.Webhook, event string) bool {
+	events := strings.TrimSpace(hook.Events)
+	if events == "" || events == "*" {
+		return true
+	}
+	for _, e := range strings.Split(events, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWebhookEvent 判断是否为可订阅的事件
+func IsWebhookEvent(event string) bool {
+	for _, info := range WebhookEvents {
+		if info.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// FireWebhook 触发事件，为所有订阅了该事件的 Webhook 创建投递记录（失败只记录日志，不影响业务）
+func FireWebhook(db *gorm.DB, event string, data interface{}) {
+	hooks, err := activeWebhooks(db)
+	if err != nil {
+		fmt.Printf("⚠️ 读取Webhook失败: %v\n", err)
+		return
+	}
+	var matched []models.Webhook
+	for _, hook := range hooks {
+		if WebhookSubscribes(&hook, event) {
+			matched = append(matched, hook)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	if _, err := createWebhookDeliveries(db, matched, event, data); err != nil {
+		fmt.Printf("⚠️ 创建Webhook投递记录失败 (%s): %v\n", event, err)
+	}
+}
+
+// PingWebhook 向指定 Webhook 发送测试事件（不检查订阅和启用状态）
+func PingWebhook(db *gorm.DB, hook *models.Webhook) (*models.WebhookDelivery, error) {
+	deliveries, err := createWebhookDeliveries(db, []models.Webhook{*hook}, WebhookEventPing, map[string]interface{}{
+		"webhook_id": hook.ID,
+		"message":    "VodCMS webhook test",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+// RedeliverWebhook 重新发送一条投递记录（重置重试次数）
+func RedeliverWebhook(db *gorm.DB, deliveryID uint) error {
+	now := time.Now()
+	result := db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status <> ?", deliveryID, "sending").
+		Updates(map[string]interface{}{
+			"status":        "pending",
+			"attempts":      0,
+			"next_retry_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("投递记录不存在或正在发送")
+	}
+	enqueueWebhookDelivery(deliveryID)
+	return nil
+}
+
+func createWebhookDeliveries(db *gorm.DB, hooks []models.Webhook, event string, data interface{}) ([]models.WebhookDelivery, error) {
+	body, err := json.Marshal(webhookPayload{Event: event, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deliveries := make([]models.WebhookDelivery, 0, len(hooks))
+	for _, hook := range hooks {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:   hook.ID,
+			Event:       event,
+			Payload:     string(body),
+			Status:      "pending",
+			NextRetryAt: &now,
+		})
+	}
+	if err := db.Create(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	for _, delivery := range deliveries {
+		enqueueWebhookDelivery(delivery.ID)
+	}
+	return deliveries, nil
+}
+
+// enqueueWebhookDelivery 队列已满或后台协程未启动时跳过，由轮询补发
+func enqueueWebhookDelivery(id uint) {
+	select {
+	case webhookQueue <- id:
+	default:
+	}
+}
+
+// StartWebhookWorker 启动后台投递协程（只会启动一次）
+func StartWebhookWorker(db *gorm.DB) {
+	webhookWorkerOnce.Do(func() {
+		// 上次退出时正在发送的记录重新发送
+		db.Model(&models.WebhookDelivery{}).Where("status = ?", "sending").Update("status", "pending")
+
+		for i := 0; i < webhookWorkers; i++ {
+			go func() {
+				for id := range webhookQueue {
+					deliverWebhook(db, id)
+				}
+			}()
+		}
+		go func() {
+			for {
+				pollWebhookDeliveries(db)
+				time.Sleep(webhookPollInterval)
+			}
+		}()
+	})
+}
+
+// pollWebhookDeliveries 把到期的待发送记录放入队列
+func pollWebhookDeliveries(db *gorm.DB) {
+	var ids []uint
+	db.Model(&models.WebhookDelivery{}).
+		Where("status = ? AND next_retry_at <= ?", "pending", time.Now()).
+		Order("next_retry_at ASC").
+		Limit(200).
+		Pluck("id", &ids)
+	for _, id := range ids {
+		webhookQueue <- id
+	}
+}
+
+// deliverWebhook 发送一次，并根据结果安排重试
+func deliverWebhook(db *gorm.DB, id uint) {
+	// 抢占记录，避免同一条记录被重复发送
+	claim := db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_retry_at <= ?", id, "pending", time.Now()).
+		Update("status", "sending")
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return
+	}
+
+	var delivery models.WebhookDelivery
+	if err := db.First(&delivery, id).Error; err != nil {
+		return
+	}
+	delivery.Attempts++
+
+	var hook models.Webhook
+	if err := db.First(&hook, delivery.WebhookID).Error; err != nil {
+		delivery.Status = "failed"
+		delivery.Error = "Webhook 已删除"
+		delivery.NextRetryAt = nil
+		db.Save(&delivery)
+		return
+	}
+
+	start := time.Now()
+	code, respBody, err := sendWebhook(&hook, &delivery)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	delivery.ResponseCode = code
+	delivery.ResponseBody = respBody
+	delivery.Error = ""
+
+	if err == nil && code >= 200 && code < 300 {
+		now := time.Now()
+		delivery.Status = "success"
+		delivery.DeliveredAt = &now
+		delivery.NextRetryAt = nil
+		db.Save(&delivery)
+		return
+	}
+
+	if err != nil {
+		delivery.Error = truncateString(err.Error(), 500)
+	} else {
+		delivery.Error = "HTTP " + strconv.Itoa(code)
+	}
+	if delivery.Attempts >= WebhookMaxAttempts {
+		delivery.Status = "failed"
+		delivery.NextRetryAt = nil
+		fmt.Printf("⚠️ Webhook 投递失败 (#%d %s -> %s): %s\n", delivery.ID, delivery.Event, hook.URL, delivery.Error)
+	} else {
+		next := time.Now().Add(webhookBackoff[delivery.Attempts-1])
+		delivery.Status = "pending"
+		delivery.NextRetryAt = &next
+	}
+	db.Save(&delivery)
+}
+
+func sendWebhook(hook *models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "VodCMS-Webhook/1.0")
+	req.Header.Set("X-VodCMS-Event", delivery.Event)
+	req.Header.Set("X-VodCMS-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	if hook.Secret != "" {
+		req.Header.Set("X-VodCMS-Signature", "sha256="+SignWebhookPayload(hook.Secret, []byte(delivery.Payload)))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxBodyLength))
+	return resp.StatusCode, truncateString(string(body), webhookMaxBodyLength), nil
+}
+
+// SignWebhookPayload 计算请求体签名（HMAC-SHA256，十六进制）
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// truncateString 按字节截断，不截断半个汉字
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// ============ 业务事件 ============
+
+// collectionEventData 采集事件的数据：采集日志加导入结果
+type collectionEventData struct {
+	*models.CollectionLog
+	Import      *ImportResult `json:"import,omitempty"`
+	ImportError string        `json:"import_error,omitempty"`
+}
+
+// NotifyCollectionLog 采集数据导入数据库后触发事件（导入失败时采集日志标记为 failed 并触发 collection.failed）
+// 资源站从正常变为失败时额外触发 source.unhealthy
+func NotifyCollectionLog(db *gorm.DB, log *models.CollectionLog, result *ImportResult, importErr error) {
+	data := collectionEventData{CollectionLog: log, Import: result}
+	if importErr != nil {
+		data.ImportError = importErr.Error()
+		if log.Status != "failed" {
+			log.Status = "failed"
+			db.Model(log).Update("status", log.Status)
+		}
+	}
+
+	event := WebhookEventCollectionFinished
+	if log.Status == "failed" {
+		event = WebhookEventCollectionFailed
+	}
+	FireWebhook(db, event, data)
+
+	if log.Status != "failed" {
+		return
+	}
+	var previous models.CollectionLog
+	err := db.Where("source_key = ? AND id < ? AND status <> ?", log.SourceKey, log.ID, "running").
+		Order("id DESC").
+		First(&previous).Error
+	if err == nil && previous.Status == "failed" {
+		return // 已经是失败状态，不重复通知
+	}
+	FireWebhook(db, WebhookEventSourceUnhealthy, map[string]interface{}{
+		"source_key":        log.SourceKey,
+		"source_name":       log.SourceName,
+		"collection_log_id": log.ID,
+		"failed_at":         log.EndTime,
+	})
+}
+
+// webhookVideoBatchLimit 批量视频事件中携带详情的视频数上限（vod_ids 包含全部视频）
+const webhookVideoBatchLimit = 100
+
+// webhookVideoBatch 一次导入中同一事件涉及的视频，导入结束后合并为一次通知
+type webhookVideoBatch struct {
+	event  string
+	vodIDs []int
+	videos []map[string]interface{}
+}
+
+func newWebhookVideoBatch(event string) *webhookVideoBatch {
+	return &webhookVideoBatch{event: event, vodIDs: []int{}, videos: []map[string]interface{}{}}
+}
+
+// add 记录一个视频（data 为 webhookVideoData 的结果，可附加字段）
+func (b *webhookVideoBatch) add(vodID int, data map[string]interface{}) {
+	b.vodIDs = append(b.vodIDs, vodID)
+	if len(b.videos) < webhookVideoBatchLimit {
+		b.videos = append(b.videos, data)
+	}
+}
+
+// fire 没有视频时不触发
+func (b *webhookVideoBatch) fire(db *gorm.DB, sourceKey string, collectionLogID uint) {
+	if len(b.vodIDs) == 0 {
+		return
+	}
+	FireWebhook(db, b.event, map[string]interface{}{
+		"source_key":        sourceKey,
+		"collection_log_id": collectionLogID,
+		"count":             len(b.vodIDs),
+		"vod_ids":           b.vodIDs,
+		"videos":            b.videos,
+		"truncated":         len(b.vodIDs) > len(b.videos),
+	})
+}
+
+// webhookVideoData 视频事件的数据
+func webhookVideoData(video *models.Video) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                     video.ID,
+		"vod_id":                 video.VodID,
+		"vod_name":               video.VodName,
+		"vod_pic":                video.VodPic,
+		"vod_remarks":            video.VodRemarks,
+		"standard_category_id":   video.StandardCategoryID,
+		"standard_category_name": video.StandardCategoryName,
+		"source_key":             video.SourceKey,
+		"source_name":            video.SourceName,
+	}
+}
+
+// VideoEpisodeCount 视频的剧集数（取剧集最多的播放组）
+func VideoEpisodeCount(video *models.Video) int {
+	count := 0
+	for _, group := range VideoPlayGroups(video) {
+		if len(group.Episodes) > count {
+			count = len(group.Episodes)
+		}
+	}
+	return count
+}
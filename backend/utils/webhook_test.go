@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vodcms/models"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		// 常见的 HMAC-SHA256 测试向量
+		{"key", "The quick brown fox jumps over the lazy dog", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"", "", "b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+	}
+	for _, tt := range tests {
+		if got := SignWebhookPayload(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("SignWebhookPayload(%q, %q) = %s，期望 %s", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestSendWebhookSignature(t *testing.T) {
+	var gotSignature, gotEvent, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get("X-VodCMS-Signature")
+		gotEvent = r.Header.Get("X-VodCMS-Event")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	payload := `{"event":"ping","data":{"message":"测试"}}`
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{"有密钥", "s3cret", "sha256=" + SignWebhookPayload("s3cret", []byte(payload))},
+		{"无密钥不签名", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &models.Webhook{URL: srv.URL, Secret: tt.secret}
+			delivery := &models.WebhookDelivery{ID: 1, Event: WebhookEventPing, Payload: payload}
+			code, body, err := sendWebhook(hook, delivery)
+			if err != nil || code != http.StatusOK || body != "ok" {
+				t.Fatalf("发送失败: code=%d body=%q err=%v", code, body, err)
+			}
+			if gotSignature != tt.want {
+				t.Errorf("签名 = %q，期望 %q", gotSignature, tt.want)
+			}
+			if gotEvent != WebhookEventPing || gotBody != payload {
+				t.Errorf("事件或请求体不一致: %q %q", gotEvent, gotBody)
+			}
+		})
+	}
+}
+
+func TestWebhookSubscribes(t *testing.T) {
+	tests := []struct {
+		events string
+		event  string
+		want   bool
+	}{
+		{"", WebhookEventVideoCreated, true},
+		{"*", WebhookEventVideoCreated, true},
+		{"video.created", WebhookEventVideoCreated, true},
+		{"collection.finished, video.created", WebhookEventVideoCreated, true},
+		{"collection.finished", WebhookEventVideoCreated, false},
+		{"video.created", WebhookEventEpisodeAdded, false},
+	}
+	for _, tt := range tests {
+		hook := &models.Webhook{Events: tt.events}
+		if got := WebhookSubscribes(hook, tt.event); got != tt.want {
+			t.Errorf("WebhookSubscribes(%q, %q) = %v，期望 %v", tt.events, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"abc", 5, "abc"},
+		{"abcdef", 3, "abc"},
+		{"中文", 4, "中"}, // 不截断半个汉字
+		{"中文", 6, "中文"},
+		{"中文", 2, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateString(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncateString(%q, %d) = %q，期望 %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookVideoBatch(t *testing.T) {
+	db := newTestDB(t)
+	ReloadWebhooks()
+	t.Cleanup(ReloadWebhooks)
+
+	subscribed := models.Webhook{URL: "http://example.com/a", Events: WebhookEventVideoCreated, IsActive: true}
+	other := models.Webhook{URL: "http://example.com/b", Events: WebhookEventCollectionFinished, IsActive: true}
+	db.Create(&subscribed)
+	db.Create(&other)
+
+	tests := []struct {
+		name      string
+		count     int
+		wantCount int64
+		wantVideo int
+	}{
+		{"没有视频不触发", 0, 0, 0},
+		{"少量视频", 3, 1, 3},
+		{"超过上限只携带部分详情", webhookVideoBatchLimit + 50, 1, webhookVideoBatchLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.Where("1 = 1").Delete(&models.WebhookDelivery{})
+			batch := newWebhookVideoBatch(WebhookEventVideoCreated)
+			for i := 1; i <= tt.count; i++ {
+				batch.add(i, map[string]interface{}{"vod_id": i})
+			}
+			batch.fire(db, "src", 7)
+
+			var deliveries []models.WebhookDelivery
+			db.Find(&deliveries)
+			if int64(len(deliveries)) != tt.wantCount {
+				t.Fatalf("投递记录 %d 条，期望 %d 条", len(deliveries), tt.wantCount)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			if deliveries[0].WebhookID != subscribed.ID {
+				t.Errorf("投递给了未订阅的 Webhook #%d", deliveries[0].WebhookID)
+			}
+			var payload struct {
+				Data struct {
+					SourceKey       string           `json:"source_key"`
+					CollectionLogID uint             `json:"collection_log_id"`
+					Count           int              `json:"count"`
+					VodIDs          []int            `json:"vod_ids"`
+					Videos          []map[string]int `json:"videos"`
+					Truncated       bool             `json:"truncated"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(deliveries[0].Payload), &payload); err != nil {
+				t.Fatalf("解析请求体失败: %v", err)
+			}
+			d := payload.Data
+			if d.SourceKey != "src" || d.CollectionLogID != 7 || d.Count != tt.count || len(d.VodIDs) != tt.count {
+				t.Errorf("批量数据不正确: %+v", d)
+			}
+			if len(d.Videos) != tt.wantVideo || d.Truncated != (tt.count > tt.wantVideo) {
+				t.Errorf("详情 %d 条 truncated=%v，期望 %d 条", len(d.Videos), d.Truncated, tt.wantVideo)
+			}
+		})
+	}
+}